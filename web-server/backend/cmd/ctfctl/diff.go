@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/obelisk/example-ctf/services"
+)
+
+// changeKind is what importing a challenge would do
+type changeKind string
+
+const (
+	changeCreate    changeKind = "create"
+	changeUpdate    changeKind = "update"
+	changeUnchanged changeKind = "unchanged"
+	changeRemove    changeKind = "remove"
+)
+
+// plannedChange is the effect of importing a single challenge
+type plannedChange struct {
+	Entry       packEntry
+	Kind        changeKind
+	Fields      []string
+	Attachments []attachmentChange
+}
+
+// attachmentChange is the effect of importing a single attachment
+type attachmentChange struct {
+	Filename string
+	Kind     changeKind
+	// Local is the file to upload, nil when the attachment is removed
+	Local *packAttachment
+}
+
+// diffAttachments compares a challenge's attachments in the database with the
+// pack by checksum. Attachments missing from the pack are removed.
+func diffAttachments(current []services.Attachment, next []packAttachment) []attachmentChange {
+	existing := make(map[string]string)
+	for _, attachment := range current {
+		existing[attachment.Filename] = attachment.SHA256
+	}
+
+	var changes []attachmentChange
+	inPack := make(map[string]bool)
+	for i := range next {
+		local := &next[i]
+		inPack[local.Filename] = true
+
+		change := attachmentChange{Filename: local.Filename, Kind: changeCreate, Local: local}
+		if checksum, exists := existing[local.Filename]; exists {
+			change.Kind = changeUpdate
+			if checksum == local.SHA256 {
+				change.Kind = changeUnchanged
+			}
+		}
+		changes = append(changes, change)
+	}
+
+	for _, attachment := range current {
+		if !inPack[attachment.Filename] {
+			changes = append(changes, attachmentChange{Filename: attachment.Filename, Kind: changeRemove})
+		}
+	}
+
+	return changes
+}
+
+// diffDefinitions lists the fields that differ between the database and the pack.
+// Flag values are never printed, only reported as changed.
+func diffDefinitions(current, next services.ChallengeDefinition) []string {
+	var fields []string
+	field := func(name string, from, to any) {
+		if fmt.Sprint(from) != fmt.Sprint(to) {
+			fields = append(fields, fmt.Sprintf("%s: %q -> %q", name, fmt.Sprint(from), fmt.Sprint(to)))
+		}
+	}
+
+	field("nested_id", current.NestedID, next.NestedID)
+	field("name", current.Name, next.Name)
+	field("category", current.Category, next.Category)
+	field("scoring", scoringMode(current), scoringMode(next))
+	if scoringMode(next) == services.ScoringStatic {
+		field("points", current.PointRewardAmount, next.PointRewardAmount)
+	} else {
+		// The current value of a dynamic challenge follows its solves, so only the curve is compared
+		field("scoring.initial", current.InitialPoints, next.InitialPoints)
+		field("scoring.minimum", current.MinimumPoints, next.MinimumPoints)
+		field("scoring.decay", current.Decay, next.Decay)
+	}
+	field("blood_bonuses", current.BloodBonuses, next.BloodBonuses)
+	field("hidden", current.Hidden, next.Hidden)
+	if next.Category == "exam" {
+		// Unset costs and rewards default to 1 token
+		field("submission_cost", derefInt(current.SubmissionCost, 1), derefInt(next.SubmissionCost, 1))
+		field("completion_reward", derefInt(current.CompletionReward, 1), derefInt(next.CompletionReward, 1))
+		field("max_attempts", derefInt(current.MaxAttempts, 0), derefInt(next.MaxAttempts, 0))
+		field("exam_track", examTrack(current), examTrack(next))
+	}
+	field("release_at", formatTime(current.ReleaseAt), formatTime(next.ReleaseAt))
+	field("file_asset", deref(current.FileAsset), deref(next.FileAsset))
+	field("flag.handler", current.ValidationHandler, next.ValidationHandler)
+	field("artifact.generator", current.ArtifactGenerator, next.ArtifactGenerator)
+	field("prerequisites", current.Prerequisites, next.Prerequisites)
+
+	if current.Description != next.Description {
+		fields = append(fields, "description changed")
+	}
+	if deref(current.TextAsset) != deref(next.TextAsset) {
+		fields = append(fields, "text_asset changed")
+	}
+	if current.FlagValue != next.FlagValue {
+		fields = append(fields, "flag.value changed")
+	}
+	if !sameParams(current.ValidationParams, next.ValidationParams) {
+		fields = append(fields, fmt.Sprintf("flag.params: %s -> %s", compactParams(current.ValidationParams), compactParams(next.ValidationParams)))
+	}
+	if !sameParams(current.ArtifactParams, next.ArtifactParams) {
+		fields = append(fields, fmt.Sprintf("artifact.params: %s -> %s", compactParams(current.ArtifactParams), compactParams(next.ArtifactParams)))
+	}
+
+	return fields
+}
+
+// sameParams compares two parameter blobs ignoring formatting and key order
+func sameParams(a, b json.RawMessage) bool {
+	return compactParams(a) == compactParams(b)
+}
+
+// compactParams normalises a parameter blob, treating an empty blob as {}
+func compactParams(params json.RawMessage) string {
+	if len(bytes.TrimSpace(params)) == 0 {
+		return "{}"
+	}
+	var decoded any
+	if err := json.Unmarshal(params, &decoded); err != nil {
+		return string(params)
+	}
+	normalised, err := json.Marshal(decoded)
+	if err != nil {
+		return string(params)
+	}
+	return string(normalised)
+}
+
+// scoringMode treats an unset scoring mode as static
+func scoringMode(def services.ChallengeDefinition) services.ScoringMode {
+	if def.Scoring == "" {
+		return services.ScoringStatic
+	}
+	return def.Scoring
+}
+
+func deref(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+// examTrack returns an exam challenge's track, which defaults to the main track
+func examTrack(def services.ChallengeDefinition) string {
+	if def.ExamTrack == "" {
+		return services.DefaultExamTrack
+	}
+	return def.ExamTrack
+}
+
+// derefInt returns an optional number or its default
+func derefInt(value *int, fallback int) int {
+	if value == nil {
+		return fallback
+	}
+	return *value
+}
+
+// formatTime renders an optional time in UTC so equal instants compare equal
+func formatTime(value *time.Time) string {
+	if value == nil {
+		return ""
+	}
+	return value.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,412 @@
+// Command ctfctl imports and exports challenge packs.
+//
+// A pack is a directory of YAML or JSON challenge definitions, optionally with
+// attachment files next to them. Usage:
+//
+//	ctfctl import [-dry-run] [-actor email] DIR
+//	ctfctl diff DIR
+//	ctfctl export [-format yaml|json] DIR
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+
+	_ "github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/obelisk/example-ctf/config"
+	"github.com/obelisk/example-ctf/services"
+)
+
+const usage = `usage:
+  ctfctl import [-dry-run] [-actor email] DIR   import a challenge pack
+  ctfctl diff DIR                               show what importing DIR would change
+  ctfctl export [-format yaml|json] DIR         write every challenge to DIR`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "import":
+		err = runImport(os.Args[2:], false)
+	case "diff":
+		err = runImport(os.Args[2:], true)
+	case "export":
+		err = runExport(os.Args[2:])
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// newContainer connects to the database configured for the backend
+func newContainer() (*services.Container, error) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	db, err := sql.Open("postgres", cfg.Database.ConnectionString())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to db: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return services.NewContainer(db, &cfg)
+}
+
+// runImport plans an import of a pack and, unless dryRun is set, applies it
+func runImport(args []string, dryRun bool) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	flags.BoolVar(&dryRun, "dry-run", dryRun, "show what would change without writing anything")
+	actor := flags.String("actor", "ctfctl", "name recorded in the admin audit log")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New(usage)
+	}
+
+	pack, err := loadPack(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	container, err := newContainer()
+	if err != nil {
+		return err
+	}
+	defer container.DB.Close()
+	ctx := context.Background()
+
+	plan, err := planImport(ctx, container, pack)
+	if err != nil {
+		return err
+	}
+	if err := printPlan(ctx, container, plan); err != nil {
+		return err
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	// Each challenge is written in its own transaction, so stop at the first failure
+	// and say which changes were already made
+	for i, change := range plan {
+		written, err := applyChange(ctx, container, *actor, change)
+		if err != nil {
+			printApplied(plan[:i])
+			if written {
+				def := change.Entry.Definition
+				fmt.Printf("%-9s %3d %s (definition written, attachments incomplete)\n", "partial", def.ID, def.Name)
+			}
+			return err
+		}
+	}
+
+	log.Infof("imported %d challenges", len(plan))
+	return nil
+}
+
+// applyChange writes a single planned change. It reports whether the challenge
+// definition was written, even when one of its attachments then failed.
+func applyChange(ctx context.Context, container *services.Container, actor string, change plannedChange) (bool, error) {
+	def := change.Entry.Definition
+
+	var err error
+	switch change.Kind {
+	case changeCreate:
+		err = container.ChallengeClient.CreateChallenge(ctx, actor, def)
+	case changeUpdate:
+		err = container.ChallengeClient.UpdateChallenge(ctx, actor, def)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to import challenge %d from %s: %w", def.ID, change.Entry.Path, err)
+	}
+	written := change.Kind == changeCreate || change.Kind == changeUpdate
+
+	for _, attachment := range change.Attachments {
+		switch attachment.Kind {
+		case changeCreate, changeUpdate:
+			err = uploadAttachment(ctx, container, actor, def.ID, *attachment.Local)
+		case changeRemove:
+			err = container.Attachments.Delete(ctx, actor, def.ID, attachment.Filename)
+		}
+		if err != nil {
+			return written, fmt.Errorf("failed to import attachment %s for challenge %d: %w", attachment.Filename, def.ID, err)
+		}
+	}
+
+	return written, nil
+}
+
+// printApplied lists the changes an interrupted import already made
+func printApplied(applied []plannedChange) {
+	fmt.Println("import stopped; these changes were applied:")
+	count := 0
+	for _, change := range applied {
+		changed := change.Kind != changeUnchanged
+		for _, attachment := range change.Attachments {
+			changed = changed || attachment.Kind != changeUnchanged
+		}
+		if !changed {
+			continue
+		}
+		def := change.Entry.Definition
+		fmt.Printf("%-9s %3d %s\n", "applied", def.ID, def.Name)
+		count++
+	}
+	if count == 0 {
+		fmt.Println("          (none)")
+	}
+}
+
+// planImport validates every definition in the pack and works out whether it
+// creates, updates or leaves each challenge unchanged. Nothing is written, so
+// an invalid definition stops the import before any challenge changes.
+func planImport(ctx context.Context, container *services.Container, pack []packEntry) ([]plannedChange, error) {
+	var invalid []error
+	plan := make([]plannedChange, 0, len(pack))
+
+	for _, entry := range pack {
+		if err := container.ChallengeClient.CheckDefinition(entry.Definition); err != nil {
+			invalid = append(invalid, fmt.Errorf("%s: %w", entry.Path, err))
+			continue
+		}
+
+		change := plannedChange{Entry: entry, Kind: changeCreate}
+		current, err := container.ChallengeClient.GetChallengeDefinition(ctx, entry.Definition.ID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		if err == nil {
+			change.Fields = diffDefinitions(*current, entry.Definition)
+			change.Kind = changeUnchanged
+			if len(change.Fields) > 0 {
+				change.Kind = changeUpdate
+			}
+		}
+
+		attachments, err := container.Attachments.List(ctx, entry.Definition.ID)
+		if err != nil {
+			return nil, err
+		}
+		change.Attachments = diffAttachments(attachments, entry.Attachments)
+
+		plan = append(plan, change)
+	}
+
+	if len(invalid) > 0 {
+		return nil, fmt.Errorf("invalid challenge definitions:\n%w", errors.Join(invalid...))
+	}
+
+	return orderByPrerequisites(ctx, container, plan)
+}
+
+// orderByPrerequisites rejects packs whose prerequisites, together with those of
+// challenges only in the database, form a cycle. It then orders the plan so every
+// challenge is written after the pack challenges it requires.
+func orderByPrerequisites(ctx context.Context, container *services.Container, plan []plannedChange) ([]plannedChange, error) {
+	existing, err := container.ChallengeClient.ListChallengeDefinitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	prerequisites := make(map[int][]services.PrerequisiteGroup)
+	for _, def := range existing {
+		prerequisites[def.ID] = def.Prerequisites
+	}
+	inPlan := make(map[int]int)
+	for i, change := range plan {
+		prerequisites[change.Entry.Definition.ID] = change.Entry.Definition.Prerequisites
+		inPlan[change.Entry.Definition.ID] = i
+	}
+
+	graph := services.PrerequisiteGraph(prerequisites)
+	if cycle := services.FindPrerequisiteCycle(graph); cycle != nil {
+		return nil, fmt.Errorf("prerequisites form a cycle: %s", services.FormatPrerequisiteCycle(cycle))
+	}
+
+	ordered := make([]plannedChange, 0, len(plan))
+	written := make(map[int]bool)
+	var write func(id int)
+	write = func(id int) {
+		if written[id] {
+			return
+		}
+		written[id] = true
+		for _, required := range graph[id] {
+			if _, ok := inPlan[required]; ok {
+				write(required)
+			}
+		}
+		ordered = append(ordered, plan[inPlan[id]])
+	}
+	for _, change := range plan {
+		write(change.Entry.Definition.ID)
+	}
+
+	return ordered, nil
+}
+
+// printPlan shows the planned changes, and challenges that exist only in the database
+func printPlan(ctx context.Context, container *services.Container, plan []plannedChange) error {
+	inPack := make(map[int]bool)
+	for _, change := range plan {
+		def := change.Entry.Definition
+		inPack[def.ID] = true
+
+		fmt.Printf("%-9s %3d %s\n", change.Kind, def.ID, def.Name)
+		for _, field := range change.Fields {
+			fmt.Printf("          - %s\n", field)
+		}
+		for _, attachment := range change.Attachments {
+			if attachment.Kind != changeUnchanged {
+				fmt.Printf("          - attachment %s: %s\n", attachment.Filename, attachment.Kind)
+			}
+		}
+	}
+
+	existing, err := container.ChallengeClient.ListChallengeDefinitions(ctx)
+	if err != nil {
+		return err
+	}
+	for _, def := range existing {
+		if !inPack[def.ID] {
+			fmt.Printf("%-9s %3d %s (only in database, left as is)\n", "skip", def.ID, def.Name)
+		}
+	}
+
+	return nil
+}
+
+// uploadAttachment attaches a local file to a challenge
+func uploadAttachment(ctx context.Context, container *services.Container, actor string, challengeID int, attachment packAttachment) error {
+	file, err := os.Open(attachment.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open attachment: %w", err)
+	}
+	defer file.Close()
+
+	contentType := mime.TypeByExtension(filepath.Ext(attachment.Path))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	_, err = container.Attachments.Put(ctx, actor, challengeID, attachment.Filename, contentType, file)
+	return err
+}
+
+// runExport writes every challenge definition in the database to a pack directory
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "yaml", "file format, yaml or json")
+	flags.Parse(args)
+	if flags.NArg() != 1 || (*format != "yaml" && *format != "json") {
+		return errors.New(usage)
+	}
+	dir := flags.Arg(0)
+
+	container, err := newContainer()
+	if err != nil {
+		return err
+	}
+	defer container.DB.Close()
+
+	ctx := context.Background()
+
+	definitions, err := container.ChallengeClient.ListChallengeDefinitions(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	for _, def := range definitions {
+		attachments, err := exportAttachments(ctx, container, dir, def.ID)
+		if err != nil {
+			return err
+		}
+		if err := writeChallengeFile(filepath.Join(dir, exportFileName(def, *format)), def, attachments, *format); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("exported %d challenges to %s", len(definitions), dir)
+	return nil
+}
+
+// exportAttachments downloads a challenge's attachments into a per-challenge
+// directory, verifying each checksum, and returns their paths relative to dir
+func exportAttachments(ctx context.Context, container *services.Container, dir string, challengeID int) ([]string, error) {
+	attachments, err := container.Attachments.List(ctx, challengeID)
+	if err != nil {
+		return nil, err
+	}
+	if len(attachments) == 0 {
+		return nil, nil
+	}
+
+	subdir := fmt.Sprintf("%03d-attachments", challengeID)
+	if err := os.MkdirAll(filepath.Join(dir, subdir), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create attachment directory: %w", err)
+	}
+
+	paths := make([]string, 0, len(attachments))
+	for _, attachment := range attachments {
+		relative := filepath.Join(subdir, attachment.Filename)
+		if err := downloadAttachment(ctx, container, attachment, filepath.Join(dir, relative)); err != nil {
+			return nil, err
+		}
+		paths = append(paths, filepath.ToSlash(relative))
+	}
+
+	return paths, nil
+}
+
+// downloadAttachment copies an attachment out of the asset store and checks its checksum
+func downloadAttachment(ctx context.Context, container *services.Container, attachment services.Attachment, path string) error {
+	body, err := container.AssetService.OpenAsset(ctx, attachment.AssetPath)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", attachment.AssetPath, err)
+	}
+	defer body.Close()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(file, hasher), body); err != nil {
+		return fmt.Errorf("failed to download %s: %w", attachment.AssetPath, err)
+	}
+
+	if checksum := hex.EncodeToString(hasher.Sum(nil)); checksum != attachment.SHA256 {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", attachment.AssetPath, attachment.SHA256, checksum)
+	}
+
+	return file.Close()
+}
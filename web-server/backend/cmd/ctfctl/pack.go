@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/obelisk/example-ctf/services"
+)
+
+// challengeFile is the on-disk format of a challenge definition in a pack
+type challengeFile struct {
+	ID          int    `yaml:"id" json:"id"`
+	NestedID    int    `yaml:"nested_id" json:"nested_id"`
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description"`
+	Category    string `yaml:"category" json:"category"`
+	// Points is ignored for dynamic challenges, which start at scoring.initial
+	Points int `yaml:"points" json:"points"`
+	// Scoring makes the challenge's value decay as it is solved
+	Scoring *scoringFile `yaml:"scoring,omitempty" json:"scoring,omitempty"`
+	// BloodBonuses are extra points for the first, second and third solves
+	BloodBonuses []int `yaml:"blood_bonuses,omitempty" json:"blood_bonuses,omitempty"`
+	// SubmissionCost, CompletionReward and MaxAttempts are the economy of exam challenges
+	SubmissionCost   *int `yaml:"submission_cost,omitempty" json:"submission_cost,omitempty"`
+	CompletionReward *int `yaml:"completion_reward,omitempty" json:"completion_reward,omitempty"`
+	MaxAttempts      *int `yaml:"max_attempts,omitempty" json:"max_attempts,omitempty"`
+	// ExamTrack is the track an exam challenge is a stage of; the track must already exist
+	ExamTrack string `yaml:"exam_track,omitempty" json:"exam_track,omitempty"`
+	Hidden    bool   `yaml:"hidden,omitempty" json:"hidden,omitempty"`
+	// ReleaseAt is an RFC 3339 time before which submissions are rejected
+	ReleaseAt *time.Time `yaml:"release_at,omitempty" json:"release_at,omitempty"`
+	TextAsset *string    `yaml:"text_asset,omitempty" json:"text_asset,omitempty"`
+	// FileAsset is the key of a single legacy asset already in the store
+	FileAsset *string `yaml:"file_asset,omitempty" json:"file_asset,omitempty"`
+	// Attachments are files next to the definition, uploaded on import
+	Attachments []string `yaml:"attachments,omitempty" json:"attachments,omitempty"`
+	// Artifact is the generator that builds a per-user file or text asset
+	Artifact *artifactFile `yaml:"artifact,omitempty" json:"artifact,omitempty"`
+	// Prerequisites must all be met before players can open the challenge
+	Prerequisites []prerequisiteFile `yaml:"prerequisites,omitempty" json:"prerequisites,omitempty"`
+	Flag          flagFile           `yaml:"flag" json:"flag"`
+}
+
+// prerequisiteFile is the on-disk format of a prerequisite group
+type prerequisiteFile struct {
+	Required   int   `yaml:"required" json:"required"`
+	Challenges []int `yaml:"challenges" json:"challenges"`
+}
+
+// scoringFile is the on-disk format of a dynamic challenge's scoring
+type scoringFile struct {
+	Mode    services.ScoringMode `yaml:"mode" json:"mode"`
+	Initial int                  `yaml:"initial" json:"initial"`
+	Minimum int                  `yaml:"minimum" json:"minimum"`
+	Decay   int                  `yaml:"decay" json:"decay"`
+}
+
+// artifactFile is the on-disk format of a challenge's artifact generator
+type artifactFile struct {
+	Generator string         `yaml:"generator" json:"generator"`
+	Params    map[string]any `yaml:"params,omitempty" json:"params,omitempty"`
+}
+
+// flagFile is the on-disk format of a challenge's flag
+type flagFile struct {
+	Value   string         `yaml:"value" json:"value"`
+	Handler string         `yaml:"handler" json:"handler"`
+	Params  map[string]any `yaml:"params,omitempty" json:"params,omitempty"`
+}
+
+// packAttachment is a local file to attach to a challenge
+type packAttachment struct {
+	Filename string
+	Path     string
+	SHA256   string
+}
+
+// packEntry is a challenge loaded from a pack along with where it came from
+type packEntry struct {
+	Path        string
+	Attachments []packAttachment
+	Definition  services.ChallengeDefinition
+}
+
+// hashFile returns the hex SHA-256 of a file
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// loadPack reads every YAML and JSON challenge definition in dir
+func loadPack(dir string) ([]packEntry, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pack directory: %w", err)
+	}
+
+	var pack []packEntry
+	seen := make(map[int]string)
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		loaded, err := loadChallengeFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if other, exists := seen[loaded.Definition.ID]; exists {
+			return nil, fmt.Errorf("%s and %s both define challenge %d", other, path, loaded.Definition.ID)
+		}
+		seen[loaded.Definition.ID] = path
+
+		pack = append(pack, loaded)
+	}
+
+	sort.Slice(pack, func(i, j int) bool {
+		return pack[i].Definition.ID < pack[j].Definition.ID
+	})
+
+	return pack, nil
+}
+
+// loadChallengeFile reads a single challenge definition
+func loadChallengeFile(path string) (packEntry, error) {
+	loaded := packEntry{Path: path}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return loaded, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var file challengeFile
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&file)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(raw))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&file)
+	}
+	if err != nil {
+		return loaded, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	params := json.RawMessage("{}")
+	if len(file.Flag.Params) > 0 {
+		params, err = json.Marshal(file.Flag.Params)
+		if err != nil {
+			return loaded, fmt.Errorf("invalid flag params in %s: %w", path, err)
+		}
+	}
+
+	loaded.Definition = services.ChallengeDefinition{
+		ID:                file.ID,
+		NestedID:          file.NestedID,
+		Name:              file.Name,
+		Description:       file.Description,
+		Category:          file.Category,
+		PointRewardAmount: file.Points,
+		FileAsset:         file.FileAsset,
+		TextAsset:         file.TextAsset,
+		Hidden:            file.Hidden,
+		ReleaseAt:         file.ReleaseAt,
+		BloodBonuses:      file.BloodBonuses,
+		SubmissionCost:    file.SubmissionCost,
+		CompletionReward:  file.CompletionReward,
+		MaxAttempts:       file.MaxAttempts,
+		ExamTrack:         file.ExamTrack,
+		FlagValue:         file.Flag.Value,
+		ValidationHandler: file.Flag.Handler,
+		ValidationParams:  params,
+	}
+
+	if file.Scoring != nil {
+		loaded.Definition.Scoring = file.Scoring.Mode
+		loaded.Definition.InitialPoints = file.Scoring.Initial
+		loaded.Definition.MinimumPoints = file.Scoring.Minimum
+		loaded.Definition.Decay = file.Scoring.Decay
+		loaded.Definition.PointRewardAmount = file.Scoring.Initial
+	}
+
+	for _, group := range file.Prerequisites {
+		loaded.Definition.Prerequisites = append(loaded.Definition.Prerequisites, services.PrerequisiteGroup{
+			Required:     group.Required,
+			ChallengeIDs: group.Challenges,
+		})
+	}
+
+	if file.Artifact != nil {
+		loaded.Definition.ArtifactGenerator = file.Artifact.Generator
+		loaded.Definition.ArtifactParams = json.RawMessage("{}")
+		if len(file.Artifact.Params) > 0 {
+			loaded.Definition.ArtifactParams, err = json.Marshal(file.Artifact.Params)
+			if err != nil {
+				return loaded, fmt.Errorf("invalid artifact params in %s: %w", path, err)
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range file.Attachments {
+		filename := filepath.Base(name)
+		if seen[filename] {
+			return loaded, fmt.Errorf("%s attaches %s twice", path, filename)
+		}
+		seen[filename] = true
+
+		attachment := packAttachment{
+			Filename: filename,
+			Path:     filepath.Join(filepath.Dir(path), name),
+		}
+		attachment.SHA256, err = hashFile(attachment.Path)
+		if err != nil {
+			return loaded, fmt.Errorf("attachment for %s: %w", path, err)
+		}
+		loaded.Attachments = append(loaded.Attachments, attachment)
+	}
+
+	return loaded, nil
+}
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// exportFileName names an exported challenge after its ID and name
+func exportFileName(def services.ChallengeDefinition, format string) string {
+	slug := strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(def.Name), "-"), "-")
+	return fmt.Sprintf("%03d-%s.%s", def.ID, slug, format)
+}
+
+// writeChallengeFile writes a challenge definition in the pack format. Attachment
+// names are listed as they will be written next to the definition.
+func writeChallengeFile(path string, def services.ChallengeDefinition, attachments []string, format string) error {
+	file := challengeFile{
+		ID:               def.ID,
+		NestedID:         def.NestedID,
+		Name:             def.Name,
+		Description:      def.Description,
+		Category:         def.Category,
+		Points:           def.PointRewardAmount,
+		Hidden:           def.Hidden,
+		ReleaseAt:        def.ReleaseAt,
+		TextAsset:        def.TextAsset,
+		BloodBonuses:     def.BloodBonuses,
+		SubmissionCost:   def.SubmissionCost,
+		CompletionReward: def.CompletionReward,
+		MaxAttempts:      def.MaxAttempts,
+		ExamTrack:        def.ExamTrack,
+		FileAsset:        def.FileAsset,
+		Attachments:      attachments,
+		Flag: flagFile{
+			Value:   def.FlagValue,
+			Handler: def.ValidationHandler,
+		},
+	}
+	if len(def.ValidationParams) > 0 {
+		if err := json.Unmarshal(def.ValidationParams, &file.Flag.Params); err != nil {
+			return fmt.Errorf("invalid flag params for challenge %d: %w", def.ID, err)
+		}
+	}
+	if def.Scoring != "" && def.Scoring != services.ScoringStatic {
+		file.Points = def.InitialPoints
+		file.Scoring = &scoringFile{
+			Mode:    def.Scoring,
+			Initial: def.InitialPoints,
+			Minimum: def.MinimumPoints,
+			Decay:   def.Decay,
+		}
+	}
+	for _, group := range def.Prerequisites {
+		file.Prerequisites = append(file.Prerequisites, prerequisiteFile{
+			Required:   group.Required,
+			Challenges: group.ChallengeIDs,
+		})
+	}
+	if def.ArtifactGenerator != "" {
+		file.Artifact = &artifactFile{Generator: def.ArtifactGenerator}
+		if len(def.ArtifactParams) > 0 {
+			if err := json.Unmarshal(def.ArtifactParams, &file.Artifact.Params); err != nil {
+				return fmt.Errorf("invalid artifact params for challenge %d: %w", def.ID, err)
+			}
+		}
+	}
+
+	var out []byte
+	var err error
+	if format == "json" {
+		out, err = json.MarshalIndent(file, "", "  ")
+		out = append(out, '\n')
+	} else {
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		err = encoder.Encode(file)
+		out = buf.Bytes()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode challenge %d: %w", def.ID, err)
+	}
+
+	return os.WriteFile(path, out, 0o644)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"net/http"
+	"os"
 
 	"github.com/obelisk/example-ctf/config"
 	"github.com/obelisk/example-ctf/middleware"
@@ -25,18 +26,8 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	// Build database connection string from config
-	connStr := fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=%s",
-		cfg.Database.User,
-		cfg.Database.Password,
-		cfg.Database.Hostname,
-		cfg.Database.Port,
-		cfg.Database.Database,
-		cfg.Database.SslMode,
-	)
-
 	// Connect to database
-	db, err := sql.Open("postgres", connStr)
+	db, err := sql.Open("postgres", cfg.Database.ConnectionString())
 	if err != nil {
 		log.Fatalf("Failed to connect to db: %v", err)
 	}
@@ -47,8 +38,31 @@ func main() {
 		log.Fatalf("Failed to ping database: %v", err)
 	}
 
+	// Run the migrate subcommand instead of the server if requested
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(db, os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	// Bring the schema up to date before serving requests
+	if cfg.Database.AutoMigrate {
+		if err := autoMigrate(db); err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+	}
+
 	// Create dependency container
-	container := services.NewContainer(db, &cfg)
+	container, err := services.NewContainer(db, &cfg)
+	if err != nil {
+		log.Fatalf("Failed to create services: %v", err)
+	}
+
+	// Refuse to start if any flag names an unknown validation handler
+	if err := container.ChallengeClient.VerifyFlags(context.Background()); err != nil {
+		log.Fatalf("Invalid flag configuration: %v", err)
+	}
 
 	// Start Slack leaderboard updates if configured
 	if container.SlackService != nil && cfg.Slack.LeaderboardInterval > 0 {
@@ -56,6 +70,12 @@ func main() {
 		log.Printf("Slack leaderboard updates started with interval: %v", cfg.Slack.LeaderboardInterval)
 	}
 
+	// Announce scheduled challenge releases as they go live
+	if container.SlackService != nil {
+		container.SlackService.StartReleaseAnnouncements(context.Background())
+		log.Printf("Slack release announcements started with interval: %v", cfg.Slack.ReleaseCheckInterval)
+	}
+
 	// Create health check router (separate port)
 	healthRouter := mux.NewRouter()
 	healthRouter.HandleFunc("/health", routes.HealthCheckHandler(container)).Methods("GET")
@@ -79,6 +99,11 @@ func main() {
 	r.Use(middleware.LoadAuthenticatedUser(container))
 	r.Use(middleware.SecurityHeadersMiddleware)
 
+	// Serve assets from the local asset store through signed URLs
+	if cfg.AwsConfig.Backend == "local" {
+		r.PathPrefix(services.LocalAssetRoute).Handler(http.StripPrefix(services.LocalAssetRoute, routes.ServeLocalAsset(container))).Methods("GET")
+	}
+
 	// Serve static files from frontend-simple directory
 	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir("./frontend-simple/"))))
 
@@ -94,11 +119,66 @@ func main() {
 	authR.HandleFunc("/challenges", routes.ListChallenges(container)).Methods("GET")
 	authR.HandleFunc("/challenges/{id}", routes.GetChallenge(container)).Methods("GET")
 	authR.HandleFunc("/challenges/{id}/submission", routes.SubmitChallenge(container)).Methods("POST")
+	authR.HandleFunc("/challenges/{id}/hints", routes.ListHints(container)).Methods("GET")
+	authR.HandleFunc("/challenges/{id}/hints/{hintId}/unlock", routes.UnlockHint(container)).Methods("POST")
+
+	authR.HandleFunc("/leaderboard", routes.GetLeaderboard(container)).Methods("GET")
+	authR.HandleFunc("/leaderboard/me", routes.GetLeaderboardMe(container)).Methods("GET")
+	authR.HandleFunc("/competition", routes.GetCompetition(container)).Methods("GET")
+
+	authR.HandleFunc("/team", routes.GetTeam(container)).Methods("GET")
+	authR.HandleFunc("/teams", routes.CreateTeam(container)).Methods("POST")
+	authR.HandleFunc("/team/join", routes.JoinTeam(container)).Methods("POST")
+	authR.HandleFunc("/team/leave", routes.LeaveTeam(container)).Methods("POST")
+	authR.HandleFunc("/teams/leaderboard", routes.GetTeamLeaderboard(container)).Methods("GET")
 
 	authR.HandleFunc("/adoble", routes.ListExamChallenges(container)).Methods("GET")
+	authR.HandleFunc("/adoble/session", routes.GetExamSession(container)).Methods("GET")
+	authR.HandleFunc("/adoble/start", routes.StartExam(container)).Methods("POST")
+	authR.HandleFunc("/adoble/tracks", routes.ListExamTracks(container)).Methods("GET")
+	authR.HandleFunc("/adoble/tracks/{track}/{id}", routes.GetExamChallenge(container)).Methods("GET")
+	authR.HandleFunc("/adoble/tracks/{track}/{id}/submission", routes.SubmitExamChallenge(container)).Methods("POST")
 	authR.HandleFunc("/adoble/{id}", routes.GetExamChallenge(container)).Methods("GET")
 	authR.HandleFunc("/adoble/{id}/submission", routes.SubmitExamChallenge(container)).Methods("POST")
 
+	// Author routes, for previewing challenges before they are published
+	authorR := authR.PathPrefix("/author").Subrouter()
+	authorR.Use(middleware.RequireRole(container, services.RoleAuthor, services.RoleAdmin))
+
+	authorR.HandleFunc("/challenges/{id}/preview", routes.AuthorPreviewChallenge(container)).Methods("GET")
+
+	// Admin routes, readable by observers and writable by admins only
+	adminR := authR.PathPrefix("/admin").Subrouter()
+	adminR.Use(middleware.RequireRole(container, services.RoleAdmin, services.RoleObserver))
+	adminOnly := middleware.RequireRole(container, services.RoleAdmin)
+
+	adminR.HandleFunc("/validation-handlers", routes.AdminListValidationHandlers(container)).Methods("GET")
+	adminR.HandleFunc("/artifact-generators", routes.AdminListArtifactGenerators(container)).Methods("GET")
+	adminR.HandleFunc("/challenges", routes.AdminListChallenges(container)).Methods("GET")
+	adminR.Handle("/challenges", adminOnly(routes.AdminCreateChallenge(container))).Methods("POST")
+	adminR.HandleFunc("/challenges/{id}", routes.AdminGetChallenge(container)).Methods("GET")
+	adminR.Handle("/challenges/{id}", adminOnly(routes.AdminUpdateChallenge(container))).Methods("PUT")
+	adminR.Handle("/challenges/{id}", adminOnly(routes.AdminDeleteChallenge(container))).Methods("DELETE")
+	adminR.Handle("/challenges/{id}/hidden", adminOnly(routes.AdminSetChallengeHidden(container))).Methods("PUT")
+	adminR.HandleFunc("/challenges/{id}/attachments", routes.AdminListAttachments(container)).Methods("GET")
+	adminR.Handle("/challenges/{id}/attachments/{filename}", adminOnly(routes.AdminPutAttachment(container))).Methods("PUT")
+	adminR.Handle("/challenges/{id}/attachments/{filename}", adminOnly(routes.AdminDeleteAttachment(container))).Methods("DELETE")
+	adminR.HandleFunc("/challenges/{id}/hints", routes.AdminListHints(container)).Methods("GET")
+	adminR.Handle("/challenges/{id}/hints", adminOnly(routes.AdminCreateHint(container))).Methods("POST")
+	adminR.Handle("/challenges/{id}/hints/{hintId}", adminOnly(routes.AdminDeleteHint(container))).Methods("DELETE")
+	adminR.HandleFunc("/hint-unlocks", routes.AdminListHintUnlocks(container)).Methods("GET")
+	adminR.HandleFunc("/cheating-incidents", routes.AdminListCheatingIncidents(container)).Methods("GET")
+	adminR.HandleFunc("/submissions", routes.AdminListSubmissions(container)).Methods("GET")
+	adminR.Handle("/competition/paused", adminOnly(routes.AdminSetCompetitionPaused(container))).Methods("PUT")
+	adminR.Handle("/competition/reveal", adminOnly(routes.AdminRevealScoreboard(container))).Methods("POST")
+	adminR.HandleFunc("/exam-tracks", routes.AdminListExamTracks(container)).Methods("GET")
+	adminR.Handle("/exam-tracks/{track}", adminOnly(routes.AdminPutExamTrack(container))).Methods("PUT")
+	adminR.Handle("/exam-tracks/{track}", adminOnly(routes.AdminDeleteExamTrack(container))).Methods("DELETE")
+	adminR.HandleFunc("/token-ledger", routes.AdminListTokenLedger(container)).Methods("GET")
+	adminR.HandleFunc("/token-ledger/reconcile", routes.AdminReconcileTokenLedger(container)).Methods("GET")
+	adminR.Handle("/token-grants", adminOnly(routes.AdminGrantTokens(container))).Methods("POST")
+	adminR.Handle("/token-refunds", adminOnly(routes.AdminRefundTokens(container))).Methods("POST")
+
 	// Serve index.html for all other routes (SPA fallback)
 	r.PathPrefix("/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "./frontend-simple/index.html")
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/obelisk/example-ctf/migrations"
+)
+
+// runMigrate handles the migrate subcommand: migrate [up | down [steps] | status]
+func runMigrate(db *sql.DB, args []string) error {
+	ctx := context.Background()
+
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			log.Infof("applied migration %04d_%s", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			log.Info("schema is up to date")
+		}
+		return nil
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return fmt.Errorf("invalid number of steps: %q", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, migration := range reverted {
+			log.Infof("reverted migration %04d_%s", migration.Version, migration.Name)
+		}
+		return err
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			if status.AppliedAt != nil {
+				fmt.Printf("%04d_%s\tapplied %s\n", status.Version, status.Name, status.AppliedAt.Format("2006-01-02 15:04:05"))
+			} else {
+				fmt.Printf("%04d_%s\tpending\n", status.Version, status.Name)
+			}
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", command)
+	}
+}
+
+// autoMigrate applies pending migrations on startup
+func autoMigrate(db *sql.DB) error {
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	applied, err := migrator.Up(context.Background())
+	for _, migration := range applied {
+		log.Infof("applied migration %04d_%s", migration.Version, migration.Name)
+	}
+	return err
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/spf13/viper"
 )
 
@@ -20,6 +21,10 @@ type Config struct {
 	Database    DatabaseConfig    `validate:"required"`
 	AwsConfig   AwsConfig         `validate:"required"`
 	Slack       SlackConfig       `validate:"required"`
+	Flags       FlagsConfig
+	Competition CompetitionConfig
+	Teams       TeamsConfig
+	Exam        ExamConfig
 }
 
 // HTTPConfig stores configuration for the public facing HTTP server.
@@ -27,9 +32,11 @@ type HTTPConfig struct {
 	Hostname string
 	Port     uint16 `validate:"required"`
 
-	Timeout               time.Duration   `validate:"required"`
-	RequestSizeLimitBytes uint64          `validate:"required"`
-	RateLimit             RateLimitConfig `validate:"required"`
+	Timeout                    time.Duration   `validate:"required"`
+	RequestSizeLimitBytes      uint64          `validate:"required"`
+	AdminRequestSizeLimitBytes uint64          `yaml:"adminRequestSizeLimitBytes,omitempty"`
+	AttachmentSizeLimitBytes   uint64          `yaml:"attachmentSizeLimitBytes,omitempty"`
+	RateLimit                  RateLimitConfig `validate:"required"`
 }
 
 // HealthCheckConfig stores configuration for the health check endpoint
@@ -56,6 +63,27 @@ type AuthConfig struct {
 	ExpectedIssuer                    string        `validate:"required"`
 	AWSRegion                         string        `validate:"required"`
 	PublicKeyCacheTTL                 time.Duration `yaml:"publicKeyCacheTTL,omitempty"`
+
+	Roles RolesConfig `yaml:"roles,omitempty"`
+}
+
+// RolesConfig stores how users are granted roles beyond player
+type RolesConfig struct {
+	// Admins, Authors and Observers list the emails granted each role
+	Admins    []string `yaml:"admins,omitempty" validate:"dive,email"`
+	Authors   []string `yaml:"authors,omitempty" validate:"dive,email"`
+	Observers []string `yaml:"observers,omitempty" validate:"dive,email"`
+
+	// GroupsClaim is the JWT claim holding the user's identity provider groups
+	GroupsClaim string `yaml:"groupsClaim,omitempty"`
+	// GroupRoles grants roles to members of identity provider groups
+	GroupRoles []GroupRoleConfig `yaml:"groupRoles,omitempty" validate:"dive"`
+}
+
+// GroupRoleConfig maps an identity provider group to a role
+type GroupRoleConfig struct {
+	Group string `validate:"required"`
+	Role  string `validate:"required,oneof=player author admin observer"`
 }
 
 // AuthTestMode stores test mode authentication configuration
@@ -72,16 +100,95 @@ type DatabaseConfig struct {
 	Password string
 	Database string `validate:"required"`
 	SslMode  string `validate:"required,oneof=disable allow prefer require verify-ca verify-full"`
+	// AutoMigrate applies pending schema migrations on startup
+	AutoMigrate bool `yaml:"autoMigrate,omitempty"`
+}
+
+// ConnectionString builds the Postgres connection string for the database
+func (d DatabaseConfig) ConnectionString() string {
+	return fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=%s",
+		d.User,
+		d.Password,
+		d.Hostname,
+		d.Port,
+		d.Database,
+		d.SslMode,
+	)
 }
 
-// AwsConfig stores config to access S3
+// AwsConfig stores config for the asset store holding challenge files
 type AwsConfig struct {
-	BucketName string `validate:"required"`
+	// Backend selects the asset store: s3 (default), minio or local
+	Backend    string `yaml:"backend,omitempty" validate:"oneof=s3 minio local"`
+	BucketName string `validate:"required_unless=Backend local"`
+	// Endpoint is the URL of the S3 compatible server used by the minio backend
+	Endpoint string `yaml:"endpoint,omitempty" validate:"required_if=Backend minio,omitempty,url"`
+	// Region overrides the AWS region for the s3 and minio backends
+	Region string `yaml:"region,omitempty"`
+	// LocalDir is the directory the local backend serves assets from
+	LocalDir string `yaml:"localDir,omitempty" validate:"required_if=Backend local"`
+	// SigningSecret signs local asset URLs, loaded from the ASSET_SIGNING_SECRET env var
+	SigningSecret string `yaml:"signingSecret,omitempty"`
 }
 
 // SlackConfig stores configuration for Slack integration
 type SlackConfig struct {
 	LeaderboardInterval time.Duration `yaml:"leaderboardInterval,omitempty"`
+	// ReleaseCheckInterval is how often scheduled challenge releases are checked for announcement
+	ReleaseCheckInterval time.Duration `yaml:"releaseCheckInterval,omitempty"`
+}
+
+// FlagsConfig stores configuration for flag validation
+type FlagsConfig struct {
+	// HMACSecret derives per-user flags, loaded from the FLAG_HMAC_SECRET env var
+	HMACSecret string `yaml:"hmacSecret,omitempty"`
+}
+
+// TeamsConfig stores configuration for team play
+type TeamsConfig struct {
+	// Enabled turns on team creation, shared team progress and pooled exam tokens
+	Enabled bool `yaml:"enabled,omitempty"`
+	// MaxMembers caps the size of a team
+	MaxMembers int `yaml:"maxMembers,omitempty" validate:"gte=0"`
+}
+
+// ExamConfig stores configuration for the exam
+type ExamConfig struct {
+	// SessionDuration is how long each user has after starting the exam. Zero disables timed sessions.
+	SessionDuration time.Duration `yaml:"sessionDuration,omitempty" validate:"gte=0"`
+}
+
+// CompetitionConfig schedules the competition. Unset times leave that bound open.
+type CompetitionConfig struct {
+	// StartsAt and EndsAt bound when flag submissions and hint unlocks are accepted
+	StartsAt time.Time `yaml:"startsAt,omitempty"`
+	EndsAt   time.Time `yaml:"endsAt,omitempty"`
+	// FreezeAt hides leaderboard changes made after it until an admin reveals the scoreboard
+	FreezeAt time.Time `yaml:"freezeAt,omitempty"`
+}
+
+// HasStarted reports whether the competition window has opened
+func (c CompetitionConfig) HasStarted(now time.Time) bool {
+	return c.StartsAt.IsZero() || !now.Before(c.StartsAt)
+}
+
+// HasEnded reports whether the competition window has closed
+func (c CompetitionConfig) HasEnded(now time.Time) bool {
+	return !c.EndsAt.IsZero() && !now.Before(c.EndsAt)
+}
+
+// validate checks that the competition times are in order
+func (c CompetitionConfig) validate() error {
+	if !c.StartsAt.IsZero() && !c.EndsAt.IsZero() && !c.EndsAt.After(c.StartsAt) {
+		return fmt.Errorf("competition endsAt must be after startsAt")
+	}
+	if !c.FreezeAt.IsZero() && !c.StartsAt.IsZero() && c.FreezeAt.Before(c.StartsAt) {
+		return fmt.Errorf("competition freezeAt must not be before startsAt")
+	}
+	if !c.FreezeAt.IsZero() && !c.EndsAt.IsZero() && c.FreezeAt.After(c.EndsAt) {
+		return fmt.Errorf("competition freezeAt must not be after endsAt")
+	}
+	return nil
 }
 
 // GetConfig loads and returns the application configuration
@@ -115,22 +222,50 @@ func GetConfig() (Config, error) {
 			return c, err
 		}
 	}
-	if err := viper.Unmarshal(&c); err != nil {
+	// Times such as competition.startsAt are written as RFC 3339 strings
+	if err := viper.Unmarshal(&c, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		mapstructure.StringToTimeHookFunc(time.RFC3339),
+	))); err != nil {
 		return c, err
 	}
 
 	// Apply env vars to the config if set.
 	setValueFromEnvVar("POSTGRES_PASSWORD", &c.Database.Password)
+	setValueFromEnvVar("FLAG_HMAC_SECRET", &c.Flags.HMACSecret)
+	setValueFromEnvVar("ASSET_SIGNING_SECRET", &c.AwsConfig.SigningSecret)
 
 	// Set default values for optional fields
+	if c.AwsConfig.Backend == "" {
+		c.AwsConfig.Backend = "s3"
+	}
+	if c.HTTP.AdminRequestSizeLimitBytes == 0 {
+		c.HTTP.AdminRequestSizeLimitBytes = 64 * 1024
+	}
+	if c.HTTP.AttachmentSizeLimitBytes == 0 {
+		c.HTTP.AttachmentSizeLimitBytes = 64 * 1024 * 1024
+	}
+	if c.Auth.Roles.GroupsClaim == "" {
+		c.Auth.Roles.GroupsClaim = "groups"
+	}
 	if c.Slack.LeaderboardInterval == 0 {
 		c.Slack.LeaderboardInterval = 30 * time.Minute
 	}
+	if c.Slack.ReleaseCheckInterval == 0 {
+		c.Slack.ReleaseCheckInterval = time.Minute
+	}
+	if c.Teams.MaxMembers == 0 {
+		c.Teams.MaxMembers = 4
+	}
 
 	// Validate configuration.
 	if err := validator.New().Struct(c); err != nil {
 		return c, fmt.Errorf("configuration file failed validation: %w", err)
 	}
+	if err := c.Competition.validate(); err != nil {
+		return c, fmt.Errorf("configuration file failed validation: %w", err)
+	}
 	return c, nil
 }
 
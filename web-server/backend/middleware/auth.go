@@ -10,6 +10,7 @@ import (
 )
 
 const unauthorized = "Unauthorized"
+const forbidden = "Forbidden"
 
 // LoadAuthenticatedUser loads the authenticated user into the request context
 func LoadAuthenticatedUser(container *services.Container) func(http.Handler) http.Handler {
@@ -21,7 +22,11 @@ func LoadAuthenticatedUser(container *services.Container) func(http.Handler) htt
 			// If test mode is enabled, set the user context to the test email
 			if container.Config.Auth.TestMode != nil && container.Config.Auth.TestMode.Enabled && container.Config.Auth.TestMode.TestUser != "" {
 				ctx = container.Auth.SetAuthenticatedFlag(ctx, true)
-				ctx = container.Auth.SetUserContext(ctx, &services.User{Email: container.Config.Auth.TestMode.TestUser})
+				testUser := container.Config.Auth.TestMode.TestUser
+				ctx = container.Auth.SetUserContext(ctx, &services.User{
+					Email: testUser,
+					Roles: container.Auth.ResolveRoles(testUser, nil),
+				})
 				log.Infoln("test mode: user authenticated")
 				next.ServeHTTP(w, r.WithContext(ctx))
 				return
@@ -69,6 +74,35 @@ func RequireAuthenticated(container *services.Container) func(http.Handler) http
 	}
 }
 
+// RequireRole middleware ensures the authenticated user has at least one of the given roles
+func RequireRole(container *services.Container, roles ...services.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			log := services.GetLogger(ctx)
+
+			user, ok := container.Auth.GetUserFromContext(ctx)
+			if !ok {
+				log.Errorf("unauthorized access attempt to role restricted route")
+				utility.SendJSONError(w, unauthorized, http.StatusUnauthorized)
+				return
+			}
+
+			if !user.HasRole(roles...) {
+				log.WithFields(logrus.Fields{
+					"required_roles": roles,
+					"user_roles":     user.Roles,
+				}).Errorf("forbidden access attempt to role restricted route")
+				utility.SendJSONError(w, forbidden, http.StatusForbidden)
+				return
+			}
+
+			// Call the next middleware function or final handler
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // RequireUnauthenticated middleware ensures the user is not authenticated
 func RequireUnauthenticated(container *services.Container) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
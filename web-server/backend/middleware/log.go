@@ -12,18 +12,21 @@ import (
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		requestID := uuid.New().String()
+		clientIP := getClientIP(r)
 
 		// Create logger entry with request context
 		logger := log.NewEntry(log.StandardLogger()).WithFields(log.Fields{
 			"request_id": requestID,
 			"method":     r.Method,
 			"path":       r.URL.Path,
-			"remote_ip":  getClientIP(r),
+			"remote_ip":  clientIP,
 		})
 
 		// Ensure the logger respects the global log level
 		logger.Logger.SetLevel(log.GetLevel())
 		ctx := context.WithValue(r.Context(), services.LoggerContextKey, logger)
+		ctx = context.WithValue(ctx, services.RequestIDContextKey, requestID)
+		ctx = context.WithValue(ctx, services.ClientIPContextKey, clientIP)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -3,6 +3,7 @@ package middleware
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/obelisk/example-ctf/services"
 	"github.com/obelisk/example-ctf/utility"
@@ -11,6 +12,9 @@ import (
 
 const (
 	requestTooLarge = "Request too large"
+
+	// adminPathPrefix identifies admin API requests, which may carry larger bodies
+	adminPathPrefix = "/api/admin/"
 )
 
 // SecurityHeadersMiddleware adds security headers to all responses
@@ -48,6 +52,15 @@ func RequestSizeLimitMiddleware(container *services.Container) func(http.Handler
 			ctx := r.Context()
 			log := services.GetLogger(ctx)
 
+			// Admin requests carry full challenge definitions, and attachment uploads whole files
+			limit := container.Config.HTTP.RequestSizeLimitBytes
+			if strings.HasPrefix(r.URL.Path, adminPathPrefix) {
+				limit = container.Config.HTTP.AdminRequestSizeLimitBytes
+				if isAttachmentUpload(r) {
+					limit = container.Config.HTTP.AttachmentSizeLimitBytes
+				}
+			}
+
 			// Check Content-Length header if present
 			if contentLength := r.Header.Get("Content-Length"); contentLength != "" {
 				size, err := strconv.ParseUint(contentLength, 10, 64)
@@ -59,7 +72,7 @@ func RequestSizeLimitMiddleware(container *services.Container) func(http.Handler
 					return
 				}
 
-				if size > container.Config.HTTP.RequestSizeLimitBytes {
+				if size > limit {
 					log.WithFields(logrus.Fields{
 						"content_length": size,
 					}).Info("request size limit exceeded")
@@ -69,7 +82,7 @@ func RequestSizeLimitMiddleware(container *services.Container) func(http.Handler
 			}
 
 			// Wrap the request body with a size-limited reader
-			limitedBody := http.MaxBytesReader(w, r.Body, int64(container.Config.HTTP.RequestSizeLimitBytes))
+			limitedBody := http.MaxBytesReader(w, r.Body, int64(limit))
 			r.Body = limitedBody
 
 			// Call the next handler
@@ -77,3 +90,10 @@ func RequestSizeLimitMiddleware(container *services.Container) func(http.Handler
 		})
 	}
 }
+
+// isAttachmentUpload reports whether an admin request uploads a challenge attachment
+func isAttachmentUpload(r *http.Request) bool {
+	return r.Method == http.MethodPut &&
+		strings.HasPrefix(r.URL.Path, adminPathPrefix+"challenges/") &&
+		strings.Contains(r.URL.Path, "/attachments/")
+}
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS user_aliases;
+DROP TABLE IF EXISTS user_history_log;
+DROP TABLE IF EXISTS user_challenges_completed;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS flags;
+DROP TABLE IF EXISTS challenges;
@@ -1,3 +1,6 @@
+-- Baseline schema, as applied by hand before versioned migrations.
+-- Every statement is idempotent so existing databases can adopt it.
+
 -- Create challenges table
 CREATE TABLE IF NOT EXISTS challenges (
     id                      INTEGER PRIMARY KEY,
@@ -66,4 +69,4 @@ CREATE INDEX IF NOT EXISTS idx_challenges_nested_id_category ON challenges(neste
 CREATE INDEX IF NOT EXISTS idx_user_challenges_completed_user_email ON user_challenges_completed(user_email);
 CREATE INDEX IF NOT EXISTS idx_user_challenges_completed_challenge ON user_challenges_completed(challenge_id);
 CREATE INDEX IF NOT EXISTS idx_user_challenges_completed_completed_at ON user_challenges_completed(completed_at);
-CREATE INDEX IF NOT EXISTS idx_user_history_log_user_email ON user_history_log(user_email);
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_user_history_log_user_email ON user_history_log(user_email);
@@ -0,0 +1,2 @@
+ALTER TABLE flags DROP COLUMN IF EXISTS validation_params;
+ALTER TABLE challenges DROP COLUMN IF EXISTS hidden;
@@ -0,0 +1,2 @@
+ALTER TABLE challenges ADD COLUMN IF NOT EXISTS hidden BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE flags ADD COLUMN IF NOT EXISTS validation_params JSONB NOT NULL DEFAULT '{}';
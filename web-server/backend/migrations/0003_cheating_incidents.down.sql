@@ -0,0 +1 @@
+DROP TABLE IF EXISTS cheating_incidents;
@@ -0,0 +1,12 @@
+-- Create cheating_incidents table to record submissions of another user's answer
+CREATE TABLE IF NOT EXISTS cheating_incidents (
+    id            SERIAL    PRIMARY KEY,
+    user_email    TEXT      NOT NULL,
+    owner_email   TEXT      NOT NULL,
+    challenge_id  INTEGER   NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+    kind          TEXT      NOT NULL,
+    created_at    TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_cheating_incidents_user_email ON cheating_incidents(user_email);
+CREATE INDEX IF NOT EXISTS idx_cheating_incidents_created_at ON cheating_incidents(created_at);
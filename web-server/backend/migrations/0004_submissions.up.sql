@@ -0,0 +1,44 @@
+-- Create submissions table recording every flag submission
+-- Only a SHA-256 of the submitted value is stored
+CREATE TABLE IF NOT EXISTS submissions (
+    id              BIGSERIAL PRIMARY KEY,
+    user_email      TEXT      NOT NULL,
+    challenge_id    INTEGER   NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+    submitted_hash  TEXT      NOT NULL,
+    result          TEXT      NOT NULL CHECK (result IN ('correct', 'incorrect')),
+    tokens_burned   INTEGER   NOT NULL DEFAULT 0,
+    ip_address      TEXT,
+    request_id      TEXT,
+    created_at      TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Backfill submissions from completions and logged wrong attempts, once, for
+-- databases created before the submissions table. The submitted value of a
+-- completion was never stored, so its hash is left empty. Wrong exam attempts
+-- were logged by nested ID and each burned 1 token.
+INSERT INTO submissions (user_email, challenge_id, submitted_hash, result, tokens_burned, created_at)
+SELECT backfill.user_email, backfill.challenge_id, backfill.submitted_hash, backfill.result, backfill.tokens_burned, backfill.created_at
+FROM (
+    SELECT ucc.user_email, ucc.challenge_id, '' AS submitted_hash, 'correct' AS result,
+           CASE WHEN c.category = 'exam' THEN 1 ELSE 0 END AS tokens_burned, ucc.completed_at AS created_at
+    FROM user_challenges_completed ucc
+    JOIN challenges c ON c.id = ucc.challenge_id
+    UNION ALL
+    SELECT h.user_email, c.id, encode(sha256(convert_to(substring(h.log FROM '^Wrong flag attempt for challenge \d+: (.*)$'), 'UTF8')), 'hex'),
+           'incorrect', 0, h.date
+    FROM user_history_log h
+    JOIN challenges c ON c.id = substring(h.log FROM '^Wrong flag attempt for challenge (\d+): ')::INTEGER
+    WHERE h.log ~ '^Wrong flag attempt for challenge \d+: '
+    UNION ALL
+    SELECT h.user_email, c.id, encode(sha256(convert_to(substring(h.log FROM '^Wrong flag attempt for exam challenge \d+: (.*)$'), 'UTF8')), 'hex'),
+           'incorrect', 1, h.date
+    FROM user_history_log h
+    JOIN challenges c ON c.category = 'exam'
+                     AND c.nested_id = substring(h.log FROM '^Wrong flag attempt for exam challenge (\d+): ')::INTEGER
+    WHERE h.log ~ '^Wrong flag attempt for exam challenge \d+: '
+) AS backfill
+WHERE NOT EXISTS (SELECT 1 FROM submissions);
+
+CREATE INDEX IF NOT EXISTS idx_submissions_user_email ON submissions(user_email);
+CREATE INDEX IF NOT EXISTS idx_submissions_challenge_id ON submissions(challenge_id);
+CREATE INDEX IF NOT EXISTS idx_submissions_created_at ON submissions(created_at);
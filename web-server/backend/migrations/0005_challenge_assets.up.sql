@@ -0,0 +1,14 @@
+-- Create challenge_assets table for files attached to a challenge
+CREATE TABLE IF NOT EXISTS challenge_assets (
+    id            SERIAL    PRIMARY KEY,
+    challenge_id  INTEGER   NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+    filename      TEXT      NOT NULL,
+    asset_path    TEXT      NOT NULL,
+    size_bytes    BIGINT    NOT NULL,
+    sha256        TEXT      NOT NULL,
+    content_type  TEXT      NOT NULL,
+    created_at    TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (challenge_id, filename)
+);
+
+CREATE INDEX IF NOT EXISTS idx_challenge_assets_challenge_id ON challenge_assets(challenge_id);
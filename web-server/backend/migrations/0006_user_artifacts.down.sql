@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS user_artifacts;
+ALTER TABLE challenges DROP COLUMN IF EXISTS artifact_params;
+ALTER TABLE challenges DROP COLUMN IF EXISTS artifact_generator;
@@ -0,0 +1,18 @@
+-- Per-user artifact generator for a challenge, empty when the challenge has none
+ALTER TABLE challenges ADD COLUMN IF NOT EXISTS artifact_generator TEXT NOT NULL DEFAULT '';
+ALTER TABLE challenges ADD COLUMN IF NOT EXISTS artifact_params JSONB NOT NULL DEFAULT '{}';
+
+-- Create user_artifacts table caching each user's generated artifact
+CREATE TABLE IF NOT EXISTS user_artifacts (
+    challenge_id  INTEGER   NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+    user_email    TEXT      NOT NULL,
+    fingerprint   TEXT      NOT NULL,
+    text_asset    TEXT,
+    filename      TEXT,
+    asset_path    TEXT,
+    size_bytes    BIGINT,
+    sha256        TEXT,
+    content_type  TEXT,
+    created_at    TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (challenge_id, user_email)
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS hint_unlocks;
+DROP TABLE IF EXISTS hints;
@@ -0,0 +1,26 @@
+-- Create hints table; each hint costs tokens or points to unlock
+CREATE TABLE IF NOT EXISTS hints (
+    id            SERIAL    PRIMARY KEY,
+    challenge_id  INTEGER   NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+    position      INTEGER   NOT NULL DEFAULT 0,
+    text          TEXT      NOT NULL,
+    cost          INTEGER   NOT NULL CHECK (cost >= 0),
+    cost_type     TEXT      NOT NULL CHECK (cost_type IN ('tokens', 'points')),
+    created_at    TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_hints_challenge_id ON hints(challenge_id);
+
+-- Create hint_unlocks table recording which users paid for which hints
+-- The cost is copied so later changes to a hint don't rewrite history
+CREATE TABLE IF NOT EXISTS hint_unlocks (
+    hint_id       INTEGER   NOT NULL REFERENCES hints(id) ON DELETE CASCADE,
+    user_email    TEXT      NOT NULL,
+    challenge_id  INTEGER   NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+    cost          INTEGER   NOT NULL,
+    cost_type     TEXT      NOT NULL,
+    unlocked_at   TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (hint_id, user_email)
+);
+
+CREATE INDEX IF NOT EXISTS idx_hint_unlocks_user_email ON hint_unlocks(user_email);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS challenge_prerequisites;
+DROP TABLE IF EXISTS challenge_prerequisite_groups;
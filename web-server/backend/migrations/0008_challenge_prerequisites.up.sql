@@ -0,0 +1,18 @@
+-- Create prerequisite tables for regular challenges. A challenge is unlocked once,
+-- for every one of its groups, the user solved at least `required` of the group's challenges.
+CREATE TABLE IF NOT EXISTS challenge_prerequisite_groups (
+    id            SERIAL  PRIMARY KEY,
+    challenge_id  INTEGER NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+    required      INTEGER NOT NULL CHECK (required >= 1)
+);
+
+CREATE INDEX IF NOT EXISTS idx_challenge_prerequisite_groups_challenge_id ON challenge_prerequisite_groups(challenge_id);
+
+-- A challenge cannot be deleted while another challenge requires it
+CREATE TABLE IF NOT EXISTS challenge_prerequisites (
+    group_id         INTEGER NOT NULL REFERENCES challenge_prerequisite_groups(id) ON DELETE CASCADE,
+    prerequisite_id  INTEGER NOT NULL REFERENCES challenges(id),
+    PRIMARY KEY (group_id, prerequisite_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_challenge_prerequisites_prerequisite_id ON challenge_prerequisites(prerequisite_id);
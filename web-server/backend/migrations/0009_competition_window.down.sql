@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS scoreboard_snapshot;
+DROP TABLE IF EXISTS competition_state;
+ALTER TABLE challenges DROP COLUMN IF EXISTS release_at;
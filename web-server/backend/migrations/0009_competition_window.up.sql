@@ -0,0 +1,22 @@
+-- Optional per-challenge release time; NULL means the challenge is released with the competition
+ALTER TABLE challenges ADD COLUMN IF NOT EXISTS release_at TIMESTAMP;
+
+-- Singleton row holding the admin controlled competition state
+CREATE TABLE IF NOT EXISTS competition_state (
+    id                      BOOLEAN   PRIMARY KEY DEFAULT TRUE CHECK (id),
+    paused                  BOOLEAN   NOT NULL DEFAULT FALSE,
+    paused_at               TIMESTAMP,
+    scoreboard_frozen_at    TIMESTAMP,
+    scoreboard_revealed_at  TIMESTAMP
+);
+
+INSERT INTO competition_state (id) VALUES (TRUE) ON CONFLICT (id) DO NOTHING;
+
+-- Standings captured when the scoreboard freezes, shown until it is revealed
+CREATE TABLE IF NOT EXISTS scoreboard_snapshot (
+    user_email                            TEXT      PRIMARY KEY,
+    points_achieved                       INTEGER   NOT NULL,
+    exam_challenges_solved                INTEGER   NOT NULL,
+    last_exam_challenge_solved_timestamp  TIMESTAMP,
+    last_challenge_solved_timestamp       TIMESTAMP
+);
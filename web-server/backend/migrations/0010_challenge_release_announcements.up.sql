@@ -0,0 +1,7 @@
+-- Set once a challenge's release has been claimed for a Slack announcement, so
+-- only one replica announces it. Challenges already released are not announced.
+ALTER TABLE challenges ADD COLUMN IF NOT EXISTS release_announced_at TIMESTAMP;
+
+UPDATE challenges
+SET release_announced_at = NOW()
+WHERE release_announced_at IS NULL AND (release_at IS NULL OR release_at <= NOW());
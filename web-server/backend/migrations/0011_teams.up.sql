@@ -0,0 +1,27 @@
+-- Optional teams. A user belongs to at most one team.
+CREATE TABLE IF NOT EXISTS teams (
+    id           SERIAL    PRIMARY KEY,
+    name         TEXT      NOT NULL,
+    invite_code  TEXT      NOT NULL UNIQUE,
+    created_at   TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_teams_name ON teams(LOWER(name));
+
+CREATE TABLE IF NOT EXISTS team_members (
+    user_email  TEXT      PRIMARY KEY,
+    team_id     INTEGER   NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    joined_at   TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_team_members_team_id ON team_members(team_id);
+
+-- The first solve of a challenge by any member, with the points it earned the team
+CREATE TABLE IF NOT EXISTS team_challenges_completed (
+    team_id       INTEGER   NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    challenge_id  INTEGER   NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+    user_email    TEXT      NOT NULL,
+    points        INTEGER   NOT NULL,
+    completed_at  TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (team_id, challenge_id)
+);
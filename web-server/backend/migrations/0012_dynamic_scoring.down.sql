@@ -0,0 +1,7 @@
+ALTER TABLE users DROP COLUMN IF EXISTS points_adjustment;
+ALTER TABLE user_challenges_completed DROP COLUMN IF EXISTS points, DROP COLUMN IF EXISTS score;
+ALTER TABLE challenges
+    DROP COLUMN IF EXISTS decay,
+    DROP COLUMN IF EXISTS minimum_points,
+    DROP COLUMN IF EXISTS initial_points,
+    DROP COLUMN IF EXISTS scoring;
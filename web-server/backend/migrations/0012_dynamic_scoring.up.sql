@@ -0,0 +1,26 @@
+-- Scoring mode of a challenge. Dynamic challenges decay from initial_points to
+-- minimum_points as solves increase; point_reward_amount holds the current value.
+ALTER TABLE challenges
+    ADD COLUMN IF NOT EXISTS scoring         TEXT    NOT NULL DEFAULT 'static' CHECK (scoring IN ('static', 'linear', 'logarithmic')),
+    ADD COLUMN IF NOT EXISTS initial_points  INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS minimum_points  INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS decay           INTEGER NOT NULL DEFAULT 0;
+
+-- score is the fraction of the reward the submitted flag earned; points is what the completion is worth now
+ALTER TABLE user_challenges_completed
+    ADD COLUMN IF NOT EXISTS score   DOUBLE PRECISION NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS points  INTEGER          NOT NULL DEFAULT 0;
+
+-- Points not explained by completions or hint unlocks, such as those kept from deleted challenges
+ALTER TABLE users ADD COLUMN IF NOT EXISTS points_adjustment INTEGER NOT NULL DEFAULT 0;
+
+UPDATE user_challenges_completed ucc
+SET points = c.point_reward_amount
+FROM challenges c
+WHERE c.id = ucc.challenge_id AND c.category != 'exam';
+
+-- Keep every existing total: whatever the completions and hint unlocks don't explain becomes the adjustment
+UPDATE users u
+SET points_adjustment = u.points_achieved
+    - COALESCE((SELECT SUM(ucc.points) FROM user_challenges_completed ucc WHERE ucc.user_email = u.user_email), 0)
+    + COALESCE((SELECT SUM(hu.cost) FROM hint_unlocks hu WHERE hu.user_email = u.user_email AND hu.cost_type = 'points'), 0);
@@ -0,0 +1,8 @@
+DROP VIEW IF EXISTS user_standings;
+ALTER TABLE scoreboard_snapshot
+    DROP COLUMN IF EXISTS third_bloods,
+    DROP COLUMN IF EXISTS second_bloods,
+    DROP COLUMN IF EXISTS first_bloods;
+DROP INDEX IF EXISTS idx_user_challenges_completed_solve_position;
+ALTER TABLE user_challenges_completed DROP COLUMN IF EXISTS bonus_points, DROP COLUMN IF EXISTS solve_position;
+ALTER TABLE challenges DROP COLUMN IF EXISTS blood_bonuses;
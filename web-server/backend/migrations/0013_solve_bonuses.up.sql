@@ -0,0 +1,40 @@
+-- Bonus points for the first, second and third solves of a challenge, in that order
+ALTER TABLE challenges ADD COLUMN IF NOT EXISTS blood_bonuses INTEGER[] NOT NULL DEFAULT '{}';
+
+-- solve_position is the completion's place in the challenge's solve order (regular challenges only)
+ALTER TABLE user_challenges_completed
+    ADD COLUMN IF NOT EXISTS solve_position  INTEGER,
+    ADD COLUMN IF NOT EXISTS bonus_points    INTEGER NOT NULL DEFAULT 0;
+
+UPDATE user_challenges_completed ucc
+SET solve_position = ordered.position
+FROM (
+    SELECT ucc.user_email, ucc.challenge_id,
+           ROW_NUMBER() OVER (PARTITION BY ucc.challenge_id ORDER BY ucc.completed_at, ucc.user_email) AS position
+    FROM user_challenges_completed ucc
+    JOIN challenges c ON c.id = ucc.challenge_id
+    WHERE c.category != 'exam'
+) ordered
+WHERE ucc.user_email = ordered.user_email
+  AND ucc.challenge_id = ordered.challenge_id
+  AND ucc.solve_position IS NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_challenges_completed_solve_position
+    ON user_challenges_completed (challenge_id, solve_position);
+
+-- Frozen standings keep the blood counts from the freeze
+ALTER TABLE scoreboard_snapshot
+    ADD COLUMN IF NOT EXISTS first_bloods   INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS second_bloods  INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS third_bloods   INTEGER NOT NULL DEFAULT 0;
+
+-- Live standings with the same columns as scoreboard_snapshot
+CREATE OR REPLACE VIEW user_standings AS
+SELECT u.user_email, u.points_achieved, u.exam_challenges_solved,
+       u.last_exam_challenge_solved_timestamp, u.last_challenge_solved_timestamp,
+       (COUNT(*) FILTER (WHERE ucc.solve_position = 1))::INTEGER AS first_bloods,
+       (COUNT(*) FILTER (WHERE ucc.solve_position = 2))::INTEGER AS second_bloods,
+       (COUNT(*) FILTER (WHERE ucc.solve_position = 3))::INTEGER AS third_bloods
+FROM users u
+LEFT JOIN user_challenges_completed ucc ON ucc.user_email = u.user_email
+GROUP BY u.user_email;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS exam_attempts;
+ALTER TABLE challenges
+    DROP COLUMN IF EXISTS max_attempts,
+    DROP COLUMN IF EXISTS completion_reward,
+    DROP COLUMN IF EXISTS submission_cost;
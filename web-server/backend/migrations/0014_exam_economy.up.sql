@@ -0,0 +1,22 @@
+-- What an exam submission costs, what solving the exam challenge earns and how many
+-- submissions a user gets (NULL is unlimited). Regular challenges keep the defaults.
+ALTER TABLE challenges
+    ADD COLUMN IF NOT EXISTS submission_cost    INTEGER NOT NULL DEFAULT 1 CHECK (submission_cost >= 0),
+    ADD COLUMN IF NOT EXISTS completion_reward  INTEGER NOT NULL DEFAULT 1 CHECK (completion_reward >= 0),
+    ADD COLUMN IF NOT EXISTS max_attempts       INTEGER CHECK (max_attempts > 0);
+
+-- Submissions each user has made to each exam challenge, claimed together with the token burn
+CREATE TABLE IF NOT EXISTS exam_attempts (
+    user_email    TEXT    NOT NULL,
+    challenge_id  INTEGER NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+    attempts      INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_email, challenge_id)
+);
+
+INSERT INTO exam_attempts (user_email, challenge_id, attempts)
+SELECT s.user_email, s.challenge_id, COUNT(*)
+FROM submissions s
+JOIN challenges c ON c.id = s.challenge_id
+WHERE c.category = 'exam'
+GROUP BY s.user_email, s.challenge_id
+ON CONFLICT (user_email, challenge_id) DO NOTHING;
@@ -0,0 +1,13 @@
+-- A view cannot drop columns in place
+DROP VIEW IF EXISTS user_standings;
+CREATE VIEW user_standings AS
+SELECT u.user_email, u.points_achieved, u.exam_challenges_solved,
+       u.last_exam_challenge_solved_timestamp, u.last_challenge_solved_timestamp,
+       (COUNT(*) FILTER (WHERE ucc.solve_position = 1))::INTEGER AS first_bloods,
+       (COUNT(*) FILTER (WHERE ucc.solve_position = 2))::INTEGER AS second_bloods,
+       (COUNT(*) FILTER (WHERE ucc.solve_position = 3))::INTEGER AS third_bloods
+FROM users u
+LEFT JOIN user_challenges_completed ucc ON ucc.user_email = u.user_email
+GROUP BY u.user_email;
+ALTER TABLE scoreboard_snapshot DROP COLUMN IF EXISTS exam_elapsed;
+DROP TABLE IF EXISTS exam_sessions;
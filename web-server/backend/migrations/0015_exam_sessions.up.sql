@@ -0,0 +1,26 @@
+-- Timed exam sessions, opened when a user starts the exam
+CREATE TABLE IF NOT EXISTS exam_sessions (
+    user_email  TEXT      PRIMARY KEY,
+    started_at  TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at  TIMESTAMP NOT NULL
+);
+
+ALTER TABLE scoreboard_snapshot ADD COLUMN IF NOT EXISTS exam_elapsed INTERVAL;
+
+-- exam_elapsed is the time from starting the exam session to the last exam solve.
+-- Solves from before the session, when sessions were enabled mid-event, don't count.
+CREATE OR REPLACE VIEW user_standings AS
+SELECT u.user_email, u.points_achieved, u.exam_challenges_solved,
+       u.last_exam_challenge_solved_timestamp, u.last_challenge_solved_timestamp,
+       (COUNT(*) FILTER (WHERE ucc.solve_position = 1))::INTEGER AS first_bloods,
+       (COUNT(*) FILTER (WHERE ucc.solve_position = 2))::INTEGER AS second_bloods,
+       (COUNT(*) FILTER (WHERE ucc.solve_position = 3))::INTEGER AS third_bloods,
+       CASE
+           WHEN u.exam_challenges_solved > 0 AND u.last_exam_challenge_solved_timestamp >= s.started_at
+               THEN u.last_exam_challenge_solved_timestamp - s.started_at
+           ELSE NULL
+       END AS exam_elapsed
+FROM users u
+LEFT JOIN exam_sessions s ON s.user_email = u.user_email
+LEFT JOIN user_challenges_completed ucc ON ucc.user_email = u.user_email
+GROUP BY u.user_email, s.started_at;
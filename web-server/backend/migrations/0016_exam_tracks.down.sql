@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS user_exam_progress;
+DROP INDEX IF EXISTS idx_challenges_exam_track_nested_id;
+ALTER TABLE challenges DROP COLUMN IF EXISTS exam_track;
+DROP TABLE IF EXISTS exam_track_requirements;
+DROP TABLE IF EXISTS exam_tracks;
@@ -0,0 +1,37 @@
+-- Exam tracks, each a sequential chain of exam challenges ordered by nested_id.
+-- Existing exam challenges and progress move to the main track.
+CREATE TABLE IF NOT EXISTS exam_tracks (
+    name        TEXT      PRIMARY KEY,
+    created_at  TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO exam_tracks (name) VALUES ('main') ON CONFLICT (name) DO NOTHING;
+
+-- A track opens once, for every requirement, the user solved required_stage stages of required_track.
+-- A track cannot be deleted while another track requires it.
+CREATE TABLE IF NOT EXISTS exam_track_requirements (
+    track           TEXT    NOT NULL REFERENCES exam_tracks(name) ON DELETE CASCADE,
+    required_track  TEXT    NOT NULL REFERENCES exam_tracks(name),
+    required_stage  INTEGER NOT NULL CHECK (required_stage >= 1),
+    PRIMARY KEY (track, required_track)
+);
+
+ALTER TABLE challenges ADD COLUMN IF NOT EXISTS exam_track TEXT REFERENCES exam_tracks(name);
+UPDATE challenges SET exam_track = 'main' WHERE category = 'exam' AND exam_track IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_challenges_exam_track_nested_id ON challenges(exam_track, nested_id);
+
+-- Stages each user solved in each track; users.exam_challenges_solved stays the total across tracks
+CREATE TABLE IF NOT EXISTS user_exam_progress (
+    user_email      TEXT      NOT NULL,
+    track           TEXT      NOT NULL REFERENCES exam_tracks(name) ON DELETE CASCADE,
+    stages_solved   INTEGER   NOT NULL DEFAULT 0,
+    last_solved_at  TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_email, track)
+);
+
+INSERT INTO user_exam_progress (user_email, track, stages_solved, last_solved_at)
+SELECT user_email, 'main', exam_challenges_solved, last_exam_challenge_solved_timestamp
+FROM users
+WHERE exam_challenges_solved > 0
+ON CONFLICT (user_email, track) DO NOTHING;
@@ -0,0 +1,10 @@
+-- Results of exam submissions keyed by the client's idempotency key, so a retried
+-- request replays the original result instead of burning tokens again
+CREATE TABLE IF NOT EXISTS exam_submission_keys (
+    user_email       TEXT      NOT NULL,
+    idempotency_key  TEXT      NOT NULL,
+    challenge_id     INTEGER   NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+    result           JSONB     NOT NULL DEFAULT '{}',
+    created_at       TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_email, idempotency_key)
+);
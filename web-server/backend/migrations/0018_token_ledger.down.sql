@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS token_ledger;
+DROP FUNCTION IF EXISTS token_ledger_append_only();
@@ -0,0 +1,39 @@
+-- Append-only record of every token balance change. tokens_available is the sum of a
+-- user's amounts and tokens_burned the negated sum of their burn and refund amounts.
+-- challenge_id has no foreign key so entries outlive deleted challenges.
+CREATE TABLE IF NOT EXISTS token_ledger (
+    id            BIGSERIAL PRIMARY KEY,
+    user_email    TEXT      NOT NULL,
+    entry_type    TEXT      NOT NULL CHECK (entry_type IN ('earn', 'burn', 'refund', 'admin-grant', 'hint')),
+    amount        INTEGER   NOT NULL,
+    challenge_id  INTEGER,
+    actor         TEXT      NOT NULL,
+    note          TEXT      NOT NULL DEFAULT '',
+    created_at    TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_token_ledger_user_email ON token_ledger(user_email, id);
+
+CREATE OR REPLACE FUNCTION token_ledger_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'token_ledger is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS token_ledger_append_only ON token_ledger;
+CREATE TRIGGER token_ledger_append_only
+    BEFORE UPDATE OR DELETE ON token_ledger
+    FOR EACH ROW EXECUTE FUNCTION token_ledger_append_only();
+
+-- Open the ledger with each user's balances so far
+INSERT INTO token_ledger (user_email, entry_type, amount, actor, note, created_at)
+SELECT user_email, 'earn', tokens_available + tokens_burned, 'migration', 'Balance before the token ledger', NOW()
+FROM users
+WHERE tokens_available + tokens_burned != 0
+  AND NOT EXISTS (SELECT 1 FROM token_ledger l WHERE l.user_email = users.user_email);
+
+INSERT INTO token_ledger (user_email, entry_type, amount, actor, note, created_at)
+SELECT user_email, 'burn', -tokens_burned, 'migration', 'Balance before the token ledger', NOW()
+FROM users
+WHERE tokens_burned != 0
+  AND NOT EXISTS (SELECT 1 FROM token_ledger l WHERE l.user_email = users.user_email AND l.entry_type = 'burn');
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS team_scoreboard_snapshot;
+DROP VIEW IF EXISTS team_standings;
@@ -0,0 +1,26 @@
+-- Live team standings with the same columns as team_scoreboard_snapshot
+CREATE OR REPLACE VIEW team_standings AS
+SELECT team_id,
+       SUM(points)::INTEGER AS points,
+       COUNT(*)::INTEGER AS solves,
+       MAX(completed_at) AS last_solved_at
+FROM team_challenges_completed
+GROUP BY team_id;
+
+-- Team standings captured at the scoreboard freeze, alongside scoreboard_snapshot.
+-- Team completions are repriced by later solves of dynamic challenges, so counting
+-- only the solves made before the freeze would still show later score changes.
+CREATE TABLE IF NOT EXISTS team_scoreboard_snapshot (
+    team_id         INTEGER   PRIMARY KEY REFERENCES teams(id) ON DELETE CASCADE,
+    points          INTEGER   NOT NULL,
+    solves          INTEGER   NOT NULL,
+    last_solved_at  TIMESTAMP
+);
+
+-- A scoreboard that already froze keeps the team solves made before it froze
+INSERT INTO team_scoreboard_snapshot (team_id, points, solves, last_solved_at)
+SELECT tcc.team_id, SUM(tcc.points), COUNT(*), MAX(tcc.completed_at)
+FROM team_challenges_completed tcc
+JOIN competition_state cs ON tcc.completed_at < cs.scoreboard_frozen_at
+GROUP BY tcc.team_id
+ON CONFLICT (team_id) DO NOTHING;
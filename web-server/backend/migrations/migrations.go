@@ -0,0 +1,261 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// advisoryLockKey identifies the migration lock. Every replica must use the same key.
+const advisoryLockKey = 7271001
+
+// fileNamePattern matches migration files such as 0001_baseline.up.sql
+var fileNamePattern = regexp.MustCompile(`^(\d{4})_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is a single numbered schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status reports whether a migration has been applied
+type Status struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+// Migrator applies the embedded migrations to a database
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewMigrator creates a migrator for the embedded migrations
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	migrations, err := load(files)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// load reads the migration files, requiring an up and down file for every
+// version and versions numbered 1..n without gaps
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file name %q", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		contents, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, exists := byVersion[version]
+		if !exists {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %04d has two names: %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(contents)
+		} else {
+			migration.Down = string(contents)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			return nil, fmt.Errorf("migration versions must be sequential, expected %04d but found %04d", i+1, migration.Version)
+		}
+	}
+
+	return migrations, nil
+}
+
+// withLock runs fn on a single connection holding the migration advisory lock.
+// Session level advisory locks belong to a connection, so all work happens on conn.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, advisoryLockKey); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, advisoryLockKey)
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version     INTEGER   PRIMARY KEY,
+			name        TEXT      NOT NULL,
+			applied_at  TIMESTAMP NOT NULL DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	return fn(conn)
+}
+
+// applied returns the applied versions and when they were applied
+func applied(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	versions := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_migrations: %w", err)
+		}
+		versions[version] = appliedAt
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return versions, nil
+}
+
+// Up applies every migration that has not been applied yet, in order.
+// Returns the migrations that were applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var ran []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, done := versions[migration.Version]; done {
+				continue
+			}
+			if err := run(ctx, conn, migration, migration.Up, `
+				INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, NOW())
+			`); err != nil {
+				return err
+			}
+			ran = append(ran, migration)
+		}
+		return nil
+	})
+	return ran, err
+}
+
+// Down reverts the most recently applied migrations, up to steps of them.
+// Returns the migrations that were reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var ran []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(ran) < steps; i-- {
+			migration := m.migrations[i]
+			if _, done := versions[migration.Version]; !done {
+				continue
+			}
+			if err := run(ctx, conn, migration, migration.Down, `
+				DELETE FROM schema_migrations WHERE version = $1 AND name = $2
+			`); err != nil {
+				return err
+			}
+			ran = append(ran, migration)
+		}
+		return nil
+	})
+	return ran, err
+}
+
+// Status lists every known migration and when it was applied
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			status := Status{Version: migration.Version, Name: migration.Name}
+			if appliedAt, done := versions[migration.Version]; done {
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// run executes a migration script and records it in a single transaction
+func run(ctx context.Context, conn *sql.Conn, migration Migration, script, record string) error {
+	// Start transaction
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, record, migration.Version, migration.Name); err != nil {
+		return fmt.Errorf("failed to record migration %04d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %04d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,1029 @@
+package routes
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/obelisk/example-ctf/services"
+	"github.com/obelisk/example-ctf/utility"
+)
+
+const (
+	defaultIncidentLimit = 50
+	maxIncidentLimit     = 200
+
+	defaultSubmissionLimit = 50
+	maxSubmissionLimit     = 200
+
+	defaultHintUnlockLimit = 50
+	maxHintUnlockLimit     = 200
+
+	defaultLedgerLimit = 50
+	maxLedgerLimit     = 200
+)
+
+// SetChallengeHiddenRequest represents the request body for hiding a challenge
+type SetChallengeHiddenRequest struct {
+	Hidden bool `json:"hidden"`
+}
+
+// SetCompetitionPausedRequest represents the request body for pausing the competition
+type SetCompetitionPausedRequest struct {
+	Paused bool `json:"paused"`
+}
+
+// sendAdminError maps service errors onto admin API responses
+func sendAdminError(w http.ResponseWriter, log *logrus.Entry, err error) {
+	switch {
+	case services.IsClientError(err):
+		log.Errorf("admin request denied: %v", err)
+		utility.SendJSONError(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, sql.ErrNoRows):
+		utility.SendJSONError(w, notFoundError, http.StatusNotFound)
+	default:
+		log.Errorf("internal error handling admin request: %v", err)
+		utility.SendJSONError(w, internalError, http.StatusInternalServerError)
+	}
+}
+
+// redactFlag hides flag values from users who can only observe
+func redactFlag(user *services.User, definition *services.ChallengeDefinition) {
+	if !user.HasRole(services.RoleAdmin) {
+		definition.FlagValue = ""
+	}
+}
+
+// AdminListChallenges returns every challenge definition including flags
+// Observers receive the definitions with flag values redacted
+func AdminListChallenges(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		log.Info("admin requested challenge definitions")
+
+		definitions, err := container.ChallengeClient.ListChallengeDefinitions(ctx)
+		if err != nil {
+			sendAdminError(w, log, err)
+			return
+		}
+		for i := range definitions {
+			redactFlag(user, &definitions[i])
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(definitions); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
+
+// AdminGetChallenge returns a single challenge definition including its flag
+// Observers receive the definition with the flag value redacted
+func AdminGetChallenge(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		challengeID, err := validateChallengeID(mux.Vars(r)["id"])
+		if err != nil {
+			log.Errorf("invalid challenge ID: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+
+		log = log.WithFields(logrus.Fields{
+			"challenge_id": challengeID,
+		})
+		log.Info("admin requested challenge definition")
+
+		definition, err := container.ChallengeClient.GetChallengeDefinition(ctx, challengeID)
+		if err != nil {
+			sendAdminError(w, log, err)
+			return
+		}
+		redactFlag(user, definition)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(definition); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
+
+// AdminCreateChallenge creates a new challenge and its flag
+func AdminCreateChallenge(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		var definition services.ChallengeDefinition
+		if err := json.NewDecoder(r.Body).Decode(&definition); err != nil {
+			log.Errorf("failed to decode challenge definition: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+
+		log = log.WithFields(logrus.Fields{
+			"challenge_id": definition.ID,
+		})
+
+		if err := container.ChallengeClient.CreateChallenge(ctx, user.Email, definition); err != nil {
+			sendAdminError(w, log, err)
+			return
+		}
+
+		log.Info("admin created challenge")
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(map[string]any{
+			"message": "Challenge created successfully",
+		}); err != nil {
+			log.Errorf("encode error: %v", err)
+		}
+	})
+}
+
+// AdminUpdateChallenge replaces an existing challenge and its flag
+func AdminUpdateChallenge(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		challengeID, err := validateChallengeID(mux.Vars(r)["id"])
+		if err != nil {
+			log.Errorf("invalid challenge ID: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+
+		log = log.WithFields(logrus.Fields{
+			"challenge_id": challengeID,
+		})
+
+		var definition services.ChallengeDefinition
+		if err := json.NewDecoder(r.Body).Decode(&definition); err != nil {
+			log.Errorf("failed to decode challenge definition: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+		// The path is authoritative for which challenge is updated
+		definition.ID = challengeID
+
+		if err := container.ChallengeClient.UpdateChallenge(ctx, user.Email, definition); err != nil {
+			sendAdminError(w, log, err)
+			return
+		}
+		// Rescoring a dynamic challenge changes its solvers' points
+		container.UserClient.InvalidateProfiles()
+
+		log.Info("admin updated challenge")
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]any{
+			"message": "Challenge updated successfully",
+		}); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
+
+// AdminSetChallengeHidden hides or reveals a challenge
+func AdminSetChallengeHidden(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		challengeID, err := validateChallengeID(mux.Vars(r)["id"])
+		if err != nil {
+			log.Errorf("invalid challenge ID: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+
+		log = log.WithFields(logrus.Fields{
+			"challenge_id": challengeID,
+		})
+
+		var req SetChallengeHiddenRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Errorf("failed to decode hidden request: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+
+		if err := container.ChallengeClient.SetChallengeHidden(ctx, user.Email, challengeID, req.Hidden); err != nil {
+			sendAdminError(w, log, err)
+			return
+		}
+
+		log.WithFields(logrus.Fields{
+			"hidden": req.Hidden,
+		}).Info("admin changed challenge visibility")
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]any{
+			"message": "Challenge visibility updated",
+			"hidden":  req.Hidden,
+		}); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
+
+// AdminDeleteChallenge deletes a challenge, refusing if it has completions unless forced
+func AdminDeleteChallenge(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		challengeID, err := validateChallengeID(mux.Vars(r)["id"])
+		if err != nil {
+			log.Errorf("invalid challenge ID: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+
+		force := r.URL.Query().Get("force") == "true"
+
+		log = log.WithFields(logrus.Fields{
+			"challenge_id": challengeID,
+			"force":        force,
+		})
+
+		if err := container.ChallengeClient.DeleteChallenge(ctx, user.Email, challengeID, force); err != nil {
+			sendAdminError(w, log, err)
+			return
+		}
+
+		log.Info("admin deleted challenge")
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]any{
+			"message": "Challenge deleted successfully",
+		}); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
+
+// AdminListValidationHandlers returns the validation handler names accepted by the admin API
+func AdminListValidationHandlers(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(container.ChallengeClient.ValidationHandlers()); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
+
+// AdminListArtifactGenerators returns the artifact generator names accepted by the admin API
+func AdminListArtifactGenerators(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(container.ChallengeClient.ArtifactGenerators()); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
+
+// AdminListCheatingIncidents returns a page of submissions of other users' answers
+func AdminListCheatingIncidents(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		limit, offset, err := parsePagination(r, defaultIncidentLimit, maxIncidentLimit)
+		if err != nil {
+			log.Errorf("invalid pagination: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+
+		log.Info("admin requested cheating incidents")
+
+		page, err := container.ChallengeClient.ListCheatingIncidents(ctx, limit, offset)
+		if err != nil {
+			sendAdminError(w, log, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(page); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
+
+// AdminListSubmissions returns a page of flag submissions, optionally filtered by
+// user_email and challenge_id
+func AdminListSubmissions(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		limit, offset, err := parsePagination(r, defaultSubmissionLimit, maxSubmissionLimit)
+		if err != nil {
+			log.Errorf("invalid pagination: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+
+		filter := services.SubmissionFilter{
+			UserEmail: r.URL.Query().Get("user_email"),
+		}
+		if raw := r.URL.Query().Get("challenge_id"); raw != "" {
+			filter.ChallengeID, err = validateChallengeID(raw)
+			if err != nil {
+				log.Errorf("invalid challenge ID: %v", err)
+				utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+				return
+			}
+		}
+
+		log.Info("admin requested submissions")
+
+		page, err := container.Submissions.List(ctx, filter, limit, offset)
+		if err != nil {
+			sendAdminError(w, log, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(page); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
+
+// AdminListAttachments returns the files attached to a challenge
+func AdminListAttachments(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		challengeID, err := validateChallengeID(mux.Vars(r)["id"])
+		if err != nil {
+			log.Errorf("invalid challenge ID: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+
+		attachments, err := container.Attachments.List(ctx, challengeID)
+		if err != nil {
+			sendAdminError(w, log, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(attachments); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
+
+// AdminPutAttachment uploads the request body as a challenge attachment.
+// Uploads are bound by the admin request size limit; use ctfctl for larger files.
+func AdminPutAttachment(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		challengeID, err := validateChallengeID(mux.Vars(r)["id"])
+		if err != nil {
+			log.Errorf("invalid challenge ID: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+		filename := mux.Vars(r)["filename"]
+
+		log = log.WithFields(logrus.Fields{
+			"challenge_id": challengeID,
+			"filename":     filename,
+		})
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Errorf("failed to read attachment: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+
+		contentType := r.Header.Get("Content-Type")
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		attachment, err := container.Attachments.Put(ctx, user.Email, challengeID, filename, contentType, bytes.NewReader(body))
+		if err != nil {
+			sendAdminError(w, log, err)
+			return
+		}
+
+		log.WithFields(logrus.Fields{
+			"sha256": attachment.SHA256,
+		}).Info("admin uploaded attachment")
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(attachment); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
+
+// AdminDeleteAttachment removes a file from a challenge
+func AdminDeleteAttachment(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		challengeID, err := validateChallengeID(mux.Vars(r)["id"])
+		if err != nil {
+			log.Errorf("invalid challenge ID: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+		filename := mux.Vars(r)["filename"]
+
+		log = log.WithFields(logrus.Fields{
+			"challenge_id": challengeID,
+			"filename":     filename,
+		})
+
+		if err := container.Attachments.Delete(ctx, user.Email, challengeID, filename); err != nil {
+			sendAdminError(w, log, err)
+			return
+		}
+
+		log.Info("admin removed attachment")
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]any{
+			"message": "Attachment removed",
+		}); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
+
+// AdminListHints returns a challenge's hints with their text and unlock counts
+func AdminListHints(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		challengeID, err := validateChallengeID(mux.Vars(r)["id"])
+		if err != nil {
+			log.Errorf("invalid challenge ID: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+
+		hints, err := container.Hints.ListDefinitions(ctx, challengeID)
+		if err != nil {
+			sendAdminError(w, log, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(hints); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
+
+// AdminCreateHint adds a hint to a challenge
+func AdminCreateHint(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		challengeID, err := validateChallengeID(mux.Vars(r)["id"])
+		if err != nil {
+			log.Errorf("invalid challenge ID: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+
+		var definition services.HintDefinition
+		if err := json.NewDecoder(r.Body).Decode(&definition); err != nil {
+			log.Errorf("failed to decode hint: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+		definition.ChallengeID = challengeID
+
+		log = log.WithFields(logrus.Fields{
+			"challenge_id": challengeID,
+		})
+
+		hint, err := container.Hints.Create(ctx, user.Email, definition)
+		if err != nil {
+			sendAdminError(w, log, err)
+			return
+		}
+
+		log.WithFields(logrus.Fields{
+			"hint_id": hint.ID,
+		}).Info("admin added hint")
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(hint); err != nil {
+			log.Errorf("encode error: %v", err)
+		}
+	})
+}
+
+// AdminDeleteHint removes a hint from a challenge
+func AdminDeleteHint(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		challengeID, err := validateChallengeID(mux.Vars(r)["id"])
+		if err != nil {
+			log.Errorf("invalid challenge ID: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+
+		hintID, err := validateHintID(mux.Vars(r)["hintId"])
+		if err != nil {
+			log.Errorf("invalid hint ID: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+
+		log = log.WithFields(logrus.Fields{
+			"challenge_id": challengeID,
+			"hint_id":      hintID,
+		})
+
+		if err := container.Hints.Delete(ctx, user.Email, challengeID, hintID); err != nil {
+			sendAdminError(w, log, err)
+			return
+		}
+
+		log.Info("admin removed hint")
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]any{
+			"message": "Hint removed",
+		}); err != nil {
+			log.Errorf("encode error: %v", err)
+		}
+	})
+}
+
+// AdminListHintUnlocks returns a page of hint unlocks, optionally filtered by
+// user_email and challenge_id
+func AdminListHintUnlocks(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		limit, offset, err := parsePagination(r, defaultHintUnlockLimit, maxHintUnlockLimit)
+		if err != nil {
+			log.Errorf("invalid pagination: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+
+		filter := services.HintUnlockFilter{
+			UserEmail: r.URL.Query().Get("user_email"),
+		}
+		if raw := r.URL.Query().Get("challenge_id"); raw != "" {
+			filter.ChallengeID, err = validateChallengeID(raw)
+			if err != nil {
+				log.Errorf("invalid challenge ID: %v", err)
+				utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+				return
+			}
+		}
+
+		log.Info("admin requested hint unlocks")
+
+		page, err := container.Hints.ListUnlocks(ctx, filter, limit, offset)
+		if err != nil {
+			sendAdminError(w, log, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(page); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
+
+// AdminSetCompetitionPaused pauses or resumes the competition
+func AdminSetCompetitionPaused(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		var req SetCompetitionPausedRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Errorf("failed to decode pause request: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+
+		if err := container.Competition.SetPaused(ctx, user.Email, req.Paused); err != nil {
+			sendAdminError(w, log, err)
+			return
+		}
+
+		log.WithFields(logrus.Fields{
+			"paused": req.Paused,
+		}).Info("admin changed competition pause state")
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]any{
+			"message": "Competition state updated",
+			"paused":  req.Paused,
+		}); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
+
+// AdminRevealScoreboard ends the scoreboard freeze
+func AdminRevealScoreboard(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		if err := container.Competition.RevealScoreboard(ctx, user.Email); err != nil {
+			sendAdminError(w, log, err)
+			return
+		}
+
+		log.Info("admin revealed the frozen scoreboard")
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]any{
+			"message": "Scoreboard revealed",
+		}); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
+
+// AdminListExamTracks returns every exam track with its unlock requirements
+func AdminListExamTracks(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		tracks, err := container.ExamTracks.List(ctx)
+		if err != nil {
+			sendAdminError(w, log, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(tracks); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
+
+// AdminPutExamTrack creates an exam track or replaces its unlock requirements
+func AdminPutExamTrack(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		var track services.ExamTrack
+		if err := json.NewDecoder(r.Body).Decode(&track); err != nil {
+			log.Errorf("failed to decode exam track: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+		// The name in the path wins
+		track.Name = mux.Vars(r)["track"]
+
+		log = log.WithFields(logrus.Fields{
+			"exam_track": track.Name,
+		})
+
+		if err := container.ExamTracks.Put(ctx, user.Email, track); err != nil {
+			sendAdminError(w, log, err)
+			return
+		}
+
+		saved, err := container.ExamTracks.Get(ctx, track.Name)
+		if err != nil {
+			sendAdminError(w, log, err)
+			return
+		}
+
+		log.Info("admin set exam track")
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(saved); err != nil {
+			log.Errorf("encode error: %v", err)
+		}
+	})
+}
+
+// AdminDeleteExamTrack removes an exam track that no challenge uses
+func AdminDeleteExamTrack(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		name := mux.Vars(r)["track"]
+		log = log.WithFields(logrus.Fields{
+			"exam_track": name,
+		})
+
+		if err := container.ExamTracks.Delete(ctx, user.Email, name); err != nil {
+			sendAdminError(w, log, err)
+			return
+		}
+
+		log.Info("admin deleted exam track")
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]any{
+			"message": "Exam track deleted",
+		}); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
+
+// AdminListTokenLedger returns a page of token ledger entries, optionally filtered by user_email
+func AdminListTokenLedger(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		limit, offset, err := parsePagination(r, defaultLedgerLimit, maxLedgerLimit)
+		if err != nil {
+			log.Errorf("invalid pagination: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+
+		log.Info("admin requested token ledger")
+
+		page, err := container.Ledger.List(ctx, r.URL.Query().Get("user_email"), limit, offset)
+		if err != nil {
+			sendAdminError(w, log, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(page); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
+
+// AdminReconcileTokenLedger reports every user whose token counters disagree with the ledger
+func AdminReconcileTokenLedger(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		discrepancies, err := container.Ledger.Reconcile(ctx)
+		if err != nil {
+			sendAdminError(w, log, err)
+			return
+		}
+
+		if len(discrepancies) > 0 {
+			log.Warnf("token ledger disagrees with %d users' balances", len(discrepancies))
+		} else {
+			log.Info("token ledger reconciled")
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]any{
+			"consistent":    len(discrepancies) == 0,
+			"discrepancies": discrepancies,
+		}); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
+
+// AdminGrantTokens adds tokens to or removes them from a user's balance
+func AdminGrantTokens(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		var grant services.TokenGrant
+		if err := json.NewDecoder(r.Body).Decode(&grant); err != nil {
+			log.Errorf("failed to decode token grant: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+
+		log = log.WithFields(logrus.Fields{
+			"grantee": grant.UserEmail,
+			"amount":  grant.Amount,
+		})
+
+		if err := container.UserClient.GrantTokens(ctx, user.Email, grant); err != nil {
+			sendAdminError(w, log, err)
+			return
+		}
+
+		log.Info("admin granted tokens")
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]any{
+			"message": "Tokens granted",
+		}); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
+
+// AdminRefundTokens gives a user back tokens they burned on exam submissions
+func AdminRefundTokens(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		var refund services.TokenRefund
+		if err := json.NewDecoder(r.Body).Decode(&refund); err != nil {
+			log.Errorf("failed to decode token refund: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+
+		log = log.WithFields(logrus.Fields{
+			"refundee": refund.UserEmail,
+			"amount":   refund.Amount,
+		})
+
+		if err := container.UserClient.RefundTokens(ctx, user.Email, refund); err != nil {
+			sendAdminError(w, log, err)
+			return
+		}
+
+		log.Info("admin refunded tokens")
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]any{
+			"message": "Tokens refunded",
+		}); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package routes
+
+import (
+	"errors"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+
+	"github.com/obelisk/example-ctf/services"
+)
+
+// ServeLocalAsset serves a file from the local asset store. The request path is
+// the asset path and must carry the expires and signature parameters of a URL
+// handed out by the asset service.
+func ServeLocalAsset(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		store, ok := container.AssetService.Store().(*services.LocalAssetStore)
+		if !ok {
+			http.Error(w, notFoundError, http.StatusNotFound)
+			return
+		}
+
+		query := r.URL.Query()
+		file, err := store.Open(r.URL.Path, query.Get("expires"), query.Get("signature"))
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrInvalidAssetSignature):
+				http.Error(w, "Forbidden", http.StatusForbidden)
+			case errors.Is(err, fs.ErrNotExist):
+				http.Error(w, notFoundError, http.StatusNotFound)
+			default:
+				log.Errorf("failed to open local asset: %v", err)
+				http.Error(w, internalError, http.StatusInternalServerError)
+			}
+			return
+		}
+		defer file.Close()
+
+		info, err := file.Stat()
+		if err != nil || info.IsDir() {
+			http.Error(w, notFoundError, http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": path.Base(r.URL.Path),
+		}))
+		http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+	})
+}
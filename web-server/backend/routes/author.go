@@ -0,0 +1,80 @@
+package routes
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/obelisk/example-ctf/services"
+	"github.com/obelisk/example-ctf/utility"
+)
+
+// AuthorPreviewChallenge returns a challenge as players would see it, including
+// hidden and exam challenges, so authors can check it before it is published
+func AuthorPreviewChallenge(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		challengeID, err := validateChallengeID(mux.Vars(r)["id"])
+		if err != nil {
+			log.Errorf("invalid challenge ID: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+
+		log = log.WithFields(logrus.Fields{
+			"challenge_id": challengeID,
+		})
+		log.Info("author requested challenge preview")
+
+		var challenge services.DetailedChallenge
+		err = container.DB.QueryRow(`
+			SELECT c.id, c.nested_id, c.name, c.description, c.category, c.point_reward_amount, c.file_asset, c.text_asset
+			FROM challenges c
+			WHERE c.id = $1
+			`, challengeID).Scan(
+			&challenge.ID,
+			&challenge.NestedID,
+			&challenge.Name,
+			&challenge.Description,
+			&challenge.Category,
+			&challenge.PointRewardAmount,
+			&challenge.FileAsset,
+			&challenge.TextAsset,
+		)
+
+		if err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, notFoundError, http.StatusNotFound)
+			} else {
+				log.Errorf("unable to query challenge from database: %v", err)
+				http.Error(w, internalError, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		// Render the text asset with the author's own derived flag and sign download URLs
+		if err := resolveChallengeAssets(ctx, container, &challenge, user.Email); err != nil {
+			log.Errorf("failed to resolve challenge assets: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(challenge); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
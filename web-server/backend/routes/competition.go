@@ -0,0 +1,49 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/obelisk/example-ctf/services"
+	"github.com/obelisk/example-ctf/utility"
+)
+
+// checkCompetitionOpen writes a 403 JSON error and returns false unless the competition accepts submissions
+func checkCompetitionOpen(w http.ResponseWriter, r *http.Request, container *services.Container, log *logrus.Entry) bool {
+	err := container.Competition.CheckOpen(r.Context())
+	if err == nil {
+		return true
+	}
+
+	if services.IsClientError(err) {
+		log.Infof("submission rejected - %v", err)
+		utility.SendJSONError(w, err.Error(), http.StatusForbidden)
+	} else {
+		log.Errorf("unable to check competition state: %v", err)
+		http.Error(w, internalError, http.StatusInternalServerError)
+	}
+	return false
+}
+
+// GetCompetition returns the competition's phase and schedule
+func GetCompetition(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		status, err := container.Competition.Status(ctx)
+		if err != nil {
+			log.Errorf("unable to get competition status: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/obelisk/example-ctf/services"
+	"github.com/obelisk/example-ctf/utility"
+)
+
+// checkExamSession writes a 403 JSON error and returns false unless the user's exam session is running
+func checkExamSession(w http.ResponseWriter, r *http.Request, container *services.Container, userEmail string, log *logrus.Entry) bool {
+	err := container.ExamSessions.CheckActive(r.Context(), userEmail)
+	if err == nil {
+		return true
+	}
+
+	if services.IsClientError(err) {
+		log.Infof("exam request rejected - %v", err)
+		utility.SendJSONError(w, err.Error(), http.StatusForbidden)
+	} else {
+		log.Errorf("unable to check exam session: %v", err)
+		http.Error(w, internalError, http.StatusInternalServerError)
+	}
+	return false
+}
+
+// GetExamSession returns whether the exam is timed and the user's session countdown
+func GetExamSession(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		status, err := container.ExamSessions.Status(ctx, user.Email)
+		if err != nil {
+			log.Errorf("unable to get exam session: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
+
+// StartExam starts the user's timed exam session
+func StartExam(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		if !checkCompetitionOpen(w, r, container, log) {
+			return
+		}
+
+		session, err := container.ExamSessions.Start(ctx, user.Email)
+		if err != nil {
+			if services.IsClientError(err) {
+				log.Infof("exam start rejected - %v", err)
+				utility.SendJSONError(w, err.Error(), http.StatusConflict)
+			} else {
+				log.Errorf("unable to start exam session: %v", err)
+				http.Error(w, internalError, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		log.Info("user started the exam")
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(session); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
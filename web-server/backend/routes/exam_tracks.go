@@ -0,0 +1,78 @@
+package routes
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/obelisk/example-ctf/services"
+	"github.com/obelisk/example-ctf/utility"
+)
+
+// lockedExamTrackError is returned for exam tracks whose requirements are not met yet
+const lockedExamTrackError = "Exam track is locked"
+
+// examTrackName returns the exam track named by the route, or the main track
+func examTrackName(r *http.Request) string {
+	if track := mux.Vars(r)["track"]; track != "" {
+		return track
+	}
+	return services.DefaultExamTrack
+}
+
+// examTrackStage resolves the request's exam track, the main track unless the route names one,
+// and returns it with the highest nested ID the user may open in it. It writes a 404 for unknown
+// tracks and a 403 for locked ones and returns false.
+func examTrackStage(w http.ResponseWriter, r *http.Request, container *services.Container, userEmail string, log *logrus.Entry) (string, int, bool) {
+	track := examTrackName(r)
+
+	progress, err := container.ExamTracks.Progress(r.Context(), userEmail, track)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, notFoundError, http.StatusNotFound)
+		return "", 0, false
+	}
+	if err != nil {
+		log.Errorf("unable to get exam track progress: %v", err)
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return "", 0, false
+	}
+	if progress.Locked {
+		log.Infof("user attempted to open locked exam track %s", track)
+		utility.SendJSONError(w, lockedExamTrackError, http.StatusForbidden)
+		return "", 0, false
+	}
+
+	return track, progress.StagesSolved + 1, true
+}
+
+// ListExamTracks returns the user's progress through each exam track and whether it is locked
+func ListExamTracks(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		tracks, err := container.ExamTracks.ListForUser(ctx, user.Email)
+		if err != nil {
+			log.Errorf("unable to list exam tracks: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(tracks); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package routes
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/obelisk/example-ctf/services"
+	"github.com/obelisk/example-ctf/utility"
+)
+
+// validateHintID validates and sanitizes hint ID input
+func validateHintID(id string) (int, error) {
+	hintID, err := strconv.Atoi(strings.TrimSpace(id))
+	if err != nil {
+		return 0, fmt.Errorf("invalid hint ID format")
+	}
+	if hintID <= 0 {
+		return 0, fmt.Errorf("hint ID out of valid range")
+	}
+	return hintID, nil
+}
+
+// ListHints returns a challenge's hints; the text is included for hints the user unlocked
+func ListHints(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		challengeID, err := validateChallengeID(mux.Vars(r)["id"])
+		if err != nil {
+			log.Errorf("invalid challenge ID: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+
+		log = log.WithFields(logrus.Fields{
+			"challenge_id": challengeID,
+		})
+		log.Info("user requested challenge hints")
+
+		isLocked, _, err := container.ChallengeClient.ChallengeLocked(ctx, challengeID, user.Email)
+		if err != nil {
+			log.Errorf("unable to evaluate challenge prerequisites: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+		if isLocked {
+			utility.SendJSONError(w, lockedChallengeError, http.StatusForbidden)
+			return
+		}
+
+		hints, err := container.Hints.ListForUser(ctx, challengeID, user.Email)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, notFoundError, http.StatusNotFound)
+			} else {
+				log.Errorf("unable to list hints: %v", err)
+				http.Error(w, internalError, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(hints); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
+
+// UnlockHint charges the user for a hint and returns it with its text
+func UnlockHint(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		challengeID, err := validateChallengeID(mux.Vars(r)["id"])
+		if err != nil {
+			log.Errorf("invalid challenge ID: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+
+		hintID, err := validateHintID(mux.Vars(r)["hintId"])
+		if err != nil {
+			log.Errorf("invalid hint ID: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+
+		log = log.WithFields(logrus.Fields{
+			"challenge_id": challengeID,
+			"hint_id":      hintID,
+		})
+
+		if !checkCompetitionOpen(w, r, container, log) {
+			return
+		}
+
+		isLocked, _, err := container.ChallengeClient.ChallengeLocked(ctx, challengeID, user.Email)
+		if err != nil {
+			log.Errorf("unable to evaluate challenge prerequisites: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+		if isLocked {
+			utility.SendJSONError(w, lockedChallengeError, http.StatusForbidden)
+			return
+		}
+
+		hint, charged, err := container.UserClient.UnlockHint(ctx, user.Email, challengeID, hintID)
+		if err != nil {
+			switch {
+			case services.IsClientError(err):
+				log.Errorf("hint unlock denied: %v", err)
+				utility.SendJSONError(w, err.Error(), http.StatusBadRequest)
+			case errors.Is(err, sql.ErrNoRows):
+				http.Error(w, notFoundError, http.StatusNotFound)
+			default:
+				log.Errorf("unable to unlock hint: %v", err)
+				http.Error(w, internalError, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		if charged {
+			log.WithFields(logrus.Fields{
+				"cost":      hint.Cost,
+				"cost_type": hint.CostType,
+			}).Info("user unlocked hint")
+			container.SlackService.SendHintUnlock(user, hint)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(hint); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
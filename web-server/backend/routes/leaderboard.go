@@ -0,0 +1,117 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/obelisk/example-ctf/services"
+	"github.com/obelisk/example-ctf/utility"
+)
+
+const (
+	defaultLeaderboardLimit = 25
+	maxLeaderboardLimit     = 100
+)
+
+// parsePagination reads the limit and offset query parameters
+func parsePagination(r *http.Request, defaultLimit, maxLimit int) (int, int, error) {
+	limit := defaultLimit
+	offset := 0
+
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > maxLimit {
+			return 0, 0, fmt.Errorf("invalid limit: %q", raw)
+		}
+		limit = parsed
+	}
+
+	if raw := r.URL.Query().Get("offset"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			return 0, 0, fmt.Errorf("invalid offset: %q", raw)
+		}
+		offset = parsed
+	}
+
+	return limit, offset, nil
+}
+
+// GetLeaderboard returns a page of the public leaderboard
+func GetLeaderboard(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		// Get user from context (set by auth middleware)
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		limit, offset, err := parsePagination(r, defaultLeaderboardLimit, maxLeaderboardLimit)
+		if err != nil {
+			log.Errorf("invalid pagination: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+
+		log.Info("user requested leaderboard")
+
+		page, err := container.Leaderboard.GetPage(ctx, user.Email, limit, offset)
+		if err != nil {
+			log.Errorf("unable to get leaderboard: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(page); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
+
+// GetLeaderboardMe returns the current user's rank on the leaderboard
+func GetLeaderboardMe(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		// Get user from context (set by auth middleware)
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		log.Info("user requested own leaderboard rank")
+
+		entry, err := container.Leaderboard.GetUserEntry(ctx, user.Email)
+		if err != nil {
+			log.Errorf("unable to get leaderboard entry: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		// Users without any points are not ranked yet
+		response := map[string]any{
+			"ranked": entry != nil,
+		}
+		if entry != nil {
+			response["entry"] = entry
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
@@ -1,10 +1,13 @@
 package routes
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gorilla/mux"
+	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
 	"net/http"
 	"strconv"
@@ -17,6 +20,7 @@ import (
 const internalError = "Internal Error"
 const notFoundError = "Not Found"
 const invalidRequestError = "Invalid Request"
+const lockedChallengeError = "Challenge is locked"
 
 // validateChallengeID validates and sanitizes challenge ID input
 func validateChallengeID(id string) (int, error) {
@@ -37,6 +41,78 @@ func validateChallengeID(id string) (int, error) {
 	return challengeID, nil
 }
 
+// resolveChallengeAssets prepares a challenge's assets for a user: the text asset
+// is rendered with their derived flag, file assets and attachments get expiring
+// download URLs, and any generated artifact is added. It must run before an exam
+// challenge's ID is replaced.
+func resolveChallengeAssets(ctx context.Context, container *services.Container, challenge *services.DetailedChallenge, userEmail string) error {
+	if challenge.TextAsset != nil && *challenge.TextAsset != "" {
+		rendered, err := container.ChallengeClient.RenderTextAsset(ctx, challenge.ID, *challenge.TextAsset, userEmail)
+		if err != nil {
+			return fmt.Errorf("failed to render text asset: %w", err)
+		}
+		challenge.TextAsset = &rendered
+	}
+
+	if challenge.FileAsset != nil && *challenge.FileAsset != "" {
+		presignedURL, err := container.AssetService.GetAsset(ctx, *challenge.FileAsset)
+		if err != nil {
+			return fmt.Errorf("failed to get presigned URL for asset %s: %w", *challenge.FileAsset, err)
+		}
+		challenge.FileAsset = &presignedURL
+	}
+
+	attachments, err := container.Attachments.ListWithURLs(ctx, challenge.ID)
+	if err != nil {
+		return err
+	}
+	challenge.Attachments = attachments
+
+	artifact, err := container.Artifacts.ForUser(ctx, challenge.ID, userEmail)
+	if err != nil {
+		return fmt.Errorf("failed to resolve generated artifact: %w", err)
+	}
+	if artifact != nil {
+		if artifact.Text != nil {
+			challenge.TextAsset = artifact.Text
+		}
+		if artifact.File != nil {
+			challenge.Attachments = append(challenge.Attachments, *artifact.File)
+		}
+	}
+
+	return nil
+}
+
+// recordFlagSharing checks a wrong submission against other users' answers and records any match
+func recordFlagSharing(ctx context.Context, container *services.Container, log *logrus.Entry, flag *services.ChallengeFlag, submittedFlag string, user *services.User) {
+	owner, found, err := container.ChallengeClient.DetectFlagSharing(ctx, flag, submittedFlag, user.Email)
+	if err != nil {
+		log.Errorf("failed to check for flag sharing: %v", err)
+		return
+	}
+	if !found {
+		return
+	}
+
+	log.WithFields(logrus.Fields{
+		"owner_email": owner,
+	}).Warn("submitted flag belongs to another user")
+
+	incident := services.CheatingIncident{
+		UserEmail:   user.Email,
+		OwnerEmail:  owner,
+		ChallengeID: flag.ChallengeID,
+		Challenge:   flag.Name,
+		Kind:        flag.ValidationHandler,
+	}
+	if err := container.ChallengeClient.RecordCheatingIncident(ctx, incident); err != nil {
+		log.Errorf("failed to record cheating incident: %v", err)
+	}
+
+	container.SlackService.SendCheatingIncident(incident)
+}
+
 // HealthCheckHandler checks if the services are online, including the DB
 func HealthCheckHandler(container *services.Container) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -83,8 +159,17 @@ func GetProfile(container *services.Container) http.HandlerFunc {
 			return
 		}
 
+		// Roles come from the request's token, not the cached profile
+		response := struct {
+			*services.UserProfile
+			Roles []services.Role `json:"roles"`
+		}{
+			UserProfile: profile,
+			Roles:       user.Roles,
+		}
+
 		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(profile); err != nil {
+		if err := json.NewEncoder(w).Encode(response); err != nil {
 			log.Errorf("encode error: %v", err)
 			http.Error(w, internalError, http.StatusInternalServerError)
 		}
@@ -113,7 +198,7 @@ func ListChallenges(container *services.Container) http.HandlerFunc {
 			       CASE WHEN ucc.challenge_id IS NOT NULL THEN true ELSE false END as completed
 			FROM challenges c
 			LEFT JOIN user_challenges_completed ucc ON c.id = ucc.challenge_id AND ucc.user_email = $1
-			WHERE c.category != 'exam'
+			WHERE c.category != 'exam' AND NOT c.hidden AND (c.release_at IS NULL OR c.release_at <= NOW())
 			ORDER BY c.id
 		`, user.Email)
 
@@ -140,6 +225,31 @@ func ListChallenges(container *services.Container) http.HandlerFunc {
 			return
 		}
 
+		teamCompleted, err := container.Teams.TeamCompletedChallenges(ctx, user.Email)
+		if err != nil {
+			log.Errorf("unable to query team completions: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+		for i := range challenges {
+			challenges[i].TeamCompleted = teamCompleted[challenges[i].ID]
+		}
+
+		// Show challenges whose prerequisites aren't met as teasers
+		locked, err := container.ChallengeClient.LockedChallenges(ctx, user.Email)
+		if err != nil {
+			log.Errorf("unable to evaluate challenge prerequisites: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+		for i := range challenges {
+			if prerequisites, isLocked := locked[challenges[i].ID]; isLocked {
+				challenges[i].Locked = true
+				challenges[i].Description = ""
+				challenges[i].Prerequisites = prerequisites
+			}
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(challenges); err != nil {
 			log.Errorf("encode error: %v", err)
@@ -182,7 +292,7 @@ func GetChallenge(container *services.Container) http.HandlerFunc {
 			       CASE WHEN ucc.challenge_id IS NOT NULL THEN true ELSE false END as completed
 			FROM challenges c
 			LEFT JOIN user_challenges_completed ucc ON c.id = ucc.challenge_id AND ucc.user_email = $2
-			WHERE c.id = $1 AND c.category != 'exam'
+			WHERE c.id = $1 AND c.category != 'exam' AND NOT c.hidden AND (c.release_at IS NULL OR c.release_at <= NOW())
 			`, challengeID, user.Email).Scan(
 			&challenge.ID,
 			&challenge.NestedID,
@@ -211,15 +321,42 @@ func GetChallenge(container *services.Container) http.HandlerFunc {
 			return
 		}
 
-		// Transform file_asset to presigned URL if not empty
-		if challenge.FileAsset != nil && *challenge.FileAsset != "" {
-			presignedURL, err := container.AssetService.GetAsset(ctx, *challenge.FileAsset)
-			if err != nil {
-				log.Errorf("failed to get presigned URL for asset %s: %v", challenge.FileAsset, err)
+		challenge.TeamCompleted, _, err = container.Teams.CheckTeamCompletedChallenge(ctx, user.Email, challengeID)
+		if err != nil {
+			log.Errorf("unable to check team completion: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		isLocked, prerequisites, err := container.ChallengeClient.ChallengeLocked(ctx, challengeID, user.Email)
+		if err != nil {
+			log.Errorf("unable to evaluate challenge prerequisites: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		// Locked challenges are returned as teasers without their description or assets
+		if isLocked {
+			challenge.Locked = true
+			challenge.Prerequisites = prerequisites
+			challenge.Description = ""
+			challenge.FileAsset = nil
+			challenge.TextAsset = nil
+			challenge.Attachments = make([]services.Attachment, 0)
+
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(challenge); err != nil {
+				log.Errorf("encode error: %v", err)
 				http.Error(w, internalError, http.StatusInternalServerError)
-				return
 			}
-			challenge.FileAsset = &presignedURL
+			return
+		}
+
+		// Render the text asset and sign download URLs for the challenge's files
+		if err := resolveChallengeAssets(ctx, container, &challenge, user.Email); err != nil {
+			log.Errorf("failed to resolve challenge assets: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -256,6 +393,10 @@ func SubmitChallenge(container *services.Container) http.HandlerFunc {
 			"challenge_id": challengeID,
 		})
 
+		if !checkCompetitionOpen(w, r, container, log) {
+			return
+		}
+
 		type submission struct {
 			Flag string `json:"flag"`
 		}
@@ -296,8 +437,28 @@ func SubmitChallenge(container *services.Container) http.HandlerFunc {
 			return
 		}
 
+		// In team mode a teammate's solve counts for everyone on the team
+		teamCompleted, teamCompletedAt, err := container.Teams.CheckTeamCompletedChallenge(ctx, user.Email, challengeID)
+		if err != nil {
+			log.Errorf("Database error checking team completion: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		if teamCompleted {
+			log.Info("flag submission rejected - challenge already completed by team")
+			if err := json.NewEncoder(w).Encode(map[string]any{
+				"message":      "Challenge already completed by your team",
+				"completed_at": teamCompletedAt,
+			}); err != nil {
+				log.Errorf("encode error: %v", err)
+				http.Error(w, internalError, http.StatusInternalServerError)
+			}
+			return
+		}
+
 		// Get the challenge to validate the flag
-		flagValue, pointRewardAmount, validationHandler, challengeName, category, err := container.ChallengeClient.GetChallengeFlagAndReward(challengeID)
+		flag, err := container.ChallengeClient.GetChallengeFlagAndReward(challengeID)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				http.Error(w, notFoundError, http.StatusNotFound)
@@ -309,30 +470,46 @@ func SubmitChallenge(container *services.Container) http.HandlerFunc {
 		}
 
 		// Treat exam challenges as if they don't exist
-		if category == "exam" {
+		if flag.Category == "exam" {
 			http.Error(w, notFoundError, http.StatusNotFound)
 			return
 		}
 
+		isLocked, _, err := container.ChallengeClient.ChallengeLocked(ctx, challengeID, user.Email)
+		if err != nil {
+			log.Errorf("unable to evaluate challenge prerequisites: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+		if isLocked {
+			log.Info("flag submission rejected - challenge is locked")
+			utility.SendJSONError(w, lockedChallengeError, http.StatusForbidden)
+			return
+		}
+
 		// Validate the flag
-		isValid, customIncorrectMessage := container.ChallengeClient.ValidateFlag(sub.Flag, flagValue, validationHandler, user.Email)
-		if !isValid {
+		result, err := container.ChallengeClient.ValidateFlag(ctx, flag, sub.Flag, user.Email)
+		if err != nil {
+			log.Errorf("failed to validate flag: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+		if !result.Valid {
 			log.Info("flag submission failed - incorrect flag")
 
-			// Log the wrong flag attempt to the database
-			_, err = container.DB.Exec(`
-				INSERT INTO user_history_log (user_email, log, date) 
-				VALUES ($1, $2, NOW())
-			`, user.Email, fmt.Sprintf("Wrong flag attempt for challenge %d: %s", challengeID, sub.Flag))
-			if err != nil {
-				log.Errorf("failed to log wrong flag attempt: %v", err)
+			// Record the wrong flag attempt
+			if err := container.Submissions.RecordIncorrect(ctx, user.Email, challengeID, sub.Flag, 0); err != nil {
+				log.Errorf("failed to record wrong flag attempt: %v", err)
 				// Don't fail the request, just log the error
 			}
 
+			// Check if the submission is someone else's answer
+			recordFlagSharing(ctx, container, log, flag, sub.Flag, user)
+
 			// Use custom message if provided, otherwise use default
 			errorMessage := "Incorrect flag"
-			if customIncorrectMessage != "" {
-				errorMessage = customIncorrectMessage
+			if result.Message != "" {
+				errorMessage = result.Message
 			}
 
 			if err := json.NewEncoder(w).Encode(map[string]any{
@@ -345,7 +522,7 @@ func SubmitChallenge(container *services.Container) http.HandlerFunc {
 		}
 
 		// Complete challenge (awards token and points, records completion)
-		err = container.UserClient.CompleteChallenge(ctx, user.Email, pointRewardAmount, challengeID)
+		completion, err := container.UserClient.CompleteChallenge(ctx, user.Email, challengeID, result, sub.Flag)
 		if err != nil {
 			log.Errorf("failed to complete challenge: %v", err)
 			http.Error(w, internalError, http.StatusInternalServerError)
@@ -353,18 +530,22 @@ func SubmitChallenge(container *services.Container) http.HandlerFunc {
 		}
 
 		log.WithFields(logrus.Fields{
-			"tokens_earned": 1,
-			"points_earned": pointRewardAmount,
+			"tokens_earned":  1,
+			"points_earned":  completion.Points,
+			"bonus_points":   completion.BonusPoints,
+			"solve_position": completion.SolvePosition,
 		}).Info("challenge completed successfully")
 
 		// Send Slack notification
-		container.SlackService.SendChallengeCompletion(user, challengeName)
+		container.SlackService.SendChallengeCompletion(user, flag.Name, completion.SolvePosition)
 
 		// Return success response
 		if err := json.NewEncoder(w).Encode(map[string]any{
-			"message":       "Challenge completed successfully!",
-			"tokens_earned": 1,
-			"points_earned": pointRewardAmount,
+			"message":        "Challenge completed successfully!",
+			"tokens_earned":  1,
+			"points_earned":  completion.Points,
+			"bonus_points":   completion.BonusPoints,
+			"solve_position": completion.SolvePosition,
 		}); err != nil {
 			log.Errorf("encode error: %v", err)
 			http.Error(w, internalError, http.StatusInternalServerError)
@@ -372,7 +553,7 @@ func SubmitChallenge(container *services.Container) http.HandlerFunc {
 	})
 }
 
-// ListExamChallenges returns a list of exam challenges up to the next unsolved challenge
+// ListExamChallenges returns the exam challenges of every unlocked track, up to the next unsolved challenge in each
 func ListExamChallenges(container *services.Container) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -386,24 +567,42 @@ func ListExamChallenges(container *services.Container) http.HandlerFunc {
 			return
 		}
 
-		// Get user profile to check ExamChallengesSolved
-		profile, err := container.UserClient.GetUserProfile(ctx, container.SlackService, user)
+		// Without a running exam session stages are teasers, so nobody reads ahead before the clock starts
+		sessionErr := container.ExamSessions.CheckActive(ctx, user.Email)
+		if sessionErr != nil && !services.IsClientError(sessionErr) {
+			log.Errorf("unable to check exam session: %v", sessionErr)
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+		teasers := sessionErr != nil
+
+		tracks, err := container.ExamTracks.ListForUser(ctx, user.Email)
 		if err != nil {
-			log.Errorf("unable to get user profile: %v", err)
+			log.Errorf("unable to list exam tracks: %v", err)
 			http.Error(w, internalError, http.StatusInternalServerError)
 			return
 		}
 
-		// Query exam challenges up to the next unsolved challenge (ExamChallengesSolved + 1)
-		maxNestedID := profile.ExamChallengesSolved + 1
+		unlocked := make([]string, 0, len(tracks))
+		for _, track := range tracks {
+			if !track.Locked {
+				unlocked = append(unlocked, track.Name)
+			}
+		}
+
+		// Query exam challenges up to the next unsolved challenge of each unlocked track
 		rows, err := container.DB.Query(`
-			SELECT c.nested_id, c.name, c.description, c.category, c.point_reward_amount,
-			       CASE WHEN ucc.challenge_id IS NOT NULL THEN true ELSE false END as completed
+			SELECT c.exam_track, c.nested_id, c.name, c.description, c.category, c.point_reward_amount,
+			       CASE WHEN ucc.challenge_id IS NOT NULL THEN true ELSE false END as completed,
+			       c.submission_cost, c.completion_reward, c.max_attempts, COALESCE(ea.attempts, 0)
 			FROM challenges c
+			LEFT JOIN user_exam_progress p ON p.track = c.exam_track AND p.user_email = $1
 			LEFT JOIN user_challenges_completed ucc ON c.id = ucc.challenge_id AND ucc.user_email = $1
-			WHERE c.category = 'exam' AND c.nested_id <= $2
-			ORDER BY c.nested_id
-		`, user.Email, maxNestedID)
+			LEFT JOIN exam_attempts ea ON c.id = ea.challenge_id AND ea.user_email = $1
+			WHERE c.category = 'exam' AND c.exam_track = ANY($2) AND c.nested_id <= COALESCE(p.stages_solved, 0) + 1
+			  AND NOT c.hidden AND (c.release_at IS NULL OR c.release_at <= NOW())
+			ORDER BY c.exam_track, c.nested_id
+		`, user.Email, pq.Array(unlocked))
 
 		if err != nil {
 			log.Errorf("unable to query exam challenges from database: %v", err)
@@ -414,13 +613,21 @@ func ListExamChallenges(container *services.Container) http.HandlerFunc {
 		challenges := make([]services.Challenge, 0)
 		for rows.Next() {
 			var challenge services.Challenge
-			if err := rows.Scan(&challenge.NestedID, &challenge.Name, &challenge.Description, &challenge.Category, &challenge.PointRewardAmount, &challenge.Completed); err != nil {
+			var terms services.ExamTerms
+			var maxAttempts sql.NullInt64
+			if err := rows.Scan(&challenge.ExamTrack, &challenge.NestedID, &challenge.Name, &challenge.Description, &challenge.Category, &challenge.PointRewardAmount, &challenge.Completed,
+				&terms.SubmissionCost, &terms.CompletionReward, &maxAttempts, &terms.AttemptsUsed); err != nil {
 				log.Errorf("unable to scan rows queried from database: %v", err)
 				http.Error(w, internalError, http.StatusInternalServerError)
 				return
 			}
+			challenge.Exam = terms.WithMaxAttempts(maxAttempts)
 			// Use nested_id as the exposed ID for exam challenges
 			challenge.ID = challenge.NestedID
+			if teasers {
+				challenge.Locked = true
+				challenge.Description = ""
+			}
 			challenges = append(challenges, challenge)
 		}
 
@@ -465,16 +672,18 @@ func GetExamChallenge(container *services.Container) http.HandlerFunc {
 			"exam_nested_id": nestedID,
 		})
 
-		// Get user profile to check ExamChallengesSolved
-		profile, err := container.UserClient.GetUserProfile(ctx, container.SlackService, user)
-		if err != nil {
-			log.Errorf("unable to get user profile: %v", err)
-			http.Error(w, internalError, http.StatusInternalServerError)
+		if !checkExamSession(w, r, container, user.Email, log) {
 			return
 		}
 
-		// Check if user has access to this exam challenge (sequential access)
-		maxAllowedNestedID := profile.ExamChallengesSolved + 1
+		// Check if user has access to this exam challenge (sequential access within its track)
+		track, maxAllowedNestedID, ok := examTrackStage(w, r, container, user.Email, log)
+		if !ok {
+			return
+		}
+		log = log.WithFields(logrus.Fields{
+			"exam_track": track,
+		})
 		if nestedID > maxAllowedNestedID {
 			log.Infof("user attempted to access exam challenge beyond their progress: nested_id=%d, max_allowed=%d", nestedID, maxAllowedNestedID)
 			http.Error(w, notFoundError, http.StatusNotFound)
@@ -482,13 +691,17 @@ func GetExamChallenge(container *services.Container) http.HandlerFunc {
 		}
 
 		var challenge services.DetailedChallenge
+		var terms services.ExamTerms
+		var maxAttempts sql.NullInt64
 		err = container.DB.QueryRow(`
 			SELECT c.id, c.nested_id, c.name, c.description, c.category, c.point_reward_amount, c.file_asset, c.text_asset,
-			       CASE WHEN ucc.challenge_id IS NOT NULL THEN true ELSE false END as completed
+			       CASE WHEN ucc.challenge_id IS NOT NULL THEN true ELSE false END as completed,
+			       c.submission_cost, c.completion_reward, c.max_attempts, COALESCE(ea.attempts, 0)
 			FROM challenges c
 			LEFT JOIN user_challenges_completed ucc ON c.id = ucc.challenge_id AND ucc.user_email = $2
-			WHERE c.category = 'exam' AND c.nested_id = $1
-			`, nestedID, user.Email).Scan(
+			LEFT JOIN exam_attempts ea ON c.id = ea.challenge_id AND ea.user_email = $2
+			WHERE c.category = 'exam' AND c.exam_track = $3 AND c.nested_id = $1 AND NOT c.hidden AND (c.release_at IS NULL OR c.release_at <= NOW())
+			`, nestedID, user.Email, track).Scan(
 			&challenge.ID,
 			&challenge.NestedID,
 			&challenge.Name,
@@ -498,6 +711,10 @@ func GetExamChallenge(container *services.Container) http.HandlerFunc {
 			&challenge.FileAsset,
 			&challenge.TextAsset,
 			&challenge.Completed,
+			&terms.SubmissionCost,
+			&terms.CompletionReward,
+			&maxAttempts,
+			&terms.AttemptsUsed,
 		)
 
 		if err != nil {
@@ -510,19 +727,17 @@ func GetExamChallenge(container *services.Container) http.HandlerFunc {
 			return
 		}
 
+		// Render the text asset and sign download URLs for the challenge's files
+		if err := resolveChallengeAssets(ctx, container, &challenge, user.Email); err != nil {
+			log.Errorf("failed to resolve challenge assets: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
 		// Use nested_id as the exposed ID for exam challenges
 		challenge.ID = challenge.NestedID
-
-		// Transform file_asset to presigned URL if not empty
-		if challenge.FileAsset != nil && *challenge.FileAsset != "" {
-			presignedURL, err := container.AssetService.GetAsset(ctx, *challenge.FileAsset)
-			if err != nil {
-				log.Errorf("failed to get presigned URL for asset %s: %v", challenge.FileAsset, err)
-				http.Error(w, internalError, http.StatusInternalServerError)
-				return
-			}
-			challenge.FileAsset = &presignedURL
-		}
+		challenge.ExamTrack = track
+		challenge.Exam = terms.WithMaxAttempts(maxAttempts)
 
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(challenge); err != nil {
@@ -558,6 +773,44 @@ func SubmitExamChallenge(container *services.Container) http.HandlerFunc {
 			"exam_nested_id": nestedID,
 		})
 
+		// Retries send the same idempotency key to get the original result instead of paying again
+		idempotencyKey := r.Header.Get("Idempotency-Key")
+		if err := services.ValidateIdempotencyKey(idempotencyKey); err != nil {
+			log.Errorf("invalid idempotency key: %v", err)
+			utility.SendJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// A retry of a submission that was already charged gets its result even if the
+		// competition or the user's exam session has closed since
+		stored, found, err := container.UserClient.StoredExamSubmission(ctx, user.Email, idempotencyKey, examTrackName(r), nestedID)
+		if errors.Is(err, services.ErrIdempotencyKeyReused) {
+			log.Infof("exam flag submission rejected - %v", err)
+			utility.SendJSONError(w, "Idempotency key was already used for another challenge", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Errorf("failed to look up idempotency key: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+		if found {
+			log.Info("exam flag submission replayed from idempotency key")
+			if err := json.NewEncoder(w).Encode(stored); err != nil {
+				log.Errorf("encode error: %v", err)
+				http.Error(w, internalError, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		if !checkCompetitionOpen(w, r, container, log) {
+			return
+		}
+
+		if !checkExamSession(w, r, container, user.Email, log) {
+			return
+		}
+
 		type submission struct {
 			Flag string `json:"flag"`
 		}
@@ -578,16 +831,14 @@ func SubmitExamChallenge(container *services.Container) http.HandlerFunc {
 		}
 		sub.Flag = sanitizedFlag
 
-		// Get user profile to check ExamChallengesSolved
-		profile, err := container.UserClient.GetUserProfile(ctx, container.SlackService, user)
-		if err != nil {
-			log.Errorf("unable to get user profile: %v", err)
-			http.Error(w, internalError, http.StatusInternalServerError)
+		// Check if user has access to this exam challenge (sequential access within its track)
+		track, maxAllowedNestedID, ok := examTrackStage(w, r, container, user.Email, log)
+		if !ok {
 			return
 		}
-
-		// Check if user has access to this exam challenge (sequential access)
-		maxAllowedNestedID := profile.ExamChallengesSolved + 1
+		log = log.WithFields(logrus.Fields{
+			"exam_track": track,
+		})
 		if nestedID > maxAllowedNestedID {
 			log.Infof("user attempted to submit exam challenge beyond their progress: nested_id=%d, max_allowed=%d", nestedID, maxAllowedNestedID)
 			http.Error(w, notFoundError, http.StatusNotFound)
@@ -597,8 +848,9 @@ func SubmitExamChallenge(container *services.Container) http.HandlerFunc {
 		// Get the global challenge ID for this nested ID
 		var globalChallengeID int
 		err = container.DB.QueryRow(`
-			SELECT id FROM challenges WHERE category = 'exam' AND nested_id = $1
-		`, nestedID).Scan(&globalChallengeID)
+			SELECT id FROM challenges
+			WHERE category = 'exam' AND exam_track = $2 AND nested_id = $1 AND NOT hidden AND (release_at IS NULL OR release_at <= NOW())
+		`, nestedID, track).Scan(&globalChallengeID)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				http.Error(w, notFoundError, http.StatusNotFound)
@@ -609,120 +861,74 @@ func SubmitExamChallenge(container *services.Container) http.HandlerFunc {
 			return
 		}
 
-		completed, completedAt, err := container.ChallengeClient.CheckUserCompletedChallenge(user.Email, globalChallengeID)
+		// Get the challenge to validate the flag
+		flag, err := container.ChallengeClient.GetChallengeFlagAndReward(globalChallengeID)
 		if err != nil {
-			log.Errorf("Database error checking challenge completion: %v", err)
-			http.Error(w, internalError, http.StatusInternalServerError)
-			return
-		}
-
-		if completed {
-			log.Info("exam flag submission rejected - challenge already completed")
-			if err := json.NewEncoder(w).Encode(map[string]any{
-				"message":       "Challenge already completed",
-				"completed_at":  completedAt,
-				"tokens_burned": 0,
-			}); err != nil {
-				log.Errorf("encode error: %v", err)
+			if err == sql.ErrNoRows {
+				http.Error(w, notFoundError, http.StatusNotFound)
+			} else {
+				log.Errorf("database error fetching challenge: %v", err)
 				http.Error(w, internalError, http.StatusInternalServerError)
 			}
 			return
 		}
 
-		// Burn 1 token for every submission (whether correct or not)
-		tokensBurned, err := container.UserClient.BurnToken(ctx, user.Email, globalChallengeID)
+		// Validate the flag before anything is charged
+		result, err := container.ChallengeClient.ValidateFlag(ctx, flag, sub.Flag, user.Email)
 		if err != nil {
-			log.Errorf("failed to burn token: %v", err)
+			log.Errorf("failed to validate flag: %v", err)
 			http.Error(w, internalError, http.StatusInternalServerError)
 			return
 		}
-		if tokensBurned == 0 {
-			log.Info("exam flag submission rejected - insufficient tokens")
+
+		// Charge the submission cost and record the result (whether correct or not) in one transaction
+		outcome, err := container.UserClient.SubmitExamFlag(ctx, user.Email, globalChallengeID, sub.Flag, result, idempotencyKey)
+		if errors.Is(err, services.ErrIdempotencyKeyReused) {
+			log.Infof("exam flag submission rejected - %v", err)
+			utility.SendJSONError(w, "Idempotency key was already used for another challenge", http.StatusConflict)
+			return
+		}
+		if services.IsClientError(err) {
+			log.Infof("exam flag submission rejected - %v", err)
 			if err := json.NewEncoder(w).Encode(map[string]any{
-				"message": "Insufficient tokens. Each exam submission costs 1 token.",
+				"message":       err.Error(),
+				"tokens_burned": 0,
 			}); err != nil {
 				log.Errorf("encode error: %v", err)
 				http.Error(w, internalError, http.StatusInternalServerError)
 			}
 			return
 		}
-
-		// Get the challenge to validate the flag
-		flagValue, _, validationHandler, challengeName, _, err := container.ChallengeClient.GetChallengeFlagAndReward(globalChallengeID)
 		if err != nil {
-			// Refund the token on unexpected error
-			if refundErr := container.UserClient.RefundToken(ctx, user.Email, globalChallengeID); refundErr != nil {
-				log.Errorf("failed to refund token after challenge fetch error: %v", refundErr)
-			} else {
-				log.Info("refunded 1 token due to challenge fetch error")
-			}
-
-			if err == sql.ErrNoRows {
-				http.Error(w, notFoundError, http.StatusNotFound)
-			} else {
-				log.Errorf("database error fetching challenge: %v", err)
-				http.Error(w, internalError, http.StatusInternalServerError)
-			}
+			log.Errorf("failed to submit exam flag: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
 			return
 		}
 
-		// Validate the flag
-		isValid, customIncorrectMessage := container.ChallengeClient.ValidateFlag(sub.Flag, flagValue, validationHandler, user.Email)
-		if !isValid {
+		// Notifications were sent when the result was first recorded
+		switch {
+		case outcome.Replayed:
+			log.Info("exam flag submission replayed from idempotency key")
+		case outcome.Result == services.SubmissionIncorrect:
 			log.Info("exam flag submission failed - incorrect flag")
 
-			// Log the wrong flag attempt to the database
-			_, err = container.DB.Exec(`
-				INSERT INTO user_history_log (user_email, log, date) 
-				VALUES ($1, $2, NOW())
-			`, user.Email, fmt.Sprintf("Wrong flag attempt for exam challenge %d: %s", nestedID, sub.Flag))
-			if err != nil {
-				log.Errorf("failed to log wrong flag attempt: %v", err)
-			}
+			// Check if the submission is someone else's answer
+			recordFlagSharing(ctx, container, log, flag, sub.Flag, user)
 
-			// Use custom message if provided, otherwise use default
-			errorMessage := "Incorrect flag"
-			if customIncorrectMessage != "" {
-				errorMessage = customIncorrectMessage
-			}
-
-			container.SlackService.SendExamChallengeFailedAttempt(user, challengeName)
-			json.NewEncoder(w).Encode(map[string]any{
-				"message":       errorMessage,
-				"tokens_burned": 1,
-			})
-			return
-		}
+			container.SlackService.SendExamChallengeFailedAttempt(user, flag.Name)
+		case outcome.Result == services.SubmissionCorrect:
+			log.WithFields(logrus.Fields{
+				"tokens_earned": outcome.TokensEarned,
+				"tokens_burned": outcome.TokensBurned,
+				"points_earned": 0,
+			}).Info("exam challenge completed successfully")
 
-		// Complete exam challenge (awards token, increments counter, records completion)
-		err = container.UserClient.CompleteExamChallenge(ctx, user.Email, globalChallengeID)
-		if err != nil {
-			// Refund the token on unexpected error
-			if refundErr := container.UserClient.RefundToken(ctx, user.Email, globalChallengeID); refundErr != nil {
-				log.Errorf("failed to refund token after challenge completion error: %v", refundErr)
-			} else {
-				log.Info("refunded 1 token due to challenge completion error")
-			}
-			http.Error(w, internalError, http.StatusInternalServerError)
-			return
+			container.SlackService.SendExamChallengeCompletion(user, flag.Name)
+		default:
+			log.Info("exam flag submission rejected - challenge already completed")
 		}
 
-		log.WithFields(logrus.Fields{
-			"tokens_earned": 1,
-			"tokens_burned": 1,
-			"points_earned": 0,
-		}).Info("exam challenge completed successfully")
-
-		// Send Slack notification
-		container.SlackService.SendExamChallengeCompletion(user, challengeName)
-
-		// Return success response
-		if err := json.NewEncoder(w).Encode(map[string]any{
-			"message":       "Exam challenge completed successfully!",
-			"tokens_earned": 1,
-			"tokens_burned": 1,
-			"points_earned": 0,
-		}); err != nil {
+		if err := json.NewEncoder(w).Encode(outcome); err != nil {
 			log.Errorf("encode error: %v", err)
 			http.Error(w, internalError, http.StatusInternalServerError)
 		}
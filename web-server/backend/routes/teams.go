@@ -0,0 +1,211 @@
+package routes
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/obelisk/example-ctf/services"
+	"github.com/obelisk/example-ctf/utility"
+)
+
+const (
+	defaultTeamLeaderboardLimit = 25
+	maxTeamLeaderboardLimit     = 100
+)
+
+// CreateTeamRequest represents the request body for creating a team
+type CreateTeamRequest struct {
+	Name string `json:"name"`
+}
+
+// JoinTeamRequest represents the request body for joining a team
+type JoinTeamRequest struct {
+	InviteCode string `json:"invite_code"`
+}
+
+// sendTeamError maps team service errors onto API responses
+func sendTeamError(w http.ResponseWriter, log *logrus.Entry, err error) {
+	switch {
+	case services.IsClientError(err):
+		log.Errorf("team request denied: %v", err)
+		utility.SendJSONError(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, sql.ErrNoRows):
+		utility.SendJSONError(w, "You are not in a team", http.StatusNotFound)
+	default:
+		log.Errorf("internal error handling team request: %v", err)
+		utility.SendJSONError(w, internalError, http.StatusInternalServerError)
+	}
+}
+
+// sendTeam writes a team as the response
+func sendTeam(w http.ResponseWriter, log *logrus.Entry, status int, team *services.Team) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(team); err != nil {
+		log.Errorf("encode error: %v", err)
+		http.Error(w, internalError, http.StatusInternalServerError)
+	}
+}
+
+// GetTeam returns the current user's team, its members and shared progress
+func GetTeam(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		log.Info("user requested team")
+
+		team, err := container.Teams.GetForUser(ctx, user.Email)
+		if err != nil {
+			sendTeamError(w, log, err)
+			return
+		}
+
+		sendTeam(w, log, http.StatusOK, team)
+	})
+}
+
+// CreateTeam creates a team with the current user as its first member
+func CreateTeam(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		var req CreateTeamRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Errorf("failed to decode team request: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+
+		team, err := container.Teams.Create(ctx, user.Email, req.Name)
+		if err != nil {
+			sendTeamError(w, log, err)
+			return
+		}
+
+		log.WithFields(logrus.Fields{
+			"team_id": team.ID,
+		}).Info("user created team")
+
+		sendTeam(w, log, http.StatusCreated, team)
+	})
+}
+
+// JoinTeam adds the current user to the team with the given invite code
+func JoinTeam(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		var req JoinTeamRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Errorf("failed to decode join request: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+
+		team, err := container.Teams.Join(ctx, user.Email, req.InviteCode)
+		if err != nil {
+			sendTeamError(w, log, err)
+			return
+		}
+
+		log.WithFields(logrus.Fields{
+			"team_id": team.ID,
+		}).Info("user joined team")
+
+		sendTeam(w, log, http.StatusOK, team)
+	})
+}
+
+// LeaveTeam removes the current user from their team
+func LeaveTeam(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		if err := container.Teams.Leave(ctx, user.Email); err != nil {
+			sendTeamError(w, log, err)
+			return
+		}
+
+		log.Info("user left team")
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]any{
+			"message": "Left team",
+		}); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
+
+// GetTeamLeaderboard returns a page of the team leaderboard
+func GetTeamLeaderboard(container *services.Container) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := services.GetLogger(ctx)
+
+		user, ok := container.Auth.GetUserFromContext(ctx)
+		if !ok {
+			log.Errorf("missing user context after authenticated middleware")
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+
+		limit, offset, err := parsePagination(r, defaultTeamLeaderboardLimit, maxTeamLeaderboardLimit)
+		if err != nil {
+			log.Errorf("invalid pagination: %v", err)
+			utility.SendJSONError(w, invalidRequestError, http.StatusBadRequest)
+			return
+		}
+
+		log.Info("user requested team leaderboard")
+
+		page, err := container.Teams.GetLeaderboard(ctx, user.Email, limit, offset)
+		if err != nil {
+			sendTeamError(w, log, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(page); err != nil {
+			log.Errorf("encode error: %v", err)
+			http.Error(w, internalError, http.StatusInternalServerError)
+		}
+	})
+}
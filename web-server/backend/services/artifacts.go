@@ -0,0 +1,461 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/obelisk/example-ctf/config"
+)
+
+// ArtifactInput carries everything a generator needs to build a user's artifact
+type ArtifactInput struct {
+	ChallengeID int
+	UserEmail   string
+	// Flag is the flag the user is expected to submit, derived by the challenge's validator
+	Flag string
+	// Params is the generator specific artifact_params blob stored for the challenge
+	Params json.RawMessage
+}
+
+// Artifact is the output of a generator. A generator may produce a file, a text
+// asset or both; empty fields are not shown to the user.
+type Artifact struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+	Text        string
+}
+
+// ArtifactGenerator builds a per-user artifact for a challenge. Generators must be
+// deterministic: the same input always produces the same artifact.
+type ArtifactGenerator interface {
+	// CheckParams verifies a challenge's artifact parameters before they are used
+	CheckParams(params json.RawMessage) error
+	// Generate builds the artifact for a single user
+	Generate(input ArtifactInput) (Artifact, error)
+}
+
+// ArtifactGeneratorRegistry maps artifact generator names to their generators
+type ArtifactGeneratorRegistry struct {
+	generators map[string]ArtifactGenerator
+	mutex      sync.RWMutex
+}
+
+// NewArtifactGeneratorRegistry creates an empty generator registry
+func NewArtifactGeneratorRegistry() *ArtifactGeneratorRegistry {
+	return &ArtifactGeneratorRegistry{
+		generators: make(map[string]ArtifactGenerator),
+	}
+}
+
+// Register adds a generator under a name
+func (gr *ArtifactGeneratorRegistry) Register(name string, generator ArtifactGenerator) error {
+	gr.mutex.Lock()
+	defer gr.mutex.Unlock()
+
+	if name == "" {
+		return fmt.Errorf("artifact generator name cannot be empty")
+	}
+	if _, exists := gr.generators[name]; exists {
+		return fmt.Errorf("artifact generator %s already registered", name)
+	}
+	gr.generators[name] = generator
+	return nil
+}
+
+// Get returns the generator registered under a name
+func (gr *ArtifactGeneratorRegistry) Get(name string) (ArtifactGenerator, bool) {
+	gr.mutex.RLock()
+	defer gr.mutex.RUnlock()
+
+	generator, exists := gr.generators[name]
+	return generator, exists
+}
+
+// Names returns the sorted names of all registered generators
+func (gr *ArtifactGeneratorRegistry) Names() []string {
+	gr.mutex.RLock()
+	defer gr.mutex.RUnlock()
+
+	names := make([]string, 0, len(gr.generators))
+	for name := range gr.generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// registerBuiltinGenerators registers the artifact generators shipped with the backend
+func registerBuiltinGenerators(registry *ArtifactGeneratorRegistry, cfg *config.Config) error {
+	secret := []byte(cfg.Flags.HMACSecret)
+	builtins := map[string]ArtifactGenerator{
+		"RepeatingKeyXor": repeatingKeyXorGenerator{secret: secret},
+		"Caesar":          caesarGenerator{secret: secret},
+	}
+
+	for name, generator := range builtins {
+		if err := registry.Register(name, generator); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// artifactKey derives key material unique to a user and challenge
+func artifactKey(secret []byte, input ArtifactInput) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(fmt.Sprintf("artifact:%s:%d", strings.ToLower(input.UserEmail), input.ChallengeID)))
+	return mac.Sum(nil)
+}
+
+// repeatingKeyXorParams configures the RepeatingKeyXor generator
+type repeatingKeyXorParams struct {
+	// Filename is the name of the downloaded ciphertext
+	Filename string `json:"filename"`
+	// KeyLength is the number of key bytes, which repeat over the flag
+	KeyLength int `json:"key_length"`
+}
+
+const (
+	defaultXorFilename  = "ciphertext.bin"
+	defaultXorKeyLength = 4
+	maxXorKeyLength     = sha256.Size
+)
+
+// repeatingKeyXorGenerator produces a file holding the user's flag XORed with a
+// short key derived for that user, so no two players download the same ciphertext
+type repeatingKeyXorGenerator struct {
+	secret []byte
+}
+
+// params decodes the generator parameters, applying defaults
+func (g repeatingKeyXorGenerator) params(raw json.RawMessage) (repeatingKeyXorParams, error) {
+	parsed, err := decodeParams[repeatingKeyXorParams](raw)
+	if err != nil {
+		return parsed, err
+	}
+	if parsed.Filename == "" {
+		parsed.Filename = defaultXorFilename
+	}
+	if parsed.KeyLength == 0 {
+		parsed.KeyLength = defaultXorKeyLength
+	}
+	if parsed.KeyLength < 0 || parsed.KeyLength > maxXorKeyLength {
+		return parsed, fmt.Errorf("key_length must be between 1 and %d", maxXorKeyLength)
+	}
+	if err := validateAttachmentFilename(parsed.Filename); err != nil {
+		return parsed, err
+	}
+	return parsed, nil
+}
+
+func (g repeatingKeyXorGenerator) CheckParams(params json.RawMessage) error {
+	if len(g.secret) == 0 {
+		return fmt.Errorf("FLAG_HMAC_SECRET must be set to use RepeatingKeyXor")
+	}
+	_, err := g.params(params)
+	return err
+}
+
+func (g repeatingKeyXorGenerator) Generate(input ArtifactInput) (Artifact, error) {
+	if len(g.secret) == 0 {
+		return Artifact{}, fmt.Errorf("FLAG_HMAC_SECRET is not set")
+	}
+	params, err := g.params(input.Params)
+	if err != nil {
+		return Artifact{}, err
+	}
+
+	key := artifactKey(g.secret, input)[:params.KeyLength]
+	data := []byte(input.Flag)
+	for i := range data {
+		data[i] ^= key[i%len(key)]
+	}
+
+	return Artifact{
+		Filename:    params.Filename,
+		ContentType: "application/octet-stream",
+		Data:        data,
+	}, nil
+}
+
+// caesarParams configures the Caesar generator
+type caesarParams struct {
+	// Template is the text asset shown to the user, with {{ciphertext}} replaced
+	Template string `json:"template"`
+}
+
+const caesarCiphertextPlaceholder = "{{ciphertext}}"
+
+// caesarGenerator produces a text asset holding the user's flag with its letters
+// rotated by a shift derived for that user
+type caesarGenerator struct {
+	secret []byte
+}
+
+func (g caesarGenerator) CheckParams(params json.RawMessage) error {
+	if len(g.secret) == 0 {
+		return fmt.Errorf("FLAG_HMAC_SECRET must be set to use Caesar")
+	}
+	parsed, err := decodeParams[caesarParams](params)
+	if err != nil {
+		return err
+	}
+	if parsed.Template != "" && !strings.Contains(parsed.Template, caesarCiphertextPlaceholder) {
+		return fmt.Errorf("template must contain %s", caesarCiphertextPlaceholder)
+	}
+	return nil
+}
+
+func (g caesarGenerator) Generate(input ArtifactInput) (Artifact, error) {
+	if len(g.secret) == 0 {
+		return Artifact{}, fmt.Errorf("FLAG_HMAC_SECRET is not set")
+	}
+	params, err := decodeParams[caesarParams](input.Params)
+	if err != nil {
+		return Artifact{}, err
+	}
+	if params.Template == "" {
+		params.Template = caesarCiphertextPlaceholder
+	}
+
+	// Shift between 1 and 25 so the ciphertext never equals the flag
+	shift := rune(artifactKey(g.secret, input)[0]%25) + 1
+	ciphertext := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return 'a' + (r-'a'+shift)%26
+		case r >= 'A' && r <= 'Z':
+			return 'A' + (r-'A'+shift)%26
+		}
+		return r
+	}, input.Flag)
+
+	return Artifact{Text: strings.ReplaceAll(params.Template, caesarCiphertextPlaceholder, ciphertext)}, nil
+}
+
+// UserArtifact is a user's generated artifact as shown with the challenge
+type UserArtifact struct {
+	// Text replaces the challenge's text asset when set
+	Text *string
+	// File is added to the challenge's attachments when set
+	File *Attachment
+}
+
+// ArtifactService generates per-user challenge artifacts and caches them in the asset store
+type ArtifactService struct {
+	db         *sql.DB
+	assets     *AssetService
+	generators *ArtifactGeneratorRegistry
+	validators *ValidatorRegistry
+}
+
+// NewArtifactService creates a new artifact service
+func NewArtifactService(db *sql.DB, assets *AssetService, generators *ArtifactGeneratorRegistry, validators *ValidatorRegistry) *ArtifactService {
+	return &ArtifactService{
+		db:         db,
+		assets:     assets,
+		generators: generators,
+		validators: validators,
+	}
+}
+
+// artifactFingerprint identifies the inputs an artifact was generated from, so a
+// cached artifact is regenerated when the generator, its params or the flag change
+func artifactFingerprint(generator string, params json.RawMessage, flag string) string {
+	hasher := sha256.New()
+	hasher.Write([]byte(generator))
+	hasher.Write([]byte{0})
+	hasher.Write(bytes.TrimSpace(params))
+	hasher.Write([]byte{0})
+	hasher.Write([]byte(flag))
+	return hex.EncodeToString(hasher.Sum(nil))[:32]
+}
+
+// artifactPath is the asset store key for a generated file. The fingerprint keeps
+// paths unguessable without exposing the user's email.
+func artifactPath(challengeID int, fingerprint, filename string) string {
+	return fmt.Sprintf("artifacts/%d/%s/%s", challengeID, fingerprint, filename)
+}
+
+// ForUser returns the user's artifact for a challenge, generating and caching it
+// on first use. Returns nil if the challenge has no artifact generator.
+func (as *ArtifactService) ForUser(ctx context.Context, challengeID int, userEmail string) (*UserArtifact, error) {
+	var generatorName string
+	var artifactParams []byte
+	flag := ChallengeFlag{ChallengeID: challengeID}
+	var validationParams []byte
+	err := as.db.QueryRowContext(ctx, `
+		SELECT c.artifact_generator, c.artifact_params, f.flag_value, f.validation_handler, f.validation_params
+		FROM challenges c
+		JOIN flags f ON c.id = f.challenge_id
+		WHERE c.id = $1
+	`, challengeID).Scan(&generatorName, &artifactParams, &flag.FlagValue, &flag.ValidationHandler, &validationParams)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query artifact generator: %w", err)
+	}
+	if generatorName == "" {
+		return nil, nil
+	}
+
+	generator, exists := as.generators.Get(generatorName)
+	if !exists {
+		return nil, fmt.Errorf("unknown artifact generator %s for challenge %d", generatorName, challengeID)
+	}
+
+	validator, exists := as.validators.Get(flag.ValidationHandler)
+	if !exists {
+		return nil, fmt.Errorf("unknown validation handler %s for challenge %d", flag.ValidationHandler, challengeID)
+	}
+	deriver, ok := validator.(FlagDeriver)
+	if !ok {
+		return nil, fmt.Errorf("validation handler %s cannot derive flags for artifacts", flag.ValidationHandler)
+	}
+
+	derived, err := deriver.DeriveFlag(ValidationInput{
+		ChallengeID: challengeID,
+		UserEmail:   userEmail,
+		Expected:    flag.FlagValue,
+		Params:      validationParams,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive flag: %w", err)
+	}
+
+	fingerprint := artifactFingerprint(generatorName, artifactParams, derived)
+
+	artifact, found, err := as.cached(ctx, challengeID, userEmail, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		artifact, err = as.generate(ctx, generator, ArtifactInput{
+			ChallengeID: challengeID,
+			UserEmail:   userEmail,
+			Flag:        derived,
+			Params:      artifactParams,
+		}, fingerprint)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if artifact.File != nil {
+		artifact.File.URL, err = as.assets.GetAsset(ctx, artifact.File.AssetPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get URL for artifact %s: %w", artifact.File.Filename, err)
+		}
+	}
+
+	return artifact, nil
+}
+
+// cached returns the user's stored artifact if it was generated from the same inputs
+func (as *ArtifactService) cached(ctx context.Context, challengeID int, userEmail, fingerprint string) (*UserArtifact, bool, error) {
+	var storedFingerprint string
+	var text, filename, assetPath, checksum, contentType sql.NullString
+	var size sql.NullInt64
+	var createdAt sql.NullTime
+	err := as.db.QueryRowContext(ctx, `
+		SELECT fingerprint, text_asset, filename, asset_path, size_bytes, sha256, content_type, created_at
+		FROM user_artifacts
+		WHERE challenge_id = $1 AND user_email = $2
+	`, challengeID, userEmail).Scan(&storedFingerprint, &text, &filename, &assetPath, &size, &checksum, &contentType, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to query cached artifact: %w", err)
+	}
+	if storedFingerprint != fingerprint {
+		return nil, false, nil
+	}
+
+	artifact := &UserArtifact{}
+	if text.Valid {
+		artifact.Text = &text.String
+	}
+	if assetPath.Valid {
+		artifact.File = &Attachment{
+			Filename:    filename.String,
+			Size:        size.Int64,
+			SHA256:      checksum.String,
+			ContentType: contentType.String,
+			CreatedAt:   createdAt.Time,
+			AssetPath:   assetPath.String,
+		}
+	}
+
+	return artifact, true, nil
+}
+
+// generate runs a generator, uploads any file it produces and caches the result
+func (as *ArtifactService) generate(ctx context.Context, generator ArtifactGenerator, input ArtifactInput, fingerprint string) (*UserArtifact, error) {
+	generated, err := generator.Generate(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate artifact for challenge %d: %w", input.ChallengeID, err)
+	}
+
+	artifact := &UserArtifact{}
+	if generated.Text != "" {
+		artifact.Text = &generated.Text
+	}
+
+	var filename, assetPath, checksum, contentType *string
+	var size *int64
+	if len(generated.Data) > 0 {
+		if err := validateAttachmentFilename(generated.Filename); err != nil {
+			return nil, fmt.Errorf("artifact generator returned an invalid filename: %w", err)
+		}
+		if generated.ContentType == "" {
+			generated.ContentType = "application/octet-stream"
+		}
+
+		sum := sha256.Sum256(generated.Data)
+		artifact.File = &Attachment{
+			Filename:    generated.Filename,
+			Size:        int64(len(generated.Data)),
+			SHA256:      hex.EncodeToString(sum[:]),
+			ContentType: generated.ContentType,
+			AssetPath:   artifactPath(input.ChallengeID, fingerprint, generated.Filename),
+		}
+
+		if err := as.assets.PutAsset(ctx, artifact.File.AssetPath, bytes.NewReader(generated.Data), generated.ContentType); err != nil {
+			return nil, err
+		}
+
+		filename = &artifact.File.Filename
+		assetPath = &artifact.File.AssetPath
+		checksum = &artifact.File.SHA256
+		contentType = &artifact.File.ContentType
+		size = &artifact.File.Size
+	}
+
+	_, err = as.db.ExecContext(ctx, `
+		INSERT INTO user_artifacts (challenge_id, user_email, fingerprint, text_asset, filename, asset_path, size_bytes, sha256, content_type, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
+		ON CONFLICT (challenge_id, user_email) DO UPDATE SET
+		    fingerprint = EXCLUDED.fingerprint,
+		    text_asset = EXCLUDED.text_asset,
+		    filename = EXCLUDED.filename,
+		    asset_path = EXCLUDED.asset_path,
+		    size_bytes = EXCLUDED.size_bytes,
+		    sha256 = EXCLUDED.sha256,
+		    content_type = EXCLUDED.content_type,
+		    created_at = EXCLUDED.created_at
+	`, input.ChallengeID, input.UserEmail, fingerprint, artifact.Text, filename, assetPath, size, checksum, contentType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cache artifact: %w", err)
+	}
+
+	return artifact, nil
+}
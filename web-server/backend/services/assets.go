@@ -2,54 +2,75 @@ package services
 
 import (
 	"context"
-	"fmt"
+	"io"
 	"sync"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	awsConfig "github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/obelisk/example-ctf/config"
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	// assetURLLifetime is how long a generated asset URL stays valid
+	assetURLLifetime = 60 * time.Minute
+	// assetURLCacheLifetime is how long a URL is reused, leaving clients time to download
+	assetURLCacheLifetime = 45 * time.Minute
+)
+
+// AssetStore stores challenge files and hands out expiring download URLs for them
+type AssetStore interface {
+	// SignedURL returns a URL that downloads the asset until it expires
+	SignedURL(ctx context.Context, path string, expires time.Duration) (string, error)
+	// Put uploads an asset, replacing any existing asset at the same path
+	Put(ctx context.Context, path string, body io.Reader, contentType string) error
+	// Get opens an asset for reading
+	Get(ctx context.Context, path string) (io.ReadCloser, error)
+}
+
 type cachedAsset struct {
 	url       string
 	expiresAt time.Time
 }
 
-// AssetService is used to manage access to static assets stored in an S3 bucket
+// AssetService is used to manage access to static assets held in an AssetStore
 type AssetService struct {
-	presignerClient *s3.PresignClient
-	awsCfg          awsConfig.Config
-	cfg             *config.Config
-	cache           map[string]*cachedAsset
-	mutex           sync.RWMutex
+	store AssetStore
+	cfg   *config.Config
+	cache map[string]*cachedAsset
+	mutex sync.RWMutex
 }
 
-// NewAssetService initializes the AssetService with the configured AWS credentials
+// NewAssetService initializes the AssetService with the asset store selected in config
 func NewAssetService(cfg *config.Config) (*AssetService, error) {
-	// Load AWS config (env vars, shared config file, EC2/ECS metadata, etc.)
-	awsCfg, err := awsConfig.LoadDefaultConfig(context.TODO())
+	var store AssetStore
+	var err error
+
+	switch cfg.AwsConfig.Backend {
+	case "local":
+		store, err = newLocalAssetStore(cfg.AwsConfig)
+	case "minio":
+		store, err = newS3AssetStore(cfg.AwsConfig, true)
+	default:
+		store, err = newS3AssetStore(cfg.AwsConfig, false)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("unable to load AWS SDK config: %w", err)
+		return nil, err
 	}
 
-	// Create an S3 client…
-	client := s3.NewFromConfig(awsCfg)
-	// …and wrap it in a presigner
-	presigner := s3.NewPresignClient(client)
-
 	return &AssetService{
-		presignerClient: presigner,
-		awsCfg:          awsCfg,
-		cfg:             cfg,
-		cache:           make(map[string]*cachedAsset),
+		store: store,
+		cfg:   cfg,
+		cache: make(map[string]*cachedAsset),
 	}, nil
 }
 
-// GetAsset will return an S3 presigned URL for the requested asset.
-// The presigned URLs are cached in memory.
+// Store returns the underlying asset store
+func (s *AssetService) Store() AssetStore {
+	return s.store
+}
+
+// GetAsset will return an expiring download URL for the requested asset.
+// The URLs are cached in memory.
 func (s *AssetService) GetAsset(ctx context.Context, path string) (string, error) {
 	// Check cache first
 	s.mutex.RLock()
@@ -64,7 +85,7 @@ func (s *AssetService) GetAsset(ctx context.Context, path string) (string, error
 	}
 	s.mutex.RUnlock()
 
-	// Cache miss or expired - generate new presigned URL
+	// Cache miss or expired - generate new URL
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -78,25 +99,16 @@ func (s *AssetService) GetAsset(ctx context.Context, path string) (string, error
 		return cached.url, nil
 	}
 
-	// Generate new presigned URL
-	presignResult, err := s.presignerClient.PresignGetObject(
-		ctx,
-		&s3.GetObjectInput{
-			Bucket: aws.String(s.cfg.AwsConfig.BucketName),
-			Key:    aws.String(path),
-		},
-		func(opts *s3.PresignOptions) {
-			opts.Expires = 60 * time.Minute
-		},
-	)
+	// Generate new URL
+	url, err := s.store.SignedURL(ctx, path, assetURLLifetime)
 	if err != nil {
-		return "", fmt.Errorf("failed to PresignGetObject: %v", err)
+		return "", err
 	}
 
-	// Cache the presigned URL
-	expiresAt := time.Now().Add(45 * time.Minute)
+	// Cache the URL
+	expiresAt := time.Now().Add(assetURLCacheLifetime)
 	s.cache[path] = &cachedAsset{
-		url:       presignResult.URL,
+		url:       url,
 		expiresAt: expiresAt,
 	}
 
@@ -106,5 +118,30 @@ func (s *AssetService) GetAsset(ctx context.Context, path string) (string, error
 		"expiresAt": expiresAt,
 	}).Debug("Asset URL generated and cached")
 
-	return presignResult.URL, nil
+	return url, nil
 }
+
+// PutAsset uploads an asset under the given path, replacing any existing
+// asset, and drops any cached URL for it
+func (s *AssetService) PutAsset(ctx context.Context, path string, body io.Reader, contentType string) error {
+	if err := s.store.Put(ctx, path, body, contentType); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	delete(s.cache, path)
+	s.mutex.Unlock()
+
+	return nil
+}
+
+// OpenAsset opens an asset in the store for reading
+func (s *AssetService) OpenAsset(ctx context.Context, path string) (io.ReadCloser, error) {
+	return s.store.Get(ctx, path)
+}
+
+// ensure the stores implement AssetStore
+var (
+	_ AssetStore = (*s3AssetStore)(nil)
+	_ AssetStore = (*LocalAssetStore)(nil)
+)
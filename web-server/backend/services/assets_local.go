@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/obelisk/example-ctf/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// LocalAssetRoute is the path prefix the backend serves local assets under
+const LocalAssetRoute = "/assets/"
+
+// ErrInvalidAssetSignature is returned for local asset URLs that are expired or have been tampered with
+var ErrInvalidAssetSignature = errors.New("invalid or expired asset signature")
+
+// LocalAssetStore keeps assets in a local directory. Downloads go through the
+// backend using URLs signed with an HMAC that expire like S3 presigned URLs.
+type LocalAssetStore struct {
+	root   *os.Root
+	secret []byte
+}
+
+// newLocalAssetStore opens the configured asset directory, creating it if needed
+func newLocalAssetStore(cfg config.AwsConfig) (*LocalAssetStore, error) {
+	if err := os.MkdirAll(cfg.LocalDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create asset directory: %w", err)
+	}
+
+	// os.Root refuses paths that escape the directory, including through symlinks
+	root, err := os.OpenRoot(cfg.LocalDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open asset directory: %w", err)
+	}
+
+	secret := []byte(cfg.SigningSecret)
+	if len(secret) == 0 {
+		log.Warn("ASSET_SIGNING_SECRET is not set, asset URLs will not survive a restart or work across replicas")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate asset signing secret: %w", err)
+		}
+	}
+
+	return &LocalAssetStore{root: root, secret: secret}, nil
+}
+
+// cleanAssetPath normalises an asset path and rejects paths outside the store
+func cleanAssetPath(assetPath string) (string, error) {
+	cleaned := path.Clean("/" + assetPath)[1:]
+	if cleaned == "" || cleaned != strings.TrimPrefix(assetPath, "/") {
+		return "", fmt.Errorf("invalid asset path %q", assetPath)
+	}
+	return cleaned, nil
+}
+
+// sign computes the signature of an asset path and expiry
+func (s *LocalAssetStore) sign(assetPath string, expires int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(fmt.Sprintf("%s\n%d", assetPath, expires)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *LocalAssetStore) SignedURL(ctx context.Context, assetPath string, expires time.Duration) (string, error) {
+	cleaned, err := cleanAssetPath(assetPath)
+	if err != nil {
+		return "", err
+	}
+
+	expiresAt := time.Now().Add(expires).Unix()
+
+	segments := strings.Split(cleaned, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expiresAt, 10))
+	query.Set("signature", s.sign(cleaned, expiresAt))
+
+	return LocalAssetRoute + strings.Join(segments, "/") + "?" + query.Encode(), nil
+}
+
+func (s *LocalAssetStore) Put(ctx context.Context, assetPath string, body io.Reader, contentType string) error {
+	cleaned, err := cleanAssetPath(assetPath)
+	if err != nil {
+		return err
+	}
+
+	// Create parent directories one at a time, as os.Root has no MkdirAll
+	dir := path.Dir(cleaned)
+	if dir != "." {
+		current := ""
+		for _, segment := range strings.Split(dir, "/") {
+			current = path.Join(current, segment)
+			if err := s.root.Mkdir(current, 0o755); err != nil && !errors.Is(err, fs.ErrExist) {
+				return fmt.Errorf("failed to create asset directory: %w", err)
+			}
+		}
+	}
+
+	file, err := s.root.OpenFile(cleaned, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create asset: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, body); err != nil {
+		return fmt.Errorf("failed to write asset: %w", err)
+	}
+	return file.Close()
+}
+
+func (s *LocalAssetStore) Get(ctx context.Context, assetPath string) (io.ReadCloser, error) {
+	cleaned, err := cleanAssetPath(assetPath)
+	if err != nil {
+		return nil, err
+	}
+	return s.root.Open(cleaned)
+}
+
+// Open verifies a signed URL's expiry and signature and opens the asset
+func (s *LocalAssetStore) Open(assetPath, expires, signature string) (*os.File, error) {
+	cleaned, err := cleanAssetPath(assetPath)
+	if err != nil {
+		return nil, ErrInvalidAssetSignature
+	}
+
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return nil, ErrInvalidAssetSignature
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(s.sign(cleaned, expiresAt))) {
+		return nil, ErrInvalidAssetSignature
+	}
+
+	return s.root.Open(cleaned)
+}
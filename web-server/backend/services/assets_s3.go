@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/obelisk/example-ctf/config"
+)
+
+// s3AssetStore keeps assets in an S3 bucket and hands out presigned URLs.
+// With a custom endpoint it talks to S3 compatible servers such as MinIO.
+type s3AssetStore struct {
+	client          *s3.Client
+	presignerClient *s3.PresignClient
+	bucket          string
+}
+
+// newS3AssetStore creates an S3 store. When customEndpoint is set, requests go to
+// the configured endpoint using path-style addressing, as MinIO expects.
+func newS3AssetStore(cfg config.AwsConfig, customEndpoint bool) (*s3AssetStore, error) {
+	var loadOptions []func(*awsConfig.LoadOptions) error
+	if cfg.Region != "" {
+		loadOptions = append(loadOptions, awsConfig.WithRegion(cfg.Region))
+	} else if customEndpoint {
+		// MinIO ignores the region, but the SDK needs one to sign requests
+		loadOptions = append(loadOptions, awsConfig.WithRegion("us-east-1"))
+	}
+
+	// Load AWS config (env vars, shared config file, EC2/ECS metadata, etc.)
+	awsCfg, err := awsConfig.LoadDefaultConfig(context.TODO(), loadOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load AWS SDK config: %w", err)
+	}
+
+	// Create an S3 client…
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if customEndpoint {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+			o.UsePathStyle = true
+		}
+	})
+	// …and wrap it in a presigner
+	presigner := s3.NewPresignClient(client)
+
+	return &s3AssetStore{
+		client:          client,
+		presignerClient: presigner,
+		bucket:          cfg.BucketName,
+	}, nil
+}
+
+func (s *s3AssetStore) SignedURL(ctx context.Context, path string, expires time.Duration) (string, error) {
+	presignResult, err := s.presignerClient.PresignGetObject(
+		ctx,
+		&s3.GetObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(path),
+		},
+		func(opts *s3.PresignOptions) {
+			opts.Expires = expires
+		},
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to PresignGetObject: %v", err)
+	}
+	return presignResult.URL, nil
+}
+
+func (s *s3AssetStore) Put(ctx context.Context, path string, body io.Reader, contentType string) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(path),
+		Body:        body,
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to PutObject: %v", err)
+	}
+	return nil
+}
+
+func (s *s3AssetStore) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(path),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to GetObject: %v", err)
+	}
+	return result.Body, nil
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// maxAttachmentFilenameLength bounds attachment file names
+const maxAttachmentFilenameLength = 128
+
+// Attachment is a file attached to a challenge. SHA256 lets players verify the download.
+type Attachment struct {
+	Filename    string    `json:"filename"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	ContentType string    `json:"content_type"`
+	URL         string    `json:"url,omitempty"`
+	CreatedAt   time.Time `json:"-"`
+	// AssetPath is the key of the file in the asset store
+	AssetPath string `json:"-"`
+}
+
+// AttachmentService manages the files attached to challenges
+type AttachmentService struct {
+	db     *sql.DB
+	assets *AssetService
+}
+
+// NewAttachmentService creates a new attachment service
+func NewAttachmentService(db *sql.DB, assets *AssetService) *AttachmentService {
+	return &AttachmentService{db: db, assets: assets}
+}
+
+// attachmentPath is the asset store key for a challenge attachment. Keys include the
+// checksum, so an upload never overwrites the file an existing row points to.
+func attachmentPath(challengeID int, sha256Hex, filename string) string {
+	return fmt.Sprintf("challenges/%d/%s/%s", challengeID, sha256Hex, filename)
+}
+
+// validateAttachmentFilename rejects names that could escape the challenge's directory
+// Error messages from this function can be returned to the client
+func validateAttachmentFilename(filename string) error {
+	if filename == "" || len(filename) > maxAttachmentFilenameLength {
+		return ClientError{Message: fmt.Sprintf("Attachment filename must be between 1 and %d characters", maxAttachmentFilenameLength)}
+	}
+	if path.Base(filename) != filename || strings.ContainsAny(filename, "\\\x00") || strings.HasPrefix(filename, ".") {
+		return ClientError{Message: fmt.Sprintf("Invalid attachment filename: %s", filename)}
+	}
+	return nil
+}
+
+// List returns a challenge's attachments ordered by filename, without download URLs
+func (as *AttachmentService) List(ctx context.Context, challengeID int) ([]Attachment, error) {
+	rows, err := as.db.QueryContext(ctx, `
+		SELECT filename, asset_path, size_bytes, sha256, content_type, created_at
+		FROM challenge_assets
+		WHERE challenge_id = $1
+		ORDER BY filename
+	`, challengeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attachments: %w", err)
+	}
+	defer rows.Close()
+
+	attachments := make([]Attachment, 0)
+	for rows.Next() {
+		var attachment Attachment
+		if err := rows.Scan(
+			&attachment.Filename,
+			&attachment.AssetPath,
+			&attachment.Size,
+			&attachment.SHA256,
+			&attachment.ContentType,
+			&attachment.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		attachments = append(attachments, attachment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return attachments, nil
+}
+
+// ListWithURLs returns a challenge's attachments with expiring download URLs
+func (as *AttachmentService) ListWithURLs(ctx context.Context, challengeID int) ([]Attachment, error) {
+	attachments, err := as.List(ctx, challengeID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range attachments {
+		attachments[i].URL, err = as.assets.GetAsset(ctx, attachments[i].AssetPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get URL for attachment %s: %w", attachments[i].Filename, err)
+		}
+	}
+
+	return attachments, nil
+}
+
+// Put uploads a file and attaches it to a challenge, replacing any attachment
+// with the same filename. The size and checksum are computed from the content.
+// Replaced files are left in the asset store.
+// Returns sql.ErrNoRows if the challenge doesn't exist
+func (as *AttachmentService) Put(ctx context.Context, adminEmail string, challengeID int, filename, contentType string, body io.ReadSeeker) (*Attachment, error) {
+	if err := validateAttachmentFilename(filename); err != nil {
+		return nil, err
+	}
+
+	var exists bool
+	err := as.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM challenges WHERE id = $1)`, challengeID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check challenge: %w", err)
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+
+	// Hash the content first, then rewind to upload it
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind attachment: %w", err)
+	}
+
+	attachment := &Attachment{
+		Filename:    filename,
+		Size:        size,
+		SHA256:      hex.EncodeToString(hasher.Sum(nil)),
+		ContentType: contentType,
+	}
+	attachment.AssetPath = attachmentPath(challengeID, attachment.SHA256, filename)
+
+	// Upload under a new key before switching the row to it; if the transaction
+	// fails the row keeps pointing at the previous file, which is untouched
+	if err := as.assets.PutAsset(ctx, attachment.AssetPath, body, contentType); err != nil {
+		return nil, err
+	}
+
+	// Start transaction
+	tx, err := as.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO challenge_assets (challenge_id, filename, asset_path, size_bytes, sha256, content_type, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (challenge_id, filename) DO UPDATE SET
+		    asset_path = EXCLUDED.asset_path,
+		    size_bytes = EXCLUDED.size_bytes,
+		    sha256 = EXCLUDED.sha256,
+		    content_type = EXCLUDED.content_type,
+		    created_at = EXCLUDED.created_at
+		RETURNING created_at
+	`, challengeID, filename, attachment.AssetPath, attachment.Size, attachment.SHA256, contentType).Scan(&attachment.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record attachment: %w", err)
+	}
+
+	if err := logAdminAction(ctx, tx, adminEmail, fmt.Sprintf("Attached %s (sha256 %s) to challenge %d", filename, attachment.SHA256, challengeID)); err != nil {
+		return nil, err
+	}
+
+	// Commit transaction
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return attachment, nil
+}
+
+// Delete detaches a file from a challenge. The file is left in the asset store.
+// Returns sql.ErrNoRows if the attachment doesn't exist
+func (as *AttachmentService) Delete(ctx context.Context, adminEmail string, challengeID int, filename string) error {
+	// Start transaction
+	tx, err := as.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		DELETE FROM challenge_assets WHERE challenge_id = $1 AND filename = $2
+	`, challengeID, filename)
+	if err != nil {
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	if err := logAdminAction(ctx, tx, adminEmail, fmt.Sprintf("Removed attachment %s from challenge %d", filename, challengeID)); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
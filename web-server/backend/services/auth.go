@@ -32,9 +32,39 @@ const (
 	authenticatedContextKey contextKey = "authenticated"
 )
 
+// Role represents a permission level granted to a user
+type Role string
+
+const (
+	// RolePlayer is granted to every authenticated user
+	RolePlayer Role = "player"
+	// RoleAuthor can preview challenges before they are published
+	RoleAuthor Role = "author"
+	// RoleAdmin can manage challenges and users
+	RoleAdmin Role = "admin"
+	// RoleObserver has read-only access to the admin views
+	RoleObserver Role = "observer"
+)
+
 // User represents an authenticated user
 type User struct {
 	Email string
+	Roles []Role
+}
+
+// HasRole checks if the user has any of the given roles
+func (u *User) HasRole(roles ...Role) bool {
+	if u == nil {
+		return false
+	}
+	for _, have := range u.Roles {
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // cachedPublicKey represents a cached AWS Verified Access public key with expiration
@@ -233,11 +263,72 @@ func (a *AuthClient) ValidateAccessToken(accessToken string) (*User, error) {
 
 	user := &User{
 		Email: emailClaim,
+		Roles: a.ResolveRoles(emailClaim, a.groupsFromClaims(claims)),
 	}
 
 	return user, nil
 }
 
+// ResolveRoles determines a user's roles from the configured allowlists and
+// the identity provider groups carried in their token
+func (a *AuthClient) ResolveRoles(email string, groups []string) []Role {
+	roles := []Role{RolePlayer}
+	addRole := func(role Role) {
+		for _, existing := range roles {
+			if existing == role {
+				return
+			}
+		}
+		roles = append(roles, role)
+	}
+
+	rolesConfig := a.config.Auth.Roles
+	allowlists := []struct {
+		role   Role
+		emails []string
+	}{
+		{RoleAdmin, rolesConfig.Admins},
+		{RoleAuthor, rolesConfig.Authors},
+		{RoleObserver, rolesConfig.Observers},
+	}
+	for _, allowlist := range allowlists {
+		for _, allowed := range allowlist.emails {
+			if strings.EqualFold(allowed, email) {
+				addRole(allowlist.role)
+			}
+		}
+	}
+
+	for _, mapping := range rolesConfig.GroupRoles {
+		for _, group := range groups {
+			if group == mapping.Group {
+				addRole(Role(mapping.Role))
+			}
+		}
+	}
+
+	return roles
+}
+
+// groupsFromClaims extracts the identity provider groups from JWT claims.
+// The claim may be a single string or a list of strings.
+func (a *AuthClient) groupsFromClaims(claims jwt.MapClaims) []string {
+	switch value := claims[a.config.Auth.Roles.GroupsClaim].(type) {
+	case string:
+		return []string{value}
+	case []any:
+		groups := make([]string, 0, len(value))
+		for _, item := range value {
+			if group, ok := item.(string); ok {
+				groups = append(groups, group)
+			}
+		}
+		return groups
+	default:
+		return nil
+	}
+}
+
 // SetUserContext stores user information in the request context
 func (a *AuthClient) SetUserContext(ctx context.Context, userContext *User) context.Context {
 	return context.WithValue(ctx, userContextKey, userContext)
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// maxBloodBonuses is how many solve positions can earn a bonus: first, second and third blood
+const maxBloodBonuses = 3
+
+// Blood is one of a user's first, second or third solves of a challenge
+type Blood struct {
+	ChallengeID   int    `json:"challenge_id"`
+	ChallengeName string `json:"challenge_name"`
+	Position      int    `json:"position"`
+	BonusPoints   int    `json:"bonus_points"`
+}
+
+// bloodName names a solve position, or returns "" past third blood
+func bloodName(position int) string {
+	switch position {
+	case 1:
+		return "First blood"
+	case 2:
+		return "Second blood"
+	case 3:
+		return "Third blood"
+	}
+	return ""
+}
+
+// validateBloodBonuses checks a definition's solve order bonuses
+// Error messages from this function can be returned to the client
+func validateBloodBonuses(def *ChallengeDefinition) error {
+	if len(def.BloodBonuses) == 0 {
+		return nil
+	}
+	if def.Category == "exam" {
+		return ClientError{Message: "Exam challenges cannot have blood bonuses"}
+	}
+	if len(def.BloodBonuses) > maxBloodBonuses {
+		return ClientError{Message: fmt.Sprintf("At most %d blood bonuses can be set", maxBloodBonuses)}
+	}
+	for _, bonus := range def.BloodBonuses {
+		if bonus < 0 {
+			return ClientError{Message: "Blood bonuses cannot be negative"}
+		}
+	}
+	return nil
+}
+
+// bloodBonus returns the bonus for a solve position
+func bloodBonus(bonuses pq.Int64Array, position int) int {
+	if position < 1 || position > len(bonuses) {
+		return 0
+	}
+	return int(bonuses[position-1])
+}
+
+// toBonusArray converts blood bonuses for storage
+func toBonusArray(bonuses []int) pq.Int64Array {
+	array := make(pq.Int64Array, len(bonuses))
+	for i, bonus := range bonuses {
+		array[i] = int64(bonus)
+	}
+	return array
+}
+
+// fromBonusArray converts stored blood bonuses, keeping nil for none
+func fromBonusArray(array pq.Int64Array) []int {
+	if len(array) == 0 {
+		return nil
+	}
+	bonuses := make([]int, len(array))
+	for i, bonus := range array {
+		bonuses[i] = int(bonus)
+	}
+	return bonuses
+}
+
+// getUserBloods returns the challenges a user solved first, second or third
+func (uc *UserClient) getUserBloods(ctx context.Context, userEmail string) ([]Blood, error) {
+	rows, err := uc.db.QueryContext(ctx, `
+		SELECT ucc.challenge_id, c.name, ucc.solve_position, ucc.bonus_points
+		FROM user_challenges_completed ucc
+		JOIN challenges c ON c.id = ucc.challenge_id
+		WHERE ucc.user_email = $1 AND ucc.solve_position <= $2
+		ORDER BY ucc.completed_at
+	`, userEmail, maxBloodBonuses)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bloods: %w", err)
+	}
+	defer rows.Close()
+
+	bloods := make([]Blood, 0)
+	for rows.Next() {
+		var blood Blood
+		if err := rows.Scan(&blood.ChallengeID, &blood.ChallengeName, &blood.Position, &blood.BonusPoints); err != nil {
+			return nil, fmt.Errorf("failed to scan blood: %w", err)
+		}
+		bloods = append(bloods, blood)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return bloods, nil
+}
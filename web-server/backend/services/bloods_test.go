@@ -0,0 +1,58 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestBloodBonus(t *testing.T) {
+	bonuses := toBonusArray([]int{30, 20, 10})
+
+	// Index is the solve position; only the first three earn anything
+	want := []int{0, 30, 20, 10, 0, 0}
+	for position, bonus := range want {
+		if got := bloodBonus(bonuses, position); got != bonus {
+			t.Errorf("bloodBonus(%v, %d) = %d, want %d", bonuses, position, got, bonus)
+		}
+	}
+
+	if got := bloodBonus(bonuses, -1); got != 0 {
+		t.Errorf("bloodBonus at a negative position = %d, want 0", got)
+	}
+	if got := bloodBonus(nil, 1); got != 0 {
+		t.Errorf("bloodBonus without bonuses = %d, want 0", got)
+	}
+	if got := bloodBonus(pq.Int64Array{5}, 2); got != 0 {
+		t.Errorf("second blood with only a first blood bonus = %d, want 0", got)
+	}
+}
+
+func TestValidateBloodBonuses(t *testing.T) {
+	valid := [][]int{nil, {}, {0}, {50}, {30, 20, 10}}
+	for _, bonuses := range valid {
+		def := &ChallengeDefinition{Category: "web", BloodBonuses: bonuses}
+		if err := validateBloodBonuses(def); err != nil {
+			t.Errorf("validateBloodBonuses(%v) = %v, want nil", bonuses, err)
+		}
+	}
+
+	invalid := map[string]*ChallengeDefinition{
+		"exam challenge": {Category: "exam", BloodBonuses: []int{10}},
+		"fourth blood":   {Category: "web", BloodBonuses: []int{40, 30, 20, 10}},
+		"negative bonus": {Category: "web", BloodBonuses: []int{10, -5}},
+	}
+	for name, def := range invalid {
+		err := validateBloodBonuses(def)
+		if err == nil {
+			t.Errorf("%s: validateBloodBonuses(%v) = nil, want an error", name, def.BloodBonuses)
+		} else if !IsClientError(err) {
+			t.Errorf("%s: validateBloodBonuses error %v is not a ClientError", name, err)
+		}
+	}
+
+	// Exam challenges can still leave the bonuses empty
+	if err := validateBloodBonuses(&ChallengeDefinition{Category: "exam"}); err != nil {
+		t.Errorf("exam challenge without bonuses: %v", err)
+	}
+}
@@ -0,0 +1,553 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// minChallengeID and maxChallengeID mirror the range accepted by the public routes
+	minChallengeID = 1
+	maxChallengeID = 128
+)
+
+// ChallengeDefinition represents a challenge and its flag as managed by admins
+type ChallengeDefinition struct {
+	ID          int    `json:"id"`
+	NestedID    int    `json:"nested_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Category    string `json:"category"`
+	// PointRewardAmount is the current value; dynamic challenges recompute it on every solve
+	PointRewardAmount int     `json:"point_reward_amount"`
+	FileAsset         *string `json:"file_asset,omitempty"`
+	TextAsset         *string `json:"text_asset,omitempty"`
+	Hidden            bool    `json:"hidden"`
+	// ReleaseAt keeps the challenge closed to submissions until the given time
+	ReleaseAt         *time.Time `json:"release_at,omitempty"`
+	FlagValue         string     `json:"flag_value"`
+	ValidationHandler string     `json:"validation_handler"`
+	// ValidationParams is the handler specific parameter blob for the flag
+	ValidationParams json.RawMessage `json:"validation_params,omitempty"`
+	// ArtifactGenerator builds a per-user file or text asset for the challenge
+	ArtifactGenerator string `json:"artifact_generator,omitempty"`
+	// ArtifactParams is the generator specific parameter blob
+	ArtifactParams json.RawMessage `json:"artifact_params,omitempty"`
+	// Prerequisites must all be met before players can open the challenge
+	Prerequisites []PrerequisiteGroup `json:"prerequisites,omitempty"`
+	// Scoring is static (the default), linear or logarithmic
+	Scoring ScoringMode `json:"scoring,omitempty"`
+	// InitialPoints, MinimumPoints and Decay shape the value of dynamic challenges
+	InitialPoints int `json:"initial_points,omitempty"`
+	MinimumPoints int `json:"minimum_points,omitempty"`
+	Decay         int `json:"decay,omitempty"`
+	// BloodBonuses are extra points for the first, second and third solves
+	BloodBonuses []int `json:"blood_bonuses,omitempty"`
+	// SubmissionCost and CompletionReward are in tokens and default to 1 for exam challenges
+	SubmissionCost   *int `json:"submission_cost,omitempty"`
+	CompletionReward *int `json:"completion_reward,omitempty"`
+	// MaxAttempts limits the submissions each user gets for an exam challenge
+	MaxAttempts *int `json:"max_attempts,omitempty"`
+	// ExamTrack is the track an exam challenge is a stage of, main by default
+	ExamTrack string `json:"exam_track,omitempty"`
+	// Completions is the number of users who solved the challenge (read only)
+	Completions int `json:"completions"`
+}
+
+// validateDefinition checks a challenge definition before it is written
+// Error messages from this function can be returned to the client
+func (cc *ChallengeClient) validateDefinition(def *ChallengeDefinition) error {
+	def.Name = strings.TrimSpace(def.Name)
+	def.Category = strings.TrimSpace(def.Category)
+
+	if def.ID < minChallengeID || def.ID > maxChallengeID {
+		return ClientError{Message: fmt.Sprintf("Challenge ID must be between %d and %d", minChallengeID, maxChallengeID)}
+	}
+	if def.NestedID < 1 {
+		return ClientError{Message: "Nested ID must be positive"}
+	}
+	if def.Name == "" {
+		return ClientError{Message: "Name cannot be empty"}
+	}
+	if def.Description == "" {
+		return ClientError{Message: "Description cannot be empty"}
+	}
+	if def.Category == "" {
+		return ClientError{Message: "Category cannot be empty"}
+	}
+	if def.PointRewardAmount < 0 {
+		return ClientError{Message: "Point reward cannot be negative"}
+	}
+	if def.FlagValue == "" {
+		return ClientError{Message: "Flag value cannot be empty"}
+	}
+	if !cc.IsKnownValidationHandler(def.ValidationHandler) {
+		return ClientError{Message: fmt.Sprintf("Unknown validation handler: %s", def.ValidationHandler)}
+	}
+	if len(def.ValidationParams) == 0 {
+		def.ValidationParams = json.RawMessage("{}")
+	}
+	if err := cc.CheckValidationParams(def.ValidationHandler, def.FlagValue, def.ValidationParams); err != nil {
+		return ClientError{Message: fmt.Sprintf("Invalid flag for %s: %v", def.ValidationHandler, err)}
+	}
+	if def.TextAsset != nil && strings.Contains(*def.TextAsset, textAssetFlagPlaceholder) {
+		validator, _ := cc.validators.Get(def.ValidationHandler)
+		if _, ok := validator.(FlagDeriver); !ok {
+			return ClientError{Message: fmt.Sprintf("Text asset %s placeholder needs a validation handler that derives per-user flags, not %s", textAssetFlagPlaceholder, def.ValidationHandler)}
+		}
+	}
+	if err := validatePrerequisites(def); err != nil {
+		return err
+	}
+	if err := validateScoring(def); err != nil {
+		return err
+	}
+	if err := validateBloodBonuses(def); err != nil {
+		return err
+	}
+	if err := validateExamTerms(def); err != nil {
+		return err
+	}
+	if err := validateChallengeTrack(def); err != nil {
+		return err
+	}
+	if def.ReleaseAt != nil {
+		// release_at is stored without a time zone
+		releaseAt := def.ReleaseAt.UTC()
+		def.ReleaseAt = &releaseAt
+	}
+	if len(def.ArtifactParams) == 0 {
+		def.ArtifactParams = json.RawMessage("{}")
+	}
+	if def.ArtifactGenerator != "" {
+		if err := cc.checkArtifactGenerator(def); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkArtifactGenerator verifies a challenge's artifact generator and its params.
+// Generated artifacts embed the user's derived flag, so the validation handler must derive one.
+// Error messages from this function can be returned to the client
+func (cc *ChallengeClient) checkArtifactGenerator(def *ChallengeDefinition) error {
+	generator, exists := cc.generators.Get(def.ArtifactGenerator)
+	if !exists {
+		return ClientError{Message: fmt.Sprintf("Unknown artifact generator: %s", def.ArtifactGenerator)}
+	}
+	validator, _ := cc.validators.Get(def.ValidationHandler)
+	if _, ok := validator.(FlagDeriver); !ok {
+		return ClientError{Message: fmt.Sprintf("Artifact generator %s needs a validation handler that derives per-user flags, not %s", def.ArtifactGenerator, def.ValidationHandler)}
+	}
+	if err := generator.CheckParams(def.ArtifactParams); err != nil {
+		return ClientError{Message: fmt.Sprintf("Invalid artifact params for %s: %v", def.ArtifactGenerator, err)}
+	}
+	return nil
+}
+
+// CheckDefinition validates a challenge definition without writing it
+// Error messages from this function can be returned to the client
+func (cc *ChallengeClient) CheckDefinition(def ChallengeDefinition) error {
+	return cc.validateDefinition(&def)
+}
+
+// ListChallengeDefinitions returns every challenge including hidden and exam challenges
+func (cc *ChallengeClient) ListChallengeDefinitions(ctx context.Context) ([]ChallengeDefinition, error) {
+	rows, err := cc.database.QueryContext(ctx, `
+		SELECT c.id, c.nested_id, c.name, c.description, c.category, c.point_reward_amount,
+		       c.file_asset, c.text_asset, c.hidden, c.release_at, c.artifact_generator, c.artifact_params,
+		       c.scoring, c.initial_points, c.minimum_points, c.decay, c.blood_bonuses,
+		       c.submission_cost, c.completion_reward, c.max_attempts, COALESCE(c.exam_track, ''),
+		       COALESCE(f.flag_value, ''), COALESCE(f.validation_handler, ''), COALESCE(f.validation_params, '{}'),
+		       (SELECT COUNT(*) FROM user_challenges_completed ucc WHERE ucc.challenge_id = c.id)
+		FROM challenges c
+		LEFT JOIN flags f ON c.id = f.challenge_id
+		ORDER BY c.id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query challenge definitions: %w", err)
+	}
+	defer rows.Close()
+
+	definitions := make([]ChallengeDefinition, 0)
+	for rows.Next() {
+		var def ChallengeDefinition
+		var params, artifactParams []byte
+		var bonuses pq.Int64Array
+		var submissionCost, completionReward int
+		var maxAttempts sql.NullInt64
+		if err := rows.Scan(&def.ID, &def.NestedID, &def.Name, &def.Description, &def.Category, &def.PointRewardAmount,
+			&def.FileAsset, &def.TextAsset, &def.Hidden, &def.ReleaseAt, &def.ArtifactGenerator, &artifactParams,
+			&def.Scoring, &def.InitialPoints, &def.MinimumPoints, &def.Decay, &bonuses,
+			&submissionCost, &completionReward, &maxAttempts, &def.ExamTrack,
+			&def.FlagValue, &def.ValidationHandler, &params, &def.Completions); err != nil {
+			return nil, fmt.Errorf("failed to scan challenge definition: %w", err)
+		}
+		def.BloodBonuses = fromBonusArray(bonuses)
+		def.setExamTerms(submissionCost, completionReward, maxAttempts)
+		def.ValidationParams = params
+		def.ArtifactParams = artifactParams
+		definitions = append(definitions, def)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	prerequisites, err := loadPrerequisites(ctx, cc.database)
+	if err != nil {
+		return nil, err
+	}
+	for i := range definitions {
+		definitions[i].Prerequisites = prerequisites[definitions[i].ID]
+	}
+
+	return definitions, nil
+}
+
+// GetChallengeDefinition returns a single challenge definition
+// Returns sql.ErrNoRows if the challenge doesn't exist
+func (cc *ChallengeClient) GetChallengeDefinition(ctx context.Context, challengeID int) (*ChallengeDefinition, error) {
+	var def ChallengeDefinition
+	var params, artifactParams []byte
+	var bonuses pq.Int64Array
+	var submissionCost, completionReward int
+	var maxAttempts sql.NullInt64
+	err := cc.database.QueryRowContext(ctx, `
+		SELECT c.id, c.nested_id, c.name, c.description, c.category, c.point_reward_amount,
+		       c.file_asset, c.text_asset, c.hidden, c.release_at, c.artifact_generator, c.artifact_params,
+		       c.scoring, c.initial_points, c.minimum_points, c.decay, c.blood_bonuses,
+		       c.submission_cost, c.completion_reward, c.max_attempts, COALESCE(c.exam_track, ''),
+		       COALESCE(f.flag_value, ''), COALESCE(f.validation_handler, ''), COALESCE(f.validation_params, '{}'),
+		       (SELECT COUNT(*) FROM user_challenges_completed ucc WHERE ucc.challenge_id = c.id)
+		FROM challenges c
+		LEFT JOIN flags f ON c.id = f.challenge_id
+		WHERE c.id = $1
+	`, challengeID).Scan(&def.ID, &def.NestedID, &def.Name, &def.Description, &def.Category, &def.PointRewardAmount,
+		&def.FileAsset, &def.TextAsset, &def.Hidden, &def.ReleaseAt, &def.ArtifactGenerator, &artifactParams,
+		&def.Scoring, &def.InitialPoints, &def.MinimumPoints, &def.Decay, &bonuses,
+		&submissionCost, &completionReward, &maxAttempts, &def.ExamTrack,
+		&def.FlagValue, &def.ValidationHandler, &params, &def.Completions)
+	if err != nil {
+		return nil, err
+	}
+	def.BloodBonuses = fromBonusArray(bonuses)
+	def.setExamTerms(submissionCost, completionReward, maxAttempts)
+	def.ValidationParams = params
+	def.ArtifactParams = artifactParams
+
+	prerequisites, err := loadPrerequisites(ctx, cc.database)
+	if err != nil {
+		return nil, err
+	}
+	def.Prerequisites = prerequisites[def.ID]
+
+	return &def, nil
+}
+
+// CreateChallenge inserts a new challenge and its flag
+func (cc *ChallengeClient) CreateChallenge(ctx context.Context, adminEmail string, def ChallengeDefinition) error {
+	if err := cc.validateDefinition(&def); err != nil {
+		return err
+	}
+
+	// Start transaction for atomic operation
+	tx, err := cc.database.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM challenges WHERE id = $1)`, def.ID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check existing challenge: %w", err)
+	}
+	if exists {
+		return ClientError{Message: fmt.Sprintf("Challenge %d already exists", def.ID)}
+	}
+
+	if err := checkExamStageFree(ctx, tx, &def); err != nil {
+		return err
+	}
+
+	// Only future releases are announced on Slack
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO challenges (id, nested_id, name, description, category, point_reward_amount, file_asset, text_asset, hidden,
+		                        artifact_generator, artifact_params, release_at, release_announced_at,
+		                        scoring, initial_points, minimum_points, decay, blood_bonuses,
+		                        submission_cost, completion_reward, max_attempts, exam_track)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12,
+		        CASE WHEN $12::timestamp > NOW() THEN NULL ELSE NOW() END,
+		        $13, $14, $15, $16, $17, COALESCE($18, 1), COALESCE($19, 1), $20, NULLIF($21, ''))
+	`, def.ID, def.NestedID, def.Name, def.Description, def.Category, def.PointRewardAmount, def.FileAsset, def.TextAsset, def.Hidden,
+		def.ArtifactGenerator, string(def.ArtifactParams), def.ReleaseAt,
+		def.Scoring, def.InitialPoints, def.MinimumPoints, def.Decay, toBonusArray(def.BloodBonuses),
+		def.SubmissionCost, def.CompletionReward, def.MaxAttempts, def.ExamTrack)
+	if err != nil {
+		return fmt.Errorf("failed to insert challenge: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO flags (challenge_id, flag_value, validation_handler, validation_params)
+		VALUES ($1, $2, $3, $4)
+	`, def.ID, def.FlagValue, def.ValidationHandler, string(def.ValidationParams))
+	if err != nil {
+		return fmt.Errorf("failed to insert flag: %w", err)
+	}
+
+	if err := writePrerequisites(ctx, tx, &def); err != nil {
+		return err
+	}
+
+	if err := logAdminAction(ctx, tx, adminEmail, fmt.Sprintf("Created challenge %d '%s'", def.ID, def.Name)); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateChallenge replaces an existing challenge and its flag
+// Returns sql.ErrNoRows if the challenge doesn't exist
+func (cc *ChallengeClient) UpdateChallenge(ctx context.Context, adminEmail string, def ChallengeDefinition) error {
+	if err := cc.validateDefinition(&def); err != nil {
+		return err
+	}
+
+	// Start transaction for atomic operation
+	tx, err := cc.database.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the challenge so the rescore below doesn't race a solve
+	_, err = tx.ExecContext(ctx, `SELECT 1 FROM challenges WHERE id = $1 FOR UPDATE`, def.ID)
+	if err != nil {
+		return fmt.Errorf("failed to lock challenge: %w", err)
+	}
+
+	if err := checkExamStageFree(ctx, tx, &def); err != nil {
+		return err
+	}
+
+	// Moving the release into the future announces it again when it lands
+	result, err := tx.ExecContext(ctx, `
+		UPDATE challenges
+		SET nested_id = $2,
+		    name = $3,
+		    description = $4,
+		    category = $5,
+		    point_reward_amount = $6,
+		    file_asset = $7,
+		    text_asset = $8,
+		    hidden = $9,
+		    artifact_generator = $10,
+		    artifact_params = $11,
+		    release_at = $12,
+		    release_announced_at = CASE WHEN $12::timestamp > NOW() THEN NULL ELSE COALESCE(release_announced_at, NOW()) END,
+		    scoring = $13,
+		    initial_points = $14,
+		    minimum_points = $15,
+		    decay = $16,
+		    blood_bonuses = $17,
+		    submission_cost = COALESCE($18, 1),
+		    completion_reward = COALESCE($19, 1),
+		    max_attempts = $20,
+		    exam_track = NULLIF($21, '')
+		WHERE id = $1
+	`, def.ID, def.NestedID, def.Name, def.Description, def.Category, def.PointRewardAmount, def.FileAsset, def.TextAsset, def.Hidden,
+		def.ArtifactGenerator, string(def.ArtifactParams), def.ReleaseAt,
+		def.Scoring, def.InitialPoints, def.MinimumPoints, def.Decay, toBonusArray(def.BloodBonuses),
+		def.SubmissionCost, def.CompletionReward, def.MaxAttempts, def.ExamTrack)
+	if err != nil {
+		return fmt.Errorf("failed to update challenge: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	// Reprice existing solves under the new curve
+	if err := freezeScoreboard(ctx, tx, cc.config.Competition); err != nil {
+		return err
+	}
+	repriced, err := rescoreChallenge(ctx, tx, def.ID)
+	if err != nil {
+		return err
+	}
+	if err := refreshUserPoints(ctx, tx, repriced); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO flags (challenge_id, flag_value, validation_handler, validation_params)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (challenge_id) DO UPDATE SET
+			flag_value = EXCLUDED.flag_value,
+			validation_handler = EXCLUDED.validation_handler,
+			validation_params = EXCLUDED.validation_params
+	`, def.ID, def.FlagValue, def.ValidationHandler, string(def.ValidationParams))
+	if err != nil {
+		return fmt.Errorf("failed to upsert flag: %w", err)
+	}
+
+	if err := writePrerequisites(ctx, tx, &def); err != nil {
+		return err
+	}
+
+	if err := logAdminAction(ctx, tx, adminEmail, fmt.Sprintf("Updated challenge %d '%s'", def.ID, def.Name)); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// SetChallengeHidden hides or reveals a challenge without deleting it
+// Returns sql.ErrNoRows if the challenge doesn't exist
+func (cc *ChallengeClient) SetChallengeHidden(ctx context.Context, adminEmail string, challengeID int, hidden bool) error {
+	// Start transaction for atomic operation
+	tx, err := cc.database.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE challenges SET hidden = $2 WHERE id = $1`, challengeID, hidden)
+	if err != nil {
+		return fmt.Errorf("failed to update challenge visibility: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	action := "Revealed"
+	if hidden {
+		action = "Hid"
+	}
+	if err := logAdminAction(ctx, tx, adminEmail, fmt.Sprintf("%s challenge %d", action, challengeID)); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteChallenge removes a challenge and its flag.
+// Challenges that have completions are only deleted when force is set; their
+// completion rows are removed but points and tokens already awarded are kept
+// by moving them into each user's points adjustment.
+// Returns sql.ErrNoRows if the challenge doesn't exist
+func (cc *ChallengeClient) DeleteChallenge(ctx context.Context, adminEmail string, challengeID int, force bool) error {
+	// Start transaction for atomic operation
+	tx, err := cc.database.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the challenge row so no completion sneaks in between the check and the delete
+	var name string
+	err = tx.QueryRowContext(ctx, `SELECT name FROM challenges WHERE id = $1 FOR UPDATE`, challengeID).Scan(&name)
+	if err != nil {
+		return err
+	}
+
+	var completions int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM user_challenges_completed WHERE challenge_id = $1
+	`, challengeID).Scan(&completions)
+	if err != nil {
+		return fmt.Errorf("failed to count completions: %w", err)
+	}
+
+	if completions > 0 && !force {
+		return ClientError{Message: fmt.Sprintf("Challenge has %d completions, set force=true to delete it anyway", completions)}
+	}
+
+	var dependents string
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(string_agg(DISTINCT g.challenge_id::TEXT, ', '), '')
+		FROM challenge_prerequisites p
+		JOIN challenge_prerequisite_groups g ON g.id = p.group_id
+		WHERE p.prerequisite_id = $1
+	`, challengeID).Scan(&dependents)
+	if err != nil {
+		return fmt.Errorf("failed to check dependent challenges: %w", err)
+	}
+	if dependents != "" {
+		return ClientError{Message: fmt.Sprintf("Challenge is a prerequisite of challenges %s, remove it from their prerequisites first", dependents)}
+	}
+
+	if err := keepChallengePoints(ctx, tx, challengeID); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM user_challenges_completed WHERE challenge_id = $1`, challengeID)
+	if err != nil {
+		return fmt.Errorf("failed to delete completions: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM flags WHERE challenge_id = $1`, challengeID)
+	if err != nil {
+		return fmt.Errorf("failed to delete flag: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM challenges WHERE id = $1`, challengeID)
+	if err != nil {
+		return fmt.Errorf("failed to delete challenge: %w", err)
+	}
+
+	if err := logAdminAction(ctx, tx, adminEmail, fmt.Sprintf("Deleted challenge %d '%s' (%d completions)", challengeID, name, completions)); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// logAdminAction records an admin change in the acting admin's history log
+func logAdminAction(ctx context.Context, tx *sql.Tx, adminEmail, action string) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO user_history_log (user_email, log, date)
+		VALUES ($1, $2, NOW())
+	`, adminEmail, "Admin: "+action)
+	if err != nil {
+		return fmt.Errorf("failed to log admin action: %w", err)
+	}
+	return nil
+}
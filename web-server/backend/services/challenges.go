@@ -1,22 +1,15 @@
 package services
 
 import (
-	"crypto/ed25519"
-	"crypto/md5"
-	"crypto/sha1"
-	"crypto/sha256"
-	"crypto/x509"
+	"context"
 	"database/sql"
-	"encoding/pem"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"strconv"
 	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/obelisk/example-ctf/config"
-	"github.com/obelisk/example-ctf/utility"
-	"golang.org/x/crypto/sha3"
 )
 
 // Challenge represents a challenge in the list view
@@ -28,6 +21,16 @@ type Challenge struct {
 	Category          string `json:"category"`
 	PointRewardAmount int    `json:"point_reward_amount"`
 	Completed         bool   `json:"completed"`
+	// TeamCompleted is set in team mode once any member of the user's team solved the challenge
+	TeamCompleted bool `json:"team_completed"`
+	// Locked challenges are teasers: the description is withheld until the prerequisites
+	// are met, or for exam challenges while the user's exam session isn't running
+	Locked        bool                `json:"locked"`
+	Prerequisites []PrerequisiteGroup `json:"prerequisites,omitempty"`
+	// ExamTrack is the track an exam challenge is a stage of
+	ExamTrack string `json:"exam_track,omitempty"`
+	// Exam holds the submission cost, reward and attempts of exam challenges
+	Exam *ExamTerms `json:"exam,omitempty"`
 }
 
 // DetailedChallenge represents a challenge with full details
@@ -41,20 +44,35 @@ type DetailedChallenge struct {
 	PointRewardAmount int     `json:"point_reward_amount"`
 	FileAsset         *string `json:"file_asset,omitempty"`
 	TextAsset         *string `json:"text_asset,omitempty"`
-	Completed         bool    `json:"completed"`
+	// Attachments are the files attached to the challenge, with download URLs and checksums
+	Attachments []Attachment `json:"attachments"`
+	Completed   bool         `json:"completed"`
+	// TeamCompleted is set in team mode once any member of the user's team solved the challenge
+	TeamCompleted bool `json:"team_completed"`
+	// Locked challenges are teasers: the description and assets are withheld until the prerequisites are met
+	Locked        bool                `json:"locked"`
+	Prerequisites []PrerequisiteGroup `json:"prerequisites,omitempty"`
+	// ExamTrack is the track an exam challenge is a stage of
+	ExamTrack string `json:"exam_track,omitempty"`
+	// Exam holds the submission cost, reward and attempts of exam challenges
+	Exam *ExamTerms `json:"exam,omitempty"`
 }
 
 // ChallengeClient handles challenge-related operations
 type ChallengeClient struct {
-	config   *config.Config
-	database *sql.DB
+	config     *config.Config
+	database   *sql.DB
+	validators *ValidatorRegistry
+	generators *ArtifactGeneratorRegistry
 }
 
 // NewChallengeClient creates a new challenge client
-func NewChallengeClient(db *sql.DB, cfg *config.Config) *ChallengeClient {
+func NewChallengeClient(db *sql.DB, cfg *config.Config, validators *ValidatorRegistry, generators *ArtifactGeneratorRegistry) *ChallengeClient {
 	return &ChallengeClient{
-		config:   cfg,
-		database: db,
+		config:     cfg,
+		database:   db,
+		validators: validators,
+		generators: generators,
 	}
 }
 
@@ -81,161 +99,133 @@ func (cc *ChallengeClient) CheckUserCompletedChallenge(userEmail string, challen
 	return false, time.Unix(0, 0), err
 }
 
+// ChallengeFlag holds the flag and reward information needed to validate a submission
+type ChallengeFlag struct {
+	ChallengeID       int
+	Name              string
+	Category          string
+	PointRewardAmount int
+	FlagValue         string
+	ValidationHandler string
+	ValidationParams  json.RawMessage
+}
+
 // GetChallengeFlagAndReward retrieves the flag, reward, name, and category for a challenge
-// Returns (flag, point_reward, validation_handler, challenge_name, category, err)
-func (cc *ChallengeClient) GetChallengeFlagAndReward(challengeID int) (string, int, string, string, string, error) {
-	var flagValue string
-	var pointRewardAmount int
-	var validationHandler string
-	var challengeName string
-	var category string
+// Returns sql.ErrNoRows if the challenge doesn't exist, is hidden or is not released yet
+func (cc *ChallengeClient) GetChallengeFlagAndReward(challengeID int) (*ChallengeFlag, error) {
+	flag := ChallengeFlag{ChallengeID: challengeID}
+	var params []byte
 	err := cc.database.QueryRow(`
-		SELECT f.flag_value, c.point_reward_amount, f.validation_handler, c.name, c.category
+		SELECT f.flag_value, c.point_reward_amount, f.validation_handler, f.validation_params, c.name, c.category
 		FROM challenges c
 		JOIN flags f ON c.id = f.challenge_id
-		WHERE c.id = $1
-	`, challengeID).Scan(&flagValue, &pointRewardAmount, &validationHandler, &challengeName, &category)
-
-	if err == nil {
-		return flagValue, pointRewardAmount, validationHandler, challengeName, category, err
+		WHERE c.id = $1 AND NOT c.hidden AND (c.release_at IS NULL OR c.release_at <= NOW())
+	`, challengeID).Scan(&flag.FlagValue, &flag.PointRewardAmount, &flag.ValidationHandler, &params, &flag.Name, &flag.Category)
+	if err != nil {
+		return nil, err
 	}
+	flag.ValidationParams = params
 
-	return "", 0, "", "", "", err
+	return &flag, nil
 }
 
-// ValidateFlag validates a submitted flag based on the validation handler type
-// Returns (isValid, customErrorMessage). If customErrorMessage is empty, use default "Incorrect flag" message.
-func (cc *ChallengeClient) ValidateFlag(submittedFlag, expectedFlagValue, validationHandler, userEmail string) (bool, string) {
-	switch validationHandler {
-	case "StringEqual":
-		return utility.ConstantTimeStringEqual(strings.ToLower(submittedFlag), strings.ToLower(expectedFlagValue)), ""
-	case "Md5HashOfUsername":
-		return cc.validateHashOfUsername(submittedFlag, expectedFlagValue, "md5", userEmail)
-	case "Sha1HashOfUsername":
-		return cc.validateHashOfUsername(submittedFlag, expectedFlagValue, "sha1", userEmail)
-	case "Sha256HashOfUsername":
-		return cc.validateHashOfUsername(submittedFlag, expectedFlagValue, "sha256", userEmail)
-	case "Keccak256HashOfUsername":
-		return cc.validateHashOfUsername(submittedFlag, expectedFlagValue, "keccak256", userEmail)
-	case "ClientSideWasm":
-		return cc.validateClientSideWasm(submittedFlag, expectedFlagValue, userEmail), ""
-	default:
-		// Default to string comparison (case-insensitive)
-		return utility.ConstantTimeStringEqual(strings.ToLower(submittedFlag), strings.ToLower(expectedFlagValue)), ""
-	}
+// ValidationHandlers returns the names of all registered validation handlers
+func (cc *ChallengeClient) ValidationHandlers() []string {
+	return cc.validators.Names()
 }
 
-// validateHashOfUsername validates hash-based PoW and returns custom messages
-func (cc *ChallengeClient) validateHashOfUsername(submittedFlag, difficulty, hashType, userEmail string) (bool, string) {
-	// Parse difficulty as integer
-	requiredZeros, err := strconv.Atoi(difficulty)
-	if err != nil || requiredZeros < 0 {
-		return false, ""
-	}
+// IsKnownValidationHandler checks if a validator is registered for the handler name
+func (cc *ChallengeClient) IsKnownValidationHandler(name string) bool {
+	_, exists := cc.validators.Get(name)
+	return exists
+}
 
-	// Check that the submitted flag contains the user email
-	if !strings.Contains(submittedFlag, userEmail) {
-		return false, ""
-	}
+// ArtifactGenerators returns the names of all registered artifact generators
+func (cc *ChallengeClient) ArtifactGenerators() []string {
+	return cc.generators.Names()
+}
 
-	var hashHex string
-	switch hashType {
-	case "md5":
-		hash := md5.Sum([]byte(submittedFlag))
-		hashHex = fmt.Sprintf("%x", hash)
-	case "sha1":
-		hash := sha1.Sum([]byte(submittedFlag))
-		hashHex = fmt.Sprintf("%x", hash)
-	case "sha256":
-		hash := sha256.Sum256([]byte(submittedFlag))
-		hashHex = fmt.Sprintf("%x", hash)
-	case "keccak256":
-		hasher := sha3.NewLegacyKeccak256()
-		hasher.Write([]byte(submittedFlag))
-		hash := hasher.Sum(nil)
-		hashHex = fmt.Sprintf("%x", hash)
-	default:
-		return false, ""
+// CheckValidationParams verifies a flag's value and parameters against its handler
+func (cc *ChallengeClient) CheckValidationParams(handler, flagValue string, params json.RawMessage) error {
+	validator, exists := cc.validators.Get(handler)
+	if !exists {
+		return fmt.Errorf("unknown validation handler: %s", handler)
 	}
+	return validator.CheckParams(flagValue, params)
+}
 
-	// Check if the hash starts with the required number of leading zeros
-	if requiredZeros == 0 {
-		return true, ""
+// ValidateFlag validates a submitted flag with the challenge's registered validator.
+// A correct answer that names another registered user is theirs and is rejected.
+// Returns an error if the challenge names a validation handler that isn't registered
+func (cc *ChallengeClient) ValidateFlag(ctx context.Context, flag *ChallengeFlag, submittedFlag, userEmail string) (ValidationResult, error) {
+	validator, exists := cc.validators.Get(flag.ValidationHandler)
+	if !exists {
+		return ValidationResult{}, fmt.Errorf("unknown validation handler %s for challenge %d", flag.ValidationHandler, flag.ChallengeID)
 	}
 
-	// Create a prefix string with the required number of zeros
-	requiredPrefix := strings.Repeat("0", requiredZeros)
-
-	// First, check if the difficulty requirement is met (correctness check first)
-	if strings.HasPrefix(hashHex, requiredPrefix) {
-		return true, ""
+	input := ValidationInput{
+		ChallengeID: flag.ChallengeID,
+		UserEmail:   userEmail,
+		Submitted:   submittedFlag,
+		Expected:    flag.FlagValue,
+		Params:      flag.ValidationParams,
 	}
-
-	// Count actual leading zeros in the hash
-	actualZeros := len(hashHex) - len(strings.TrimLeft(hashHex, "0"))
-
-	// Custom messages based on progress toward the goal
-	if actualZeros >= 2 {
-		// Calculate 75% threshold of required zeros
-		threshold75 := int(float64(requiredZeros) * 0.75)
-		threshold50 := int(float64(requiredZeros) * 0.50)
-
-		if actualZeros >= threshold75 {
-			return false, "Need a little more work"
-		} else if actualZeros >= threshold50 {
-			return false, "NEED EVEN MORE WORK"
-		}
-		return false, "Need more work"
+	result := validator.Validate(input)
+	if !result.Valid {
+		return result, nil
 	}
 
-	// Default case: less than 2 zeros, return empty message (use default "Incorrect flag")
-	return false, ""
-}
-
-// validateClientSideWasm validates a JWT token signed with an Ed25519 key and checks for admin claims
-func (cc *ChallengeClient) validateClientSideWasm(submittedFlag, publicKeyPEM, userEmail string) bool {
-	// Parse the Ed25519 public key from PEM format
-	block, _ := pem.Decode([]byte(publicKeyPEM))
-	if block == nil {
-		return false
+	detector, ok := validator.(SharingDetector)
+	if !ok {
+		return result, nil
 	}
-
-	// Parse the public key
-	publicKeyInterface, err := x509.ParsePKIXPublicKey(block.Bytes)
+	// Only answers that name an email can belong to someone else once they are correct
+	hint, ok := detector.OwnerHint(input)
+	if !ok || hint == "" {
+		return result, nil
+	}
+	candidates, err := cc.sharingCandidates(ctx, hint)
 	if err != nil {
-		return false
+		return ValidationResult{}, err
 	}
-
-	// Ensure it's an Ed25519 public key
-	publicKey, ok := publicKeyInterface.(ed25519.PublicKey)
-	if !ok {
-		return false
+	if _, found := detector.FindOwner(input, candidates); found {
+		return reject(""), nil
 	}
 
-	// Parse and validate the JWT
-	token, err := jwt.ParseWithClaims(submittedFlag, jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// Verify the signing method is EdDSA
-		if _, ok := token.Method.(*jwt.SigningMethodEd25519); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return publicKey, nil
-	})
+	return result, nil
+}
 
+// VerifyFlags checks that every row in flags names a registered validation handler
+// with valid parameters. It is run at startup so misconfigured challenges fail fast.
+func (cc *ChallengeClient) VerifyFlags(ctx context.Context) error {
+	rows, err := cc.database.QueryContext(ctx, `
+		SELECT challenge_id, flag_value, validation_handler, validation_params
+		FROM flags
+		ORDER BY challenge_id
+	`)
 	if err != nil {
-		return false
+		return fmt.Errorf("failed to query flags: %w", err)
 	}
+	defer rows.Close()
 
-	// Check if token is valid and extract claims
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		// Verify that is_admin is true
-		if isAdmin, exists := claims["is_admin"]; exists {
-			if adminBool, ok := isAdmin.(bool); ok {
-				return adminBool
-			}
+	var errs []error
+	for rows.Next() {
+		var challengeID int
+		var flagValue, handler string
+		var params []byte
+		if err := rows.Scan(&challengeID, &flagValue, &handler, &params); err != nil {
+			return fmt.Errorf("failed to scan flag: %w", err)
 		}
+		if err := cc.CheckValidationParams(handler, flagValue, params); err != nil {
+			errs = append(errs, fmt.Errorf("challenge %d: %w", challengeID, err))
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows error: %w", err)
 	}
 
-	return false
+	return errors.Join(errs...)
 }
 
 // SanitizeFlag cleans and validates flag input
@@ -0,0 +1,297 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/obelisk/example-ctf/config"
+)
+
+// CompetitionPhase describes where the competition is in its schedule
+type CompetitionPhase string
+
+const (
+	CompetitionNotStarted CompetitionPhase = "not_started"
+	CompetitionRunning    CompetitionPhase = "running"
+	CompetitionPaused     CompetitionPhase = "paused"
+	CompetitionEnded      CompetitionPhase = "ended"
+)
+
+// CompetitionStatus combines the configured schedule with the admin controlled state
+type CompetitionStatus struct {
+	Phase                CompetitionPhase `json:"phase"`
+	StartsAt             *time.Time       `json:"starts_at,omitempty"`
+	EndsAt               *time.Time       `json:"ends_at,omitempty"`
+	FreezeAt             *time.Time       `json:"freeze_at,omitempty"`
+	PausedAt             *time.Time       `json:"paused_at,omitempty"`
+	ScoreboardFrozen     bool             `json:"scoreboard_frozen"`
+	ScoreboardRevealedAt *time.Time       `json:"scoreboard_revealed_at,omitempty"`
+}
+
+// competitionState is the singleton competition_state row
+type competitionState struct {
+	paused               bool
+	pausedAt             sql.NullTime
+	scoreboardFrozenAt   sql.NullTime
+	scoreboardRevealedAt sql.NullTime
+}
+
+// CompetitionService enforces the competition window, the admin pause switch and the scoreboard freeze
+type CompetitionService struct {
+	db     *sql.DB
+	config *config.Config
+}
+
+// NewCompetitionService creates a new competition service
+func NewCompetitionService(db *sql.DB, cfg *config.Config) *CompetitionService {
+	return &CompetitionService{
+		db:     db,
+		config: cfg,
+	}
+}
+
+// loadState reads the competition_state row
+func (cs *CompetitionService) loadState(ctx context.Context) (competitionState, error) {
+	var state competitionState
+	err := cs.db.QueryRowContext(ctx, `
+		SELECT paused, paused_at, scoreboard_frozen_at, scoreboard_revealed_at
+		FROM competition_state
+	`).Scan(&state.paused, &state.pausedAt, &state.scoreboardFrozenAt, &state.scoreboardRevealedAt)
+	if err != nil {
+		return state, fmt.Errorf("failed to query competition state: %w", err)
+	}
+	return state, nil
+}
+
+// scoreboardFrozen reports whether the freeze time has passed and the scoreboard is not revealed yet
+func (cs *CompetitionService) scoreboardFrozen(state competitionState, now time.Time) bool {
+	freezeAt := cs.config.Competition.FreezeAt
+	return !freezeAt.IsZero() && !now.Before(freezeAt) && !state.scoreboardRevealedAt.Valid
+}
+
+// Status returns the competition's current phase and schedule
+func (cs *CompetitionService) Status(ctx context.Context) (CompetitionStatus, error) {
+	state, err := cs.loadState(ctx)
+	if err != nil {
+		return CompetitionStatus{}, err
+	}
+
+	now := time.Now()
+	competition := cs.config.Competition
+	status := CompetitionStatus{
+		Phase:            CompetitionRunning,
+		StartsAt:         optionalTime(competition.StartsAt),
+		EndsAt:           optionalTime(competition.EndsAt),
+		FreezeAt:         optionalTime(competition.FreezeAt),
+		ScoreboardFrozen: cs.scoreboardFrozen(state, now),
+	}
+	if state.pausedAt.Valid && state.paused {
+		status.PausedAt = &state.pausedAt.Time
+	}
+	if state.scoreboardRevealedAt.Valid {
+		status.ScoreboardRevealedAt = &state.scoreboardRevealedAt.Time
+	}
+
+	switch {
+	case competition.HasEnded(now):
+		status.Phase = CompetitionEnded
+	case !competition.HasStarted(now):
+		status.Phase = CompetitionNotStarted
+	case state.paused:
+		status.Phase = CompetitionPaused
+	}
+
+	return status, nil
+}
+
+// CheckOpen returns a ClientError unless the competition is accepting submissions
+// Error messages from this function can be returned to the client
+func (cs *CompetitionService) CheckOpen(ctx context.Context) error {
+	status, err := cs.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch status.Phase {
+	case CompetitionNotStarted:
+		return ClientError{Message: fmt.Sprintf("The competition starts at %s", status.StartsAt.UTC().Format(time.RFC3339))}
+	case CompetitionEnded:
+		return ClientError{Message: "The competition has ended"}
+	case CompetitionPaused:
+		return ClientError{Message: "The competition is paused"}
+	}
+	return nil
+}
+
+// SetPaused pauses or resumes the competition
+// Error messages from this function can be returned to the client
+func (cs *CompetitionService) SetPaused(ctx context.Context, adminEmail string, paused bool) error {
+	// Start transaction for atomic operation
+	tx, err := cs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE competition_state
+		SET paused = $1,
+		    paused_at = CASE WHEN $1 THEN NOW() ELSE NULL END
+		WHERE paused != $1
+	`, paused)
+	if err != nil {
+		return fmt.Errorf("failed to update competition state: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+
+	action, current := "Resumed", "running"
+	if paused {
+		action, current = "Paused", "paused"
+	}
+	if rowsAffected == 0 {
+		return ClientError{Message: fmt.Sprintf("The competition is already %s", current)}
+	}
+
+	if err := logAdminAction(ctx, tx, adminEmail, action+" the competition"); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// RevealScoreboard ends the scoreboard freeze so the leaderboard shows live standings again
+// Error messages from this function can be returned to the client
+func (cs *CompetitionService) RevealScoreboard(ctx context.Context, adminEmail string) error {
+	freezeAt := cs.config.Competition.FreezeAt
+	if freezeAt.IsZero() || time.Now().Before(freezeAt) {
+		return ClientError{Message: "The scoreboard is not frozen"}
+	}
+
+	// Start transaction for atomic operation
+	tx, err := cs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE competition_state
+		SET scoreboard_revealed_at = NOW()
+		WHERE scoreboard_revealed_at IS NULL
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to reveal scoreboard: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ClientError{Message: "The scoreboard has already been revealed"}
+	}
+
+	if err := logAdminAction(ctx, tx, adminEmail, "Revealed the frozen scoreboard"); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ScoreboardSource returns the table the leaderboard should rank and whether the scoreboard is frozen.
+// Until a score changes after the freeze time the live table still holds the frozen standings.
+func (cs *CompetitionService) ScoreboardSource(ctx context.Context) (string, bool, error) {
+	state, err := cs.loadState(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	if !cs.scoreboardFrozen(state, time.Now()) {
+		return "user_standings", false, nil
+	}
+	if state.scoreboardFrozenAt.Valid {
+		return "scoreboard_snapshot", true, nil
+	}
+	return "user_standings", true, nil
+}
+
+// freezeScoreboard copies the user and team standings into scoreboard_snapshot and
+// team_scoreboard_snapshot the first time a score changes after the freeze time. It must run before the change in the same transaction.
+func freezeScoreboard(ctx context.Context, tx *sql.Tx, competition config.CompetitionConfig) error {
+	if competition.FreezeAt.IsZero() || time.Now().Before(competition.FreezeAt) {
+		return nil
+	}
+
+	// Claiming the row makes concurrent score changes wait for the snapshot
+	var frozen bool
+	err := tx.QueryRowContext(ctx, `
+		UPDATE competition_state
+		SET scoreboard_frozen_at = NOW()
+		WHERE scoreboard_frozen_at IS NULL
+		RETURNING TRUE
+	`).Scan(&frozen)
+	if err == sql.ErrNoRows {
+		// Already frozen
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to freeze scoreboard: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM scoreboard_snapshot`)
+	if err != nil {
+		return fmt.Errorf("failed to clear scoreboard snapshot: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM team_scoreboard_snapshot`)
+	if err != nil {
+		return fmt.Errorf("failed to clear team scoreboard snapshot: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO scoreboard_snapshot (user_email, points_achieved, exam_challenges_solved,
+		                                 last_exam_challenge_solved_timestamp, last_challenge_solved_timestamp,
+		                                 first_bloods, second_bloods, third_bloods, exam_elapsed)
+		SELECT user_email, points_achieved, exam_challenges_solved,
+		       last_exam_challenge_solved_timestamp, last_challenge_solved_timestamp,
+		       first_bloods, second_bloods, third_bloods, exam_elapsed
+		FROM user_standings
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to snapshot scoreboard: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO team_scoreboard_snapshot (team_id, points, solves, last_solved_at)
+		SELECT team_id, points, solves, last_solved_at
+		FROM team_standings
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to snapshot team scoreboard: %w", err)
+	}
+
+	return nil
+}
+
+// optionalTime returns nil for the zero time
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
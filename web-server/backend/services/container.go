@@ -14,23 +14,60 @@ type Container struct {
 	Auth            *AuthClient
 	UserClient      *UserClient
 	AssetService    *AssetService
+	Attachments     *AttachmentService
 	SlackService    *SlackService
+	Validators      *ValidatorRegistry
+	Generators      *ArtifactGeneratorRegistry
+	Artifacts       *ArtifactService
+	Leaderboard     *LeaderboardService
+	Submissions     *SubmissionClient
+	Hints           *HintClient
+	Competition     *CompetitionService
+	Teams           *TeamClient
+	ExamSessions    *ExamSessionClient
+	ExamTracks      *ExamTrackClient
+	Ledger          *LedgerClient
 }
 
 // NewContainer creates a new dependency container
-func NewContainer(db *sql.DB, cfg *config.Config) *Container {
+func NewContainer(db *sql.DB, cfg *config.Config) (*Container, error) {
 	assetService, err := NewAssetService(cfg)
 	if err != nil {
-		panic(fmt.Sprintf("failed to initialize asset service: %v", err))
+		return nil, fmt.Errorf("failed to initialize asset service: %w", err)
 	}
 
+	validators := NewValidatorRegistry()
+	if err := registerBuiltinValidators(validators, cfg); err != nil {
+		return nil, fmt.Errorf("failed to register validators: %w", err)
+	}
+
+	generators := NewArtifactGeneratorRegistry()
+	if err := registerBuiltinGenerators(generators, cfg); err != nil {
+		return nil, fmt.Errorf("failed to register artifact generators: %w", err)
+	}
+
+	competition := NewCompetitionService(db, cfg)
+	leaderboard := NewLeaderboardService(db, cfg, competition)
+
 	return &Container{
 		DB:              db,
 		Config:          cfg,
-		ChallengeClient: NewChallengeClient(db, cfg),
+		ChallengeClient: NewChallengeClient(db, cfg, validators, generators),
 		Auth:            NewAuthClient(db, cfg),
 		UserClient:      NewUserClient(db, cfg),
 		AssetService:    assetService,
-		SlackService:    NewSlackService(db, cfg),
-	}
+		Attachments:     NewAttachmentService(db, assetService),
+		SlackService:    NewSlackService(db, cfg, leaderboard),
+		Validators:      validators,
+		Generators:      generators,
+		Artifacts:       NewArtifactService(db, assetService, generators, validators),
+		Leaderboard:     leaderboard,
+		Submissions:     NewSubmissionClient(db),
+		Hints:           NewHintClient(db),
+		Competition:     competition,
+		Teams:           NewTeamClient(db, cfg, competition),
+		ExamSessions:    NewExamSessionClient(db, cfg),
+		ExamTracks:      NewExamTrackClient(db),
+		Ledger:          NewLedgerClient(db),
+	}, nil
 }
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// ExamTerms is what submitting to an exam challenge costs and what solving it earns
+type ExamTerms struct {
+	SubmissionCost   int `json:"submission_cost"`
+	CompletionReward int `json:"completion_reward"`
+	// MaxAttempts limits how many submissions a user gets; nil is unlimited
+	MaxAttempts  *int `json:"max_attempts,omitempty"`
+	AttemptsUsed int  `json:"attempts_used"`
+}
+
+// WithMaxAttempts sets the attempt limit from a nullable column and returns the terms
+func (terms ExamTerms) WithMaxAttempts(maxAttempts sql.NullInt64) *ExamTerms {
+	if maxAttempts.Valid {
+		terms.MaxAttempts = intPtr(int(maxAttempts.Int64))
+	}
+	return &terms
+}
+
+// validateExamTerms checks a definition's submission cost, completion reward and max attempts.
+// Exam challenges default to costing and earning 1 token with unlimited attempts.
+// Error messages from this function can be returned to the client
+func validateExamTerms(def *ChallengeDefinition) error {
+	if def.Category != "exam" {
+		if def.SubmissionCost != nil || def.CompletionReward != nil || def.MaxAttempts != nil {
+			return ClientError{Message: "Only exam challenges have a submission cost, completion reward or max attempts"}
+		}
+		return nil
+	}
+
+	if def.SubmissionCost == nil {
+		def.SubmissionCost = intPtr(1)
+	}
+	if def.CompletionReward == nil {
+		def.CompletionReward = intPtr(1)
+	}
+	if *def.SubmissionCost < 0 {
+		return ClientError{Message: "Submission cost cannot be negative"}
+	}
+	if *def.CompletionReward < 0 {
+		return ClientError{Message: "Completion reward cannot be negative"}
+	}
+	if def.MaxAttempts != nil && *def.MaxAttempts < 1 {
+		return ClientError{Message: "Max attempts must be positive"}
+	}
+	return nil
+}
+
+// setExamTerms fills in an exam challenge's terms from scanned columns
+func (def *ChallengeDefinition) setExamTerms(submissionCost, completionReward int, maxAttempts sql.NullInt64) {
+	if def.Category != "exam" {
+		return
+	}
+	terms := ExamTerms{SubmissionCost: submissionCost, CompletionReward: completionReward}.WithMaxAttempts(maxAttempts)
+	def.SubmissionCost = &terms.SubmissionCost
+	def.CompletionReward = &terms.CompletionReward
+	def.MaxAttempts = terms.MaxAttempts
+}
+
+// tokenCount renders a number of tokens for messages
+func tokenCount(tokens int) string {
+	if tokens == 1 {
+		return "1 token"
+	}
+	return fmt.Sprintf("%d tokens", tokens)
+}
+
+func intPtr(value int) *int {
+	return &value
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/obelisk/example-ctf/config"
+)
+
+// ExamSession is a user's timed run at the exam
+type ExamSession struct {
+	StartedAt        time.Time `json:"started_at"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RemainingSeconds int64     `json:"remaining_seconds"`
+	Expired          bool      `json:"expired"`
+}
+
+// ExamSessionStatus describes whether timed sessions are on and the user's session, if started
+type ExamSessionStatus struct {
+	Enabled         bool         `json:"enabled"`
+	DurationSeconds int64        `json:"duration_seconds,omitempty"`
+	Session         *ExamSession `json:"session,omitempty"`
+}
+
+// ExamSessionClient opens and checks timed exam sessions
+type ExamSessionClient struct {
+	db     *sql.DB
+	config *config.Config
+}
+
+// NewExamSessionClient creates a new exam session client
+func NewExamSessionClient(db *sql.DB, cfg *config.Config) *ExamSessionClient {
+	return &ExamSessionClient{
+		db:     db,
+		config: cfg,
+	}
+}
+
+// enabled reports whether users must start a timed session to take the exam
+func (ec *ExamSessionClient) enabled() bool {
+	return ec.config.Exam.SessionDuration > 0
+}
+
+// get returns a user's exam session, timed by the database clock.
+// Returns sql.ErrNoRows if the user has not started the exam
+func (ec *ExamSessionClient) get(ctx context.Context, userEmail string) (*ExamSession, error) {
+	var session ExamSession
+	err := ec.db.QueryRowContext(ctx, `
+		SELECT started_at, expires_at,
+		       GREATEST(CEIL(EXTRACT(EPOCH FROM expires_at - NOW())), 0)::BIGINT,
+		       expires_at <= NOW()
+		FROM exam_sessions
+		WHERE user_email = $1
+	`, userEmail).Scan(&session.StartedAt, &session.ExpiresAt, &session.RemainingSeconds, &session.Expired)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Status returns whether timed sessions are enabled and the user's session
+func (ec *ExamSessionClient) Status(ctx context.Context, userEmail string) (ExamSessionStatus, error) {
+	status := ExamSessionStatus{Enabled: ec.enabled()}
+	if !status.Enabled {
+		return status, nil
+	}
+	status.DurationSeconds = int64(ec.config.Exam.SessionDuration / time.Second)
+
+	session, err := ec.get(ctx, userEmail)
+	if err != nil && err != sql.ErrNoRows {
+		return status, fmt.Errorf("failed to query exam session: %w", err)
+	}
+	status.Session = session
+
+	return status, nil
+}
+
+// Start opens the user's exam session. Each user gets one session.
+// Error messages from this function can be returned to the client
+func (ec *ExamSessionClient) Start(ctx context.Context, userEmail string) (*ExamSession, error) {
+	if !ec.enabled() {
+		return nil, ClientError{Message: "Timed exam sessions are not enabled"}
+	}
+
+	// Start transaction for atomic operation
+	tx, err := ec.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO exam_sessions (user_email, started_at, expires_at)
+		VALUES ($1, NOW(), NOW() + $2::DOUBLE PRECISION * INTERVAL '1 second')
+		ON CONFLICT (user_email) DO NOTHING
+	`, userEmail, ec.config.Exam.SessionDuration.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to start exam session: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return nil, ClientError{Message: "You have already started the exam"}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO user_history_log (user_email, log, date)
+		VALUES ($1, $2, NOW())
+	`, userEmail, fmt.Sprintf("Started the exam with %s to finish it", ec.config.Exam.SessionDuration))
+	if err != nil {
+		return nil, fmt.Errorf("failed to log exam start: %w", err)
+	}
+
+	// Commit transaction
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return ec.get(ctx, userEmail)
+}
+
+// CheckActive returns a ClientError unless the user may open and submit exam challenges.
+// Without timed sessions the exam is always open.
+// Error messages from this function can be returned to the client
+func (ec *ExamSessionClient) CheckActive(ctx context.Context, userEmail string) error {
+	if !ec.enabled() {
+		return nil
+	}
+
+	session, err := ec.get(ctx, userEmail)
+	if err == sql.ErrNoRows {
+		return ClientError{Message: "Start the exam to open its challenges"}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query exam session: %w", err)
+	}
+	if session.Expired {
+		return ClientError{Message: "Your exam session has expired"}
+	}
+
+	return nil
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// maxIdempotencyKeyLength bounds the idempotency keys clients can send
+const maxIdempotencyKeyLength = 128
+
+// ErrIdempotencyKeyReused is returned when an idempotency key is sent again for a different challenge
+var ErrIdempotencyKeyReused = errors.New("idempotency key was already used for another challenge")
+
+// ExamSubmission is the result of an exam flag submission. It is stored under the
+// request's idempotency key and replayed as is when the request is retried.
+type ExamSubmission struct {
+	Message string `json:"message"`
+	// Result is unset when the challenge was already completed and nothing was charged
+	Result       SubmissionResult `json:"result,omitempty"`
+	TokensBurned int              `json:"tokens_burned"`
+	TokensEarned int              `json:"tokens_earned"`
+	PointsEarned int              `json:"points_earned"`
+	CompletedAt  *time.Time       `json:"completed_at,omitempty"`
+	// Replayed is set on results of an earlier request with the same idempotency key
+	Replayed bool `json:"replayed,omitempty"`
+}
+
+// ValidateIdempotencyKey checks a client-supplied idempotency key. Exam submissions
+// burn tokens, so every submission must carry one.
+// Error messages from this function can be returned to the client
+func ValidateIdempotencyKey(key string) error {
+	if key == "" {
+		return ClientError{Message: "Idempotency-Key header is required"}
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return ClientError{Message: fmt.Sprintf("Idempotency key cannot be longer than %d characters", maxIdempotencyKeyLength)}
+	}
+	for _, r := range key {
+		if r < '!' || r > '~' {
+			return ClientError{Message: "Idempotency key must be printable ASCII without spaces"}
+		}
+	}
+	return nil
+}
+
+// SubmitExamFlag charges an exam submission and records its already validated result in a
+// single transaction: a correct flag completes the challenge, a wrong one is recorded as
+// incorrect. Either everything is written or nothing is charged.
+//
+// The result is stored under the idempotency key, and a retry with the same key
+// returns the stored result instead of charging again. Concurrent retries wait for the
+// first request to finish.
+// Error messages for running out of tokens or attempts can be returned to the client
+func (uc *UserClient) SubmitExamFlag(ctx context.Context, userEmail string, challengeID int, submittedFlag string, result ValidationResult, idempotencyKey string) (ExamSubmission, error) {
+	var submission ExamSubmission
+
+	// Start transaction
+	tx, err := uc.db.BeginTx(ctx, nil)
+	if err != nil {
+		return submission, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// A racing request with the same key blocks here until the first one commits or rolls back
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO exam_submission_keys (user_email, idempotency_key, challenge_id, created_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (user_email, idempotency_key) DO NOTHING
+	`, userEmail, idempotencyKey, challengeID)
+	if err != nil {
+		return submission, fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+	claimed, err := res.RowsAffected()
+	if err != nil {
+		return submission, fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if claimed == 0 {
+		return uc.replayExamSubmission(ctx, tx, userEmail, challengeID, idempotencyKey)
+	}
+
+	payerEmail := userEmail
+	var completedAt time.Time
+	err = tx.QueryRowContext(ctx, `
+		SELECT completed_at FROM user_challenges_completed WHERE user_email = $1 AND challenge_id = $2
+	`, userEmail, challengeID).Scan(&completedAt)
+	switch {
+	case err == nil:
+		submission.Message = "Challenge already completed"
+		submission.CompletedAt = &completedAt
+	case err != sql.ErrNoRows:
+		return submission, fmt.Errorf("failed to check challenge completion: %w", err)
+	default:
+		tokensBurned, payer, err := uc.burnTokens(ctx, tx, userEmail, challengeID)
+		if err != nil {
+			return submission, err
+		}
+		submission.TokensBurned = tokensBurned
+		payerEmail = payer
+
+		if result.Valid {
+			tokensEarned, err := uc.completeExamChallenge(ctx, tx, userEmail, challengeID, submittedFlag, tokensBurned)
+			if err != nil {
+				return submission, err
+			}
+			submission.Result = SubmissionCorrect
+			submission.Message = "Exam challenge completed successfully!"
+			submission.TokensEarned = tokensEarned
+		} else {
+			if err := recordSubmission(ctx, tx, userEmail, challengeID, submittedFlag, SubmissionIncorrect, tokensBurned); err != nil {
+				return submission, err
+			}
+			submission.Result = SubmissionIncorrect
+			submission.Message = "Incorrect flag"
+			if result.Message != "" {
+				submission.Message = result.Message
+			}
+		}
+	}
+
+	stored, err := json.Marshal(submission)
+	if err != nil {
+		return submission, fmt.Errorf("failed to encode exam submission: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE exam_submission_keys SET result = $3 WHERE user_email = $1 AND idempotency_key = $2
+	`, userEmail, idempotencyKey, stored)
+	if err != nil {
+		return submission, fmt.Errorf("failed to store exam submission: %w", err)
+	}
+
+	// Commit transaction
+	if err = tx.Commit(); err != nil {
+		return submission, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// Invalidate cache
+	uc.mutex.Lock()
+	delete(uc.cache, userEmail)
+	delete(uc.cache, payerEmail)
+	uc.mutex.Unlock()
+
+	return submission, nil
+}
+
+// StoredExamSubmission returns the result an earlier request stored under an idempotency
+// key, if any. Retries are answered from it before the competition window or exam session
+// is checked, since the original request was already charged while they were open.
+// ErrIdempotencyKeyReused is returned when the key was used for another exam challenge.
+func (uc *UserClient) StoredExamSubmission(ctx context.Context, userEmail, idempotencyKey, track string, nestedID int) (ExamSubmission, bool, error) {
+	var submission ExamSubmission
+	var storedTrack string
+	var storedNestedID int
+	var stored []byte
+	err := uc.db.QueryRowContext(ctx, `
+		SELECT COALESCE(c.exam_track, ''), c.nested_id, k.result
+		FROM exam_submission_keys k
+		JOIN challenges c ON c.id = k.challenge_id
+		WHERE k.user_email = $1 AND k.idempotency_key = $2
+	`, userEmail, idempotencyKey).Scan(&storedTrack, &storedNestedID, &stored)
+	if err == sql.ErrNoRows {
+		return submission, false, nil
+	}
+	if err != nil {
+		return submission, false, fmt.Errorf("failed to query idempotency key: %w", err)
+	}
+	if storedTrack != track || storedNestedID != nestedID {
+		return submission, false, ErrIdempotencyKeyReused
+	}
+
+	if err := json.Unmarshal(stored, &submission); err != nil {
+		return submission, false, fmt.Errorf("failed to decode exam submission: %w", err)
+	}
+	submission.Replayed = true
+
+	return submission, true, nil
+}
+
+// replayExamSubmission returns the result stored under an idempotency key
+func (uc *UserClient) replayExamSubmission(ctx context.Context, tx *sql.Tx, userEmail string, challengeID int, idempotencyKey string) (ExamSubmission, error) {
+	var submission ExamSubmission
+	var storedChallengeID int
+	var stored []byte
+	err := tx.QueryRowContext(ctx, `
+		SELECT challenge_id, result FROM exam_submission_keys WHERE user_email = $1 AND idempotency_key = $2
+	`, userEmail, idempotencyKey).Scan(&storedChallengeID, &stored)
+	if err != nil {
+		return submission, fmt.Errorf("failed to query idempotency key: %w", err)
+	}
+	if storedChallengeID != challengeID {
+		return submission, ErrIdempotencyKeyReused
+	}
+
+	if err := json.Unmarshal(stored, &submission); err != nil {
+		return submission, fmt.Errorf("failed to decode exam submission: %w", err)
+	}
+	submission.Replayed = true
+
+	return submission, nil
+}
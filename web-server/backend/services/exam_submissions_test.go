@@ -0,0 +1,37 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateIdempotencyKey(t *testing.T) {
+	accepted := []string{
+		"3f2b8c1e-9d4a-4e7b-8a6f-2c1d0e9b7a55",
+		"!~#$%&'()*+,-./:;<=>?@[]^_`{|}",
+		strings.Repeat("k", maxIdempotencyKeyLength),
+	}
+	for _, key := range accepted {
+		if err := ValidateIdempotencyKey(key); err != nil {
+			t.Errorf("ValidateIdempotencyKey(%q) = %v, want nil", key, err)
+		}
+	}
+
+	rejected := []string{
+		"",
+		strings.Repeat("k", maxIdempotencyKeyLength+1),
+		"two words",
+		"key\t1",
+		"key\n",
+		"key\x7f",
+		"clé",
+	}
+	for _, key := range rejected {
+		err := ValidateIdempotencyKey(key)
+		if err == nil {
+			t.Errorf("ValidateIdempotencyKey(%q) = nil, want an error", key)
+		} else if !IsClientError(err) {
+			t.Errorf("ValidateIdempotencyKey(%q) = %v, want a ClientError", key, err)
+		}
+	}
+}
@@ -0,0 +1,493 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// DefaultExamTrack holds exam challenges that don't name a track
+const DefaultExamTrack = "main"
+
+// examTrackNamePattern keeps track names short and usable in URLs
+var examTrackNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// TrackRequirement requires solving Stage stages of Track
+type TrackRequirement struct {
+	Track string `json:"track"`
+	Stage int    `json:"stage"`
+}
+
+// ExamTrack is a sequential chain of exam challenges as managed by admins
+type ExamTrack struct {
+	Name string `json:"name"`
+	// Requires must all be met before users can open the track
+	Requires []TrackRequirement `json:"requires,omitempty"`
+	// Stages is the number of exam challenges in the track (read only)
+	Stages int `json:"stages"`
+}
+
+// ExamTrackProgress is a user's progress through an exam track
+type ExamTrackProgress struct {
+	Name         string `json:"name"`
+	Stages       int    `json:"stages"`
+	StagesSolved int    `json:"stages_solved"`
+	// Locked tracks open once every requirement is met
+	Locked   bool               `json:"locked"`
+	Requires []TrackRequirement `json:"requires,omitempty"`
+}
+
+// ExamTrackClient manages exam tracks and reports users' progress through them
+type ExamTrackClient struct {
+	db *sql.DB
+}
+
+// NewExamTrackClient creates a new exam track client
+func NewExamTrackClient(db *sql.DB) *ExamTrackClient {
+	return &ExamTrackClient{db: db}
+}
+
+// validateTrackName checks that a track name can be used in URLs
+// Error messages from this function can be returned to the client
+func validateTrackName(name string) error {
+	if !examTrackNamePattern.MatchString(name) {
+		return ClientError{Message: fmt.Sprintf("Exam track name %q must be 1-32 lowercase letters, digits, dashes or underscores", name)}
+	}
+	return nil
+}
+
+// validateExamTrack checks a track's name and requirements, sorting the requirements.
+// References to other tracks are checked when the track is written.
+// Error messages from this function can be returned to the client
+func validateExamTrack(track *ExamTrack) error {
+	track.Name = strings.TrimSpace(track.Name)
+	if err := validateTrackName(track.Name); err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	for _, requirement := range track.Requires {
+		if requirement.Track == track.Name {
+			return ClientError{Message: "An exam track cannot require itself"}
+		}
+		if seen[requirement.Track] {
+			return ClientError{Message: fmt.Sprintf("Exam track %s is required twice", requirement.Track)}
+		}
+		if requirement.Stage < 1 {
+			return ClientError{Message: "Required stage must be positive"}
+		}
+		seen[requirement.Track] = true
+	}
+	sort.Slice(track.Requires, func(i, j int) bool {
+		return track.Requires[i].Track < track.Requires[j].Track
+	})
+
+	return nil
+}
+
+// validateChallengeTrack defaults an exam challenge's track and checks its name.
+// Error messages from this function can be returned to the client
+func validateChallengeTrack(def *ChallengeDefinition) error {
+	def.ExamTrack = strings.TrimSpace(def.ExamTrack)
+	if def.Category != "exam" {
+		if def.ExamTrack != "" {
+			return ClientError{Message: "Only exam challenges belong to an exam track"}
+		}
+		return nil
+	}
+
+	if def.ExamTrack == "" {
+		def.ExamTrack = DefaultExamTrack
+	}
+	return validateTrackName(def.ExamTrack)
+}
+
+// trackSatisfied reports whether the solved stages meet every requirement
+func trackSatisfied(requires []TrackRequirement, solved map[string]int) bool {
+	for _, requirement := range requires {
+		if solved[requirement.Track] < requirement.Stage {
+			return false
+		}
+	}
+	return true
+}
+
+// loadTrackRequirements returns the requirements of every track, keyed by track name
+func loadTrackRequirements(ctx context.Context, db querier) (map[string][]TrackRequirement, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT track, required_track, required_stage
+		FROM exam_track_requirements
+		ORDER BY track, required_track
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exam track requirements: %w", err)
+	}
+	defer rows.Close()
+
+	requirements := make(map[string][]TrackRequirement)
+	for rows.Next() {
+		var track string
+		var requirement TrackRequirement
+		if err := rows.Scan(&track, &requirement.Track, &requirement.Stage); err != nil {
+			return nil, fmt.Errorf("failed to scan exam track requirement: %w", err)
+		}
+		requirements[track] = append(requirements[track], requirement)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return requirements, nil
+}
+
+// findTrackCycle returns a cycle of track requirements, starting and ending at
+// the same track, or nil if there is none
+func findTrackCycle(requirements map[string][]TrackRequirement) []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	var path []string
+
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		state[name] = visiting
+		path = append(path, name)
+		for _, requirement := range requirements[name] {
+			switch state[requirement.Track] {
+			case visiting:
+				for i, onPath := range path {
+					if onPath == requirement.Track {
+						return append(append([]string{}, path[i:]...), requirement.Track)
+					}
+				}
+			case unvisited:
+				if cycle := visit(requirement.Track); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+
+	// Visit in name order so the reported cycle is stable
+	names := make([]string, 0, len(requirements))
+	for name := range requirements {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if state[name] == unvisited {
+			if cycle := visit(name); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// List returns every exam track with its requirements and number of stages
+func (tc *ExamTrackClient) List(ctx context.Context) ([]ExamTrack, error) {
+	rows, err := tc.db.QueryContext(ctx, `
+		SELECT t.name,
+		       (SELECT COUNT(*) FROM challenges c WHERE c.category = 'exam' AND c.exam_track = t.name)
+		FROM exam_tracks t
+		ORDER BY t.name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exam tracks: %w", err)
+	}
+	defer rows.Close()
+
+	tracks := make([]ExamTrack, 0)
+	for rows.Next() {
+		var track ExamTrack
+		if err := rows.Scan(&track.Name, &track.Stages); err != nil {
+			return nil, fmt.Errorf("failed to scan exam track: %w", err)
+		}
+		tracks = append(tracks, track)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	requirements, err := loadTrackRequirements(ctx, tc.db)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tracks {
+		tracks[i].Requires = requirements[tracks[i].Name]
+	}
+
+	return tracks, nil
+}
+
+// Get returns a single exam track
+// Returns sql.ErrNoRows if the track doesn't exist
+func (tc *ExamTrackClient) Get(ctx context.Context, name string) (*ExamTrack, error) {
+	tracks, err := tc.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tracks {
+		if tracks[i].Name == name {
+			return &tracks[i], nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// Put creates an exam track or replaces its requirements, rejecting
+// unknown tracks and requirements that would lock tracks in a cycle
+// Error messages from this function can be returned to the client
+func (tc *ExamTrackClient) Put(ctx context.Context, adminEmail string, track ExamTrack) error {
+	if err := validateExamTrack(&track); err != nil {
+		return err
+	}
+
+	// Start transaction
+	tx, err := tc.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Serialize requirement changes so concurrent writes can't form a cycle between them
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE exam_track_requirements IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return fmt.Errorf("failed to lock exam track requirements: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO exam_tracks (name, created_at)
+		VALUES ($1, NOW())
+		ON CONFLICT (name) DO NOTHING
+	`, track.Name)
+	if err != nil {
+		return fmt.Errorf("failed to insert exam track: %w", err)
+	}
+
+	required := make([]string, len(track.Requires))
+	for i, requirement := range track.Requires {
+		required[i] = requirement.Track
+	}
+	var missing []string
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(ARRAY_AGG(r.name ORDER BY r.name), '{}')
+		FROM UNNEST($1::TEXT[]) AS r(name)
+		WHERE NOT EXISTS (SELECT 1 FROM exam_tracks t WHERE t.name = r.name)
+	`, pq.Array(required)).Scan(pq.Array(&missing))
+	if err != nil {
+		return fmt.Errorf("failed to check required exam tracks: %w", err)
+	}
+	if len(missing) > 0 {
+		return ClientError{Message: fmt.Sprintf("Unknown exam track in requirements: %s", strings.Join(missing, ", "))}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM exam_track_requirements WHERE track = $1`, track.Name)
+	if err != nil {
+		return fmt.Errorf("failed to clear exam track requirements: %w", err)
+	}
+
+	for _, requirement := range track.Requires {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO exam_track_requirements (track, required_track, required_stage)
+			VALUES ($1, $2, $3)
+		`, track.Name, requirement.Track, requirement.Stage)
+		if err != nil {
+			return fmt.Errorf("failed to insert exam track requirement: %w", err)
+		}
+	}
+
+	requirements, err := loadTrackRequirements(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if cycle := findTrackCycle(requirements); cycle != nil {
+		return ClientError{Message: fmt.Sprintf("Exam track requirements would create a cycle: %s", strings.Join(cycle, " -> "))}
+	}
+
+	if err := logAdminAction(ctx, tx, adminEmail, fmt.Sprintf("Set exam track '%s'", track.Name)); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes an exam track that no challenge uses and no track requires.
+// Users' progress through the track is removed with it.
+// Returns sql.ErrNoRows if the track doesn't exist
+func (tc *ExamTrackClient) Delete(ctx context.Context, adminEmail, name string) error {
+	if name == DefaultExamTrack {
+		return ClientError{Message: fmt.Sprintf("The %s exam track cannot be deleted", DefaultExamTrack)}
+	}
+
+	// Start transaction
+	tx, err := tc.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE exam_track_requirements IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return fmt.Errorf("failed to lock exam track requirements: %w", err)
+	}
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM exam_tracks WHERE name = $1)`, name).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check exam track: %w", err)
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+
+	var challenges int
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM challenges WHERE exam_track = $1`, name).Scan(&challenges)
+	if err != nil {
+		return fmt.Errorf("failed to count exam track challenges: %w", err)
+	}
+	if challenges > 0 {
+		return ClientError{Message: fmt.Sprintf("Exam track %s still has %d challenges", name, challenges)}
+	}
+
+	var dependents []string
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(ARRAY_AGG(track ORDER BY track), '{}') FROM exam_track_requirements WHERE required_track = $1
+	`, name).Scan(pq.Array(&dependents))
+	if err != nil {
+		return fmt.Errorf("failed to check dependent exam tracks: %w", err)
+	}
+	if len(dependents) > 0 {
+		return ClientError{Message: fmt.Sprintf("Exam track %s is required by %s", name, strings.Join(dependents, ", "))}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM exam_tracks WHERE name = $1`, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete exam track: %w", err)
+	}
+
+	if err := logAdminAction(ctx, tx, adminEmail, fmt.Sprintf("Deleted exam track '%s'", name)); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ListForUser returns the user's progress through every track with visible challenges
+func (tc *ExamTrackClient) ListForUser(ctx context.Context, userEmail string) ([]ExamTrackProgress, error) {
+	rows, err := tc.db.QueryContext(ctx, `
+		SELECT t.name,
+		       (SELECT COUNT(*) FROM challenges c
+		        WHERE c.category = 'exam' AND c.exam_track = t.name AND NOT c.hidden AND (c.release_at IS NULL OR c.release_at <= NOW())),
+		       COALESCE(p.stages_solved, 0)
+		FROM exam_tracks t
+		LEFT JOIN user_exam_progress p ON p.track = t.name AND p.user_email = $1
+		ORDER BY t.name
+	`, userEmail)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exam track progress: %w", err)
+	}
+	defer rows.Close()
+
+	all := make([]ExamTrackProgress, 0)
+	solved := make(map[string]int)
+	for rows.Next() {
+		var progress ExamTrackProgress
+		if err := rows.Scan(&progress.Name, &progress.Stages, &progress.StagesSolved); err != nil {
+			return nil, fmt.Errorf("failed to scan exam track progress: %w", err)
+		}
+		solved[progress.Name] = progress.StagesSolved
+		all = append(all, progress)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	requirements, err := loadTrackRequirements(ctx, tc.db)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tracks without visible challenges are left out so unreleased tracks don't leak
+	tracks := make([]ExamTrackProgress, 0, len(all))
+	for _, progress := range all {
+		if progress.Stages == 0 {
+			continue
+		}
+		progress.Requires = requirements[progress.Name]
+		progress.Locked = !trackSatisfied(progress.Requires, solved)
+		tracks = append(tracks, progress)
+	}
+
+	return tracks, nil
+}
+
+// Progress returns the user's progress through a single track
+// Returns sql.ErrNoRows if the track doesn't exist or has no visible challenges
+func (tc *ExamTrackClient) Progress(ctx context.Context, userEmail, name string) (*ExamTrackProgress, error) {
+	tracks, err := tc.ListForUser(ctx, userEmail)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tracks {
+		if tracks[i].Name == name {
+			return &tracks[i], nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// checkExamStageFree ensures an exam challenge's track exists and no other
+// challenge in the track uses the same nested ID
+func checkExamStageFree(ctx context.Context, tx *sql.Tx, def *ChallengeDefinition) error {
+	if def.Category != "exam" {
+		return nil
+	}
+
+	var trackExists bool
+	err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM exam_tracks WHERE name = $1)`, def.ExamTrack).Scan(&trackExists)
+	if err != nil {
+		return fmt.Errorf("failed to check exam track: %w", err)
+	}
+	if !trackExists {
+		return ClientError{Message: fmt.Sprintf("Unknown exam track: %s", def.ExamTrack)}
+	}
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM challenges WHERE category = 'exam' AND exam_track = $1 AND nested_id = $2 AND id != $3
+		)
+	`, def.ExamTrack, def.NestedID, def.ID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check exam nested ID: %w", err)
+	}
+	if exists {
+		return ClientError{Message: fmt.Sprintf("Exam nested ID %d is already in use in track %s", def.NestedID, def.ExamTrack)}
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFindTrackCycle(t *testing.T) {
+	requires := func(tracks ...string) []TrackRequirement {
+		requirements := make([]TrackRequirement, 0, len(tracks))
+		for _, track := range tracks {
+			requirements = append(requirements, TrackRequirement{Track: track, Stage: 1})
+		}
+		return requirements
+	}
+
+	tests := []struct {
+		name         string
+		requirements map[string][]TrackRequirement
+		want         []string
+	}{
+		{"no tracks", map[string][]TrackRequirement{}, nil},
+		{"no requirements", map[string][]TrackRequirement{"main": nil, "web": nil}, nil},
+		{"chain", map[string][]TrackRequirement{
+			"crypto": requires("web"),
+			"web":    requires("main"),
+			"main":   nil,
+		}, nil},
+		{"diamond", map[string][]TrackRequirement{
+			"final":  requires("crypto", "web"),
+			"crypto": requires("main"),
+			"web":    requires("main"),
+			"main":   nil,
+		}, nil},
+		{"requirement on an unlisted track", map[string][]TrackRequirement{
+			"web": requires("main"),
+		}, nil},
+		{"self requirement", map[string][]TrackRequirement{
+			"web": requires("web"),
+		}, []string{"web", "web"}},
+		{"two tracks", map[string][]TrackRequirement{
+			"crypto": requires("web"),
+			"web":    requires("crypto"),
+		}, []string{"crypto", "web", "crypto"}},
+		{"cycle behind an acyclic prefix", map[string][]TrackRequirement{
+			"a": requires("b"),
+			"b": requires("c"),
+			"c": requires("d"),
+			"d": requires("b"),
+		}, []string{"b", "c", "d", "b"}},
+		{"cycle found in name order", map[string][]TrackRequirement{
+			"z": requires("y"),
+			"y": requires("z"),
+			"m": requires("n"),
+			"n": requires("m"),
+		}, []string{"m", "n", "m"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findTrackCycle(tt.requirements); !slices.Equal(got, tt.want) {
+				t.Errorf("findTrackCycle() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateExamTrack(t *testing.T) {
+	track := ExamTrack{
+		Name:     " crypto ",
+		Requires: []TrackRequirement{{Track: "web", Stage: 2}, {Track: "main", Stage: 3}},
+	}
+	if err := validateExamTrack(&track); err != nil {
+		t.Fatalf("validateExamTrack() = %v", err)
+	}
+	if track.Name != "crypto" {
+		t.Errorf("name = %q, want it trimmed to %q", track.Name, "crypto")
+	}
+	if track.Requires[0].Track != "main" || track.Requires[1].Track != "web" {
+		t.Errorf("requirements = %v, want them sorted by track", track.Requires)
+	}
+
+	for _, bad := range []ExamTrack{
+		{Name: ""},
+		{Name: "Crypto"},
+		{Name: "-crypto"},
+		{Name: "crypto/web"},
+		{Name: "crypto", Requires: []TrackRequirement{{Track: "crypto", Stage: 1}}},
+		{Name: "crypto", Requires: []TrackRequirement{{Track: "web", Stage: 1}, {Track: "web", Stage: 2}}},
+		{Name: "crypto", Requires: []TrackRequirement{{Track: "web", Stage: 0}}},
+	} {
+		if err := validateExamTrack(&bad); !IsClientError(err) {
+			t.Errorf("validateExamTrack(%+v) = %v, want a ClientError", bad, err)
+		}
+	}
+}
+
+func TestTrackSatisfied(t *testing.T) {
+	requires := []TrackRequirement{{Track: "main", Stage: 2}, {Track: "web", Stage: 1}}
+
+	if !trackSatisfied(nil, nil) {
+		t.Error("a track without requirements should always be open")
+	}
+	if !trackSatisfied(requires, map[string]int{"main": 2, "web": 1}) {
+		t.Error("exactly meeting every requirement should open the track")
+	}
+	if !trackSatisfied(requires, map[string]int{"main": 5, "web": 3, "crypto": 1}) {
+		t.Error("exceeding every requirement should open the track")
+	}
+	if trackSatisfied(requires, map[string]int{"main": 1, "web": 1}) {
+		t.Error("one stage short of a requirement should keep the track locked")
+	}
+	if trackSatisfied(requires, map[string]int{"main": 2}) {
+		t.Error("an untouched required track should keep the track locked")
+	}
+}
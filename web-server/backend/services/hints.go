@@ -0,0 +1,426 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// HintCostType is the currency a hint is paid for in
+type HintCostType string
+
+const (
+	HintCostTokens HintCostType = "tokens"
+	HintCostPoints HintCostType = "points"
+)
+
+// maxHintLength bounds the text of a single hint
+const maxHintLength = 4096
+
+// Hint is a challenge hint as shown to a player. Text is only set once unlocked.
+type Hint struct {
+	ID          int          `json:"id"`
+	ChallengeID int          `json:"challenge_id"`
+	Position    int          `json:"position"`
+	Cost        int          `json:"cost"`
+	CostType    HintCostType `json:"cost_type"`
+	Unlocked    bool         `json:"unlocked"`
+	Text        *string      `json:"text,omitempty"`
+	// Challenge is the challenge's name, used for notifications
+	Challenge string `json:"-"`
+}
+
+// HintDefinition is a hint as managed by admins
+type HintDefinition struct {
+	ID          int          `json:"id"`
+	ChallengeID int          `json:"challenge_id"`
+	Position    int          `json:"position"`
+	Text        string       `json:"text"`
+	Cost        int          `json:"cost"`
+	CostType    HintCostType `json:"cost_type"`
+	// Unlocks is the number of users who unlocked the hint (read only)
+	Unlocks int `json:"unlocks"`
+}
+
+// HintUnlock records a user paying for a hint
+type HintUnlock struct {
+	HintID      int          `json:"hint_id"`
+	ChallengeID int          `json:"challenge_id"`
+	Challenge   string       `json:"challenge"`
+	UserEmail   string       `json:"user_email"`
+	Cost        int          `json:"cost"`
+	CostType    HintCostType `json:"cost_type"`
+	UnlockedAt  time.Time    `json:"unlocked_at"`
+}
+
+// HintUnlockPage represents a paginated slice of hint unlocks
+type HintUnlockPage struct {
+	Unlocks []HintUnlock `json:"unlocks"`
+	Total   int          `json:"total"`
+	Limit   int          `json:"limit"`
+	Offset  int          `json:"offset"`
+}
+
+// HintUnlockFilter narrows a hint unlock listing. Zero values match everything.
+type HintUnlockFilter struct {
+	UserEmail   string
+	ChallengeID int
+}
+
+// HintClient manages challenge hints
+type HintClient struct {
+	db *sql.DB
+}
+
+// NewHintClient creates a new hint client
+func NewHintClient(db *sql.DB) *HintClient {
+	return &HintClient{db: db}
+}
+
+// ListForUser returns a visible challenge's hints, with the text of those the user unlocked
+// Returns sql.ErrNoRows if the challenge doesn't exist, is hidden, is not released yet or is an exam challenge
+func (hc *HintClient) ListForUser(ctx context.Context, challengeID int, userEmail string) ([]Hint, error) {
+	var exists bool
+	err := hc.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM challenges
+			WHERE id = $1 AND category != 'exam' AND NOT hidden AND (release_at IS NULL OR release_at <= NOW())
+		)
+	`, challengeID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check challenge: %w", err)
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+
+	rows, err := hc.db.QueryContext(ctx, `
+		SELECT h.id, h.challenge_id, h.position, h.cost, h.cost_type,
+		       hu.hint_id IS NOT NULL AS unlocked, h.text
+		FROM hints h
+		LEFT JOIN hint_unlocks hu ON hu.hint_id = h.id AND hu.user_email = $2
+		WHERE h.challenge_id = $1
+		ORDER BY h.position, h.id
+	`, challengeID, userEmail)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query hints: %w", err)
+	}
+	defer rows.Close()
+
+	hints := make([]Hint, 0)
+	for rows.Next() {
+		var hint Hint
+		var text string
+		if err := rows.Scan(&hint.ID, &hint.ChallengeID, &hint.Position, &hint.Cost, &hint.CostType, &hint.Unlocked, &text); err != nil {
+			return nil, fmt.Errorf("failed to scan hint: %w", err)
+		}
+		if hint.Unlocked {
+			hint.Text = &text
+		}
+		hints = append(hints, hint)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return hints, nil
+}
+
+// ListDefinitions returns a challenge's hints with their unlock counts
+func (hc *HintClient) ListDefinitions(ctx context.Context, challengeID int) ([]HintDefinition, error) {
+	rows, err := hc.db.QueryContext(ctx, `
+		SELECT h.id, h.challenge_id, h.position, h.text, h.cost, h.cost_type,
+		       (SELECT COUNT(*) FROM hint_unlocks hu WHERE hu.hint_id = h.id)
+		FROM hints h
+		WHERE h.challenge_id = $1
+		ORDER BY h.position, h.id
+	`, challengeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query hints: %w", err)
+	}
+	defer rows.Close()
+
+	definitions := make([]HintDefinition, 0)
+	for rows.Next() {
+		var def HintDefinition
+		if err := rows.Scan(&def.ID, &def.ChallengeID, &def.Position, &def.Text, &def.Cost, &def.CostType, &def.Unlocks); err != nil {
+			return nil, fmt.Errorf("failed to scan hint: %w", err)
+		}
+		definitions = append(definitions, def)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return definitions, nil
+}
+
+// validateHint checks a hint definition before it is written
+// Error messages from this function can be returned to the client
+func validateHint(def *HintDefinition) error {
+	def.Text = strings.TrimSpace(def.Text)
+	if def.Text == "" {
+		return ClientError{Message: "Hint text cannot be empty"}
+	}
+	if len(def.Text) > maxHintLength {
+		return ClientError{Message: fmt.Sprintf("Hint text cannot be longer than %d characters", maxHintLength)}
+	}
+	if def.Cost < 0 {
+		return ClientError{Message: "Hint cost cannot be negative"}
+	}
+	if def.CostType != HintCostTokens && def.CostType != HintCostPoints {
+		return ClientError{Message: fmt.Sprintf("Hint cost type must be %s or %s", HintCostTokens, HintCostPoints)}
+	}
+	return nil
+}
+
+// Create adds a hint to a challenge
+// Returns sql.ErrNoRows if the challenge doesn't exist
+func (hc *HintClient) Create(ctx context.Context, adminEmail string, def HintDefinition) (*HintDefinition, error) {
+	if err := validateHint(&def); err != nil {
+		return nil, err
+	}
+
+	// Start transaction
+	tx, err := hc.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM challenges WHERE id = $1)`, def.ChallengeID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check challenge: %w", err)
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO hints (challenge_id, position, text, cost, cost_type, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		RETURNING id
+	`, def.ChallengeID, def.Position, def.Text, def.Cost, def.CostType).Scan(&def.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert hint: %w", err)
+	}
+
+	if err := logAdminAction(ctx, tx, adminEmail, fmt.Sprintf("Added hint %d costing %d %s to challenge %d", def.ID, def.Cost, def.CostType, def.ChallengeID)); err != nil {
+		return nil, err
+	}
+
+	// Commit transaction
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &def, nil
+}
+
+// Delete removes a hint from a challenge along with its unlock records
+// Returns sql.ErrNoRows if the hint doesn't exist
+func (hc *HintClient) Delete(ctx context.Context, adminEmail string, challengeID, hintID int) error {
+	// Start transaction
+	tx, err := hc.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Points already spent on the hint stay spent
+	if err := keepHintCosts(ctx, tx, hintID); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM hints WHERE id = $1 AND challenge_id = $2`, hintID, challengeID)
+	if err != nil {
+		return fmt.Errorf("failed to delete hint: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	if err := logAdminAction(ctx, tx, adminEmail, fmt.Sprintf("Removed hint %d from challenge %d", hintID, challengeID)); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ListUnlocks returns a page of hint unlocks matching the filter, newest first
+func (hc *HintClient) ListUnlocks(ctx context.Context, filter HintUnlockFilter, limit, offset int) (HintUnlockPage, error) {
+	page := HintUnlockPage{
+		Unlocks: make([]HintUnlock, 0),
+		Limit:   limit,
+		Offset:  offset,
+	}
+
+	const where = `
+		WHERE ($1 = '' OR hu.user_email = $1)
+		AND ($2 = 0 OR hu.challenge_id = $2)
+	`
+
+	err := hc.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM hint_unlocks hu`+where, filter.UserEmail, filter.ChallengeID).Scan(&page.Total)
+	if err != nil {
+		return page, fmt.Errorf("failed to count hint unlocks: %w", err)
+	}
+
+	rows, err := hc.db.QueryContext(ctx, `
+		SELECT hu.hint_id, hu.challenge_id, c.name, hu.user_email, hu.cost, hu.cost_type, hu.unlocked_at
+		FROM hint_unlocks hu
+		JOIN challenges c ON c.id = hu.challenge_id`+where+`
+		ORDER BY hu.unlocked_at DESC, hu.hint_id DESC
+		LIMIT $3 OFFSET $4
+	`, filter.UserEmail, filter.ChallengeID, limit, offset)
+	if err != nil {
+		return page, fmt.Errorf("failed to query hint unlocks: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var unlock HintUnlock
+		if err := rows.Scan(
+			&unlock.HintID,
+			&unlock.ChallengeID,
+			&unlock.Challenge,
+			&unlock.UserEmail,
+			&unlock.Cost,
+			&unlock.CostType,
+			&unlock.UnlockedAt,
+		); err != nil {
+			return page, fmt.Errorf("failed to scan hint unlock: %w", err)
+		}
+		page.Unlocks = append(page.Unlocks, unlock)
+	}
+
+	if err := rows.Err(); err != nil {
+		return page, fmt.Errorf("rows error: %w", err)
+	}
+
+	return page, nil
+}
+
+// UnlockHint charges a user for a hint and reveals it. Unlocking a hint the user
+// already paid for reveals it again without charging; the returned bool reports
+// whether the user was charged.
+// Returns sql.ErrNoRows if the hint doesn't belong to a visible challenge
+func (uc *UserClient) UnlockHint(ctx context.Context, userEmail string, challengeID, hintID int) (*Hint, bool, error) {
+	// Start transaction
+	tx, err := uc.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	hint := Hint{Unlocked: true}
+	var text string
+	err = tx.QueryRowContext(ctx, `
+		SELECT h.id, h.challenge_id, h.position, h.cost, h.cost_type, h.text, c.name
+		FROM hints h
+		JOIN challenges c ON c.id = h.challenge_id
+		WHERE h.id = $1 AND h.challenge_id = $2 AND c.category != 'exam' AND NOT c.hidden
+		  AND (c.release_at IS NULL OR c.release_at <= NOW())
+	`, hintID, challengeID).Scan(&hint.ID, &hint.ChallengeID, &hint.Position, &hint.Cost, &hint.CostType, &text, &hint.Challenge)
+	if err != nil {
+		return nil, false, err
+	}
+	hint.Text = &text
+
+	// Claim the unlock first; the primary key makes concurrent unlocks of the same hint wait here
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO hint_unlocks (hint_id, user_email, challenge_id, cost, cost_type, unlocked_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (hint_id, user_email) DO NOTHING
+	`, hint.ID, userEmail, hint.ChallengeID, hint.Cost, hint.CostType)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to record hint unlock: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		// Already unlocked
+		return &hint, false, nil
+	}
+
+	if hint.Cost > 0 && hint.CostType == HintCostPoints {
+		if err := freezeScoreboard(ctx, tx, uc.config.Competition); err != nil {
+			return nil, false, err
+		}
+
+		// Points are derived, so check the balance on the locked row and rederive it with the unlock
+		var points int
+		err := tx.QueryRowContext(ctx, `
+			SELECT points_achieved FROM users WHERE user_email = $1 FOR NO KEY UPDATE
+		`, userEmail).Scan(&points)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, false, fmt.Errorf("failed to check points: %w", err)
+		}
+		if points < hint.Cost {
+			return nil, false, ClientError{Message: fmt.Sprintf("Not enough %s to unlock this hint", hint.CostType)}
+		}
+
+		if err := refreshUserPoints(ctx, tx, []string{userEmail}); err != nil {
+			return nil, false, err
+		}
+	} else if hint.Cost > 0 {
+		// Atomic check and deduct in a single query
+		result, err := tx.ExecContext(ctx, `
+			UPDATE users
+			SET tokens_available = tokens_available - $2
+			WHERE user_email = $1 AND tokens_available >= $2
+		`, userEmail, hint.Cost)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to charge for hint: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to check rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return nil, false, ClientError{Message: fmt.Sprintf("Not enough %s to unlock this hint", hint.CostType)}
+		}
+		if err := recordLedgerEntry(ctx, tx, userEmail, LedgerHint, -hint.Cost, &challengeID, userEmail, fmt.Sprintf("Hint %d", hint.ID)); err != nil {
+			return nil, false, err
+		}
+	}
+
+	// Log to user history
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO user_history_log (user_email, log, date)
+		VALUES ($1, $2, NOW())
+	`, userEmail, fmt.Sprintf("Unlocked hint %d for challenge %d: spent %d %s", hint.ID, hint.ChallengeID, hint.Cost, hint.CostType))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to log hint unlock: %w", err)
+	}
+
+	// Commit transaction
+	if err = tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// Invalidate cache
+	uc.mutex.Lock()
+	delete(uc.cache, userEmail)
+	uc.mutex.Unlock()
+
+	return &hint, true, nil
+}
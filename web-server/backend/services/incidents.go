@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// textAssetFlagPlaceholder is replaced with the user's derived flag when rendering text assets
+const textAssetFlagPlaceholder = "{{flag}}"
+
+// CheatingIncident records a submission of another user's answer
+type CheatingIncident struct {
+	ID          int       `json:"id"`
+	UserEmail   string    `json:"user_email"`
+	OwnerEmail  string    `json:"owner_email"`
+	ChallengeID int       `json:"challenge_id"`
+	Challenge   string    `json:"challenge"`
+	Kind        string    `json:"kind"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// CheatingIncidentPage represents a paginated slice of recorded incidents
+type CheatingIncidentPage struct {
+	Incidents []CheatingIncident `json:"incidents"`
+	Total     int                `json:"total"`
+	Limit     int                `json:"limit"`
+	Offset    int                `json:"offset"`
+}
+
+// RenderTextAsset substitutes the user's derived flag into a challenge's text asset.
+// Text assets without the placeholder, or whose handler does not derive flags, are
+// returned unchanged.
+func (cc *ChallengeClient) RenderTextAsset(ctx context.Context, challengeID int, textAsset, userEmail string) (string, error) {
+	if !strings.Contains(textAsset, textAssetFlagPlaceholder) {
+		return textAsset, nil
+	}
+
+	flag := ChallengeFlag{ChallengeID: challengeID}
+	var params []byte
+	err := cc.database.QueryRowContext(ctx, `
+		SELECT flag_value, validation_handler, validation_params
+		FROM flags
+		WHERE challenge_id = $1
+	`, challengeID).Scan(&flag.FlagValue, &flag.ValidationHandler, &params)
+	if err != nil {
+		return "", fmt.Errorf("failed to query flag for text asset: %w", err)
+	}
+	flag.ValidationParams = params
+
+	validator, exists := cc.validators.Get(flag.ValidationHandler)
+	if !exists {
+		return "", fmt.Errorf("unknown validation handler %s for challenge %d", flag.ValidationHandler, challengeID)
+	}
+
+	deriver, ok := validator.(FlagDeriver)
+	if !ok {
+		return textAsset, nil
+	}
+
+	derived, err := deriver.DeriveFlag(ValidationInput{
+		ChallengeID: challengeID,
+		UserEmail:   userEmail,
+		Expected:    flag.FlagValue,
+		Params:      flag.ValidationParams,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to derive flag: %w", err)
+	}
+
+	return strings.ReplaceAll(textAsset, textAssetFlagPlaceholder, derived), nil
+}
+
+// DetectFlagSharing checks if a wrong submission is another user's correct answer.
+// Returns the email of the user the answer belongs to.
+func (cc *ChallengeClient) DetectFlagSharing(ctx context.Context, flag *ChallengeFlag, submittedFlag, userEmail string) (string, bool, error) {
+	validator, exists := cc.validators.Get(flag.ValidationHandler)
+	if !exists {
+		return "", false, nil
+	}
+
+	detector, ok := validator.(SharingDetector)
+	if !ok {
+		return "", false, nil
+	}
+
+	input := ValidationInput{
+		ChallengeID: flag.ChallengeID,
+		UserEmail:   userEmail,
+		Submitted:   submittedFlag,
+		Expected:    flag.FlagValue,
+		Params:      flag.ValidationParams,
+	}
+
+	// Most wrong submissions are nobody's answer; skip loading users for them
+	hint, ok := detector.OwnerHint(input)
+	if !ok {
+		return "", false, nil
+	}
+
+	candidates, err := cc.sharingCandidates(ctx, hint)
+	if err != nil {
+		return "", false, err
+	}
+
+	owner, found := detector.FindOwner(input, candidates)
+	return owner, found, nil
+}
+
+// sharingCandidates returns the users whose email appears in hint, or every user if hint is empty
+func (cc *ChallengeClient) sharingCandidates(ctx context.Context, hint string) ([]string, error) {
+	rows, err := cc.database.QueryContext(ctx, `
+		SELECT user_email FROM users
+		WHERE $1 = '' OR STRPOS(LOWER($1), LOWER(user_email)) > 0
+	`, hint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	candidates := make([]string, 0)
+	for rows.Next() {
+		var candidate string
+		if err := rows.Scan(&candidate); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return candidates, nil
+}
+
+// RecordCheatingIncident stores a detected submission of another user's answer
+func (cc *ChallengeClient) RecordCheatingIncident(ctx context.Context, incident CheatingIncident) error {
+	_, err := cc.database.ExecContext(ctx, `
+		INSERT INTO cheating_incidents (user_email, owner_email, challenge_id, kind, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+	`, incident.UserEmail, incident.OwnerEmail, incident.ChallengeID, incident.Kind)
+	if err != nil {
+		return fmt.Errorf("failed to record cheating incident: %w", err)
+	}
+	return nil
+}
+
+// ListCheatingIncidents returns a page of recorded incidents, newest first
+func (cc *ChallengeClient) ListCheatingIncidents(ctx context.Context, limit, offset int) (CheatingIncidentPage, error) {
+	page := CheatingIncidentPage{
+		Incidents: make([]CheatingIncident, 0),
+		Limit:     limit,
+		Offset:    offset,
+	}
+
+	err := cc.database.QueryRowContext(ctx, `SELECT COUNT(*) FROM cheating_incidents`).Scan(&page.Total)
+	if err != nil {
+		return page, fmt.Errorf("failed to count cheating incidents: %w", err)
+	}
+
+	rows, err := cc.database.QueryContext(ctx, `
+		SELECT i.id, i.user_email, i.owner_email, i.challenge_id, c.name, i.kind, i.created_at
+		FROM cheating_incidents i
+		JOIN challenges c ON c.id = i.challenge_id
+		ORDER BY i.created_at DESC, i.id DESC
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return page, fmt.Errorf("failed to query cheating incidents: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var incident CheatingIncident
+		if err := rows.Scan(
+			&incident.ID,
+			&incident.UserEmail,
+			&incident.OwnerEmail,
+			&incident.ChallengeID,
+			&incident.Challenge,
+			&incident.Kind,
+			&incident.CreatedAt,
+		); err != nil {
+			return page, fmt.Errorf("failed to scan cheating incident: %w", err)
+		}
+		page.Incidents = append(page.Incidents, incident)
+	}
+
+	if err := rows.Err(); err != nil {
+		return page, fmt.Errorf("rows error: %w", err)
+	}
+
+	return page, nil
+}
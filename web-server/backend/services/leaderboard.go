@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/obelisk/example-ctf/config"
+)
+
+// anonymousDisplayName is shown on public leaderboards for users without an alias
+const anonymousDisplayName = "Anonymous"
+
+// rankedUsersQuery ranks every scoring user according to the README rules:
+// most exam challenges solved, then shortest exam session, then earliest last exam solve,
+// then most points. Exam session time is only set when timed exam sessions are enabled.
+// The user's email breaks any remaining ties so ranks are stable between pages.
+// The %s placeholder is the standings table, either user_standings or scoreboard_snapshot.
+const rankedUsersQuery = `
+	WITH ranked AS (
+		SELECT u.user_email,
+		       COALESCE(ua.alias, '') AS alias,
+		       u.points_achieved,
+		       u.exam_challenges_solved,
+		       u.first_bloods,
+		       u.second_bloods,
+		       u.third_bloods,
+		       u.exam_elapsed,
+		       CASE
+		           WHEN u.exam_challenges_solved > 0 THEN u.last_exam_challenge_solved_timestamp
+		           ELSE NULL
+		       END AS last_exam_challenge_solved_timestamp,
+		       ROW_NUMBER() OVER (
+		           ORDER BY
+		               u.exam_challenges_solved DESC,
+		               CASE
+		                   WHEN u.exam_challenges_solved > 0 THEN u.exam_elapsed
+		                   ELSE NULL
+		               END ASC NULLS LAST,
+		               CASE
+		                   WHEN u.exam_challenges_solved > 0 THEN u.last_exam_challenge_solved_timestamp
+		                   ELSE NULL
+		               END ASC NULLS LAST,
+		               u.points_achieved DESC,
+		               u.last_challenge_solved_timestamp ASC,
+		               u.user_email ASC
+		       ) AS rank
+		FROM %s u
+		LEFT JOIN user_aliases ua ON u.user_email = ua.user_email AND ua.deleted_at IS NULL
+		WHERE u.points_achieved > 0
+	)
+`
+
+// LeaderboardEntry represents a single ranked user
+type LeaderboardEntry struct {
+	Rank                             int        `json:"rank"`
+	UserEmail                        string     `json:"-"`
+	Alias                            string     `json:"-"`
+	DisplayName                      string     `json:"display_name"`
+	Points                           int        `json:"points"`
+	ExamChallengesSolved             int        `json:"exam_challenges_solved"`
+	LastExamChallengeSolvedTimestamp *time.Time `json:"last_exam_challenge_solved_timestamp,omitempty"`
+	FirstBloods                      int        `json:"first_bloods"`
+	SecondBloods                     int        `json:"second_bloods"`
+	ThirdBloods                      int        `json:"third_bloods"`
+	ExamElapsedSeconds               *int64     `json:"exam_elapsed_seconds,omitempty"`
+	IsCurrentUser                    bool       `json:"is_current_user"`
+}
+
+// LeaderboardPage represents a paginated slice of the leaderboard
+type LeaderboardPage struct {
+	Entries []LeaderboardEntry `json:"entries"`
+	Total   int                `json:"total"`
+	Limit   int                `json:"limit"`
+	Offset  int                `json:"offset"`
+	// Frozen is set while the leaderboard shows the standings from the scoreboard freeze
+	Frozen bool `json:"frozen"`
+}
+
+// LeaderboardStats represents statistics for the leaderboard
+type LeaderboardStats struct {
+	TopScorers            []LeaderboardEntry
+	TotalUsers            int
+	TotalSubmissions      int
+	SuccessfulSubmissions int
+	WrongSubmissions      int
+	Frozen                bool
+}
+
+// LeaderboardService computes the ranking shared by the API and the Slack digest
+type LeaderboardService struct {
+	db          *sql.DB
+	config      *config.Config
+	competition *CompetitionService
+}
+
+// NewLeaderboardService creates a new leaderboard service
+func NewLeaderboardService(db *sql.DB, cfg *config.Config, competition *CompetitionService) *LeaderboardService {
+	return &LeaderboardService{
+		db:          db,
+		config:      cfg,
+		competition: competition,
+	}
+}
+
+// rankedQuery returns rankedUsersQuery over the standings players may currently see
+func (ls *LeaderboardService) rankedQuery(ctx context.Context) (string, bool, error) {
+	source, frozen, err := ls.competition.ScoreboardSource(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	return fmt.Sprintf(rankedUsersQuery, source), frozen, nil
+}
+
+// GetPage returns a page of the leaderboard ordered by rank.
+// The current user's entry, if present on the page, is flagged.
+func (ls *LeaderboardService) GetPage(ctx context.Context, currentUserEmail string, limit, offset int) (LeaderboardPage, error) {
+	page := LeaderboardPage{
+		Entries: make([]LeaderboardEntry, 0),
+		Limit:   limit,
+		Offset:  offset,
+	}
+
+	ranked, frozen, err := ls.rankedQuery(ctx)
+	if err != nil {
+		return page, err
+	}
+	page.Frozen = frozen
+
+	err = ls.db.QueryRowContext(ctx, ranked+`SELECT COUNT(*) FROM ranked`).Scan(&page.Total)
+	if err != nil {
+		return page, fmt.Errorf("failed to count ranked users: %w", err)
+	}
+
+	entries, err := ls.queryEntries(ctx, ranked+`
+		SELECT rank, user_email, alias, points_achieved, exam_challenges_solved, last_exam_challenge_solved_timestamp,
+		       first_bloods, second_bloods, third_bloods, EXTRACT(EPOCH FROM exam_elapsed)::BIGINT
+		FROM ranked
+		ORDER BY rank
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return page, err
+	}
+
+	for i := range entries {
+		entries[i].IsCurrentUser = entries[i].UserEmail == currentUserEmail
+	}
+	page.Entries = entries
+
+	return page, nil
+}
+
+// GetUserEntry returns the leaderboard entry for a single user.
+// Returns nil if the user is not ranked yet.
+func (ls *LeaderboardService) GetUserEntry(ctx context.Context, userEmail string) (*LeaderboardEntry, error) {
+	ranked, _, err := ls.rankedQuery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ls.queryEntries(ctx, ranked+`
+		SELECT rank, user_email, alias, points_achieved, exam_challenges_solved, last_exam_challenge_solved_timestamp,
+		       first_bloods, second_bloods, third_bloods, EXTRACT(EPOCH FROM exam_elapsed)::BIGINT
+		FROM ranked
+		WHERE user_email = $1
+	`, userEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	entry := entries[0]
+	entry.IsCurrentUser = true
+	return &entry, nil
+}
+
+// GetStats returns the top scorers and submission statistics used by the Slack digest
+func (ls *LeaderboardService) GetStats(ctx context.Context, topN int) (LeaderboardStats, error) {
+	stats := LeaderboardStats{}
+
+	ranked, frozen, err := ls.rankedQuery(ctx)
+	if err != nil {
+		return stats, err
+	}
+	stats.Frozen = frozen
+
+	topScorers, err := ls.queryEntries(ctx, ranked+`
+		SELECT rank, user_email, alias, points_achieved, exam_challenges_solved, last_exam_challenge_solved_timestamp,
+		       first_bloods, second_bloods, third_bloods, EXTRACT(EPOCH FROM exam_elapsed)::BIGINT
+		FROM ranked
+		ORDER BY rank
+		LIMIT $1
+	`, topN)
+	if err != nil {
+		return stats, err
+	}
+	stats.TopScorers = topScorers
+
+	// Get total unique users
+	err = ls.db.QueryRowContext(ctx, `
+		SELECT COUNT(DISTINCT user_email)
+		FROM users
+	`).Scan(&stats.TotalUsers)
+	if err != nil {
+		return stats, fmt.Errorf("failed to count total users: %w", err)
+	}
+
+	// Get submission statistics
+	err = ls.db.QueryRowContext(ctx, `
+		SELECT
+			COUNT(*) AS total_submissions,
+			COUNT(*) FILTER (WHERE result = 'correct') AS successful_submissions,
+			COUNT(*) FILTER (WHERE result = 'incorrect') AS wrong_submissions
+		FROM submissions
+	`).Scan(&stats.TotalSubmissions, &stats.SuccessfulSubmissions, &stats.WrongSubmissions)
+	if err != nil {
+		return stats, fmt.Errorf("failed to get submission stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// queryEntries runs a ranked query and scans the resulting leaderboard entries
+func (ls *LeaderboardService) queryEntries(ctx context.Context, query string, args ...any) ([]LeaderboardEntry, error) {
+	rows, err := ls.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query leaderboard: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]LeaderboardEntry, 0)
+	for rows.Next() {
+		var entry LeaderboardEntry
+		var lastExamTimestamp sql.NullTime
+		var examElapsed sql.NullInt64
+		if err := rows.Scan(&entry.Rank, &entry.UserEmail, &entry.Alias, &entry.Points, &entry.ExamChallengesSolved, &lastExamTimestamp,
+			&entry.FirstBloods, &entry.SecondBloods, &entry.ThirdBloods, &examElapsed); err != nil {
+			return nil, fmt.Errorf("failed to scan leaderboard entry: %w", err)
+		}
+		if lastExamTimestamp.Valid {
+			entry.LastExamChallengeSolvedTimestamp = &lastExamTimestamp.Time
+		}
+		if examElapsed.Valid {
+			entry.ExamElapsedSeconds = &examElapsed.Int64
+		}
+
+		// Only aliases are ever rendered publicly
+		entry.DisplayName = entry.Alias
+		if entry.DisplayName == "" {
+			entry.DisplayName = anonymousDisplayName
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,362 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LedgerEntryType is the kind of token balance change a ledger entry records
+type LedgerEntryType string
+
+const (
+	// LedgerEarn is a reward for completing a challenge
+	LedgerEarn LedgerEntryType = "earn"
+	// LedgerBurn is an exam submission's cost; it also counts towards tokens_burned
+	LedgerBurn LedgerEntryType = "burn"
+	// LedgerRefund is an admin's refund of burned tokens; it also reduces tokens_burned
+	LedgerRefund LedgerEntryType = "refund"
+	// LedgerAdminGrant is a manual adjustment by an admin, positive or negative
+	LedgerAdminGrant LedgerEntryType = "admin-grant"
+	// LedgerHint is the token cost of unlocking a hint
+	LedgerHint LedgerEntryType = "hint"
+)
+
+// maxLedgerNoteLength bounds the note admins can attach to a grant
+const maxLedgerNoteLength = 512
+
+// LedgerEntry is a single change to a user's token balance
+type LedgerEntry struct {
+	ID        int64           `json:"id"`
+	UserEmail string          `json:"user_email"`
+	Type      LedgerEntryType `json:"type"`
+	// Amount is added to tokens_available; costs are negative
+	Amount      int  `json:"amount"`
+	ChallengeID *int `json:"challenge_id,omitempty"`
+	// Actor is who caused the change: the user, the submitting teammate or an admin
+	Actor     string    `json:"actor"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LedgerPage represents a paginated slice of ledger entries
+type LedgerPage struct {
+	Entries []LedgerEntry `json:"entries"`
+	Total   int           `json:"total"`
+	Limit   int           `json:"limit"`
+	Offset  int           `json:"offset"`
+}
+
+// TokenDiscrepancy is a user whose token counters disagree with the ledger
+type TokenDiscrepancy struct {
+	UserEmail       string `json:"user_email"`
+	TokensAvailable int    `json:"tokens_available"`
+	LedgerAvailable int    `json:"ledger_available"`
+	TokensBurned    int    `json:"tokens_burned"`
+	LedgerBurned    int    `json:"ledger_burned"`
+}
+
+// TokenGrant is an admin's adjustment of a user's tokens
+type TokenGrant struct {
+	UserEmail string `json:"user_email"`
+	Amount    int    `json:"amount"`
+	Note      string `json:"note"`
+}
+
+// TokenRefund is an admin's refund of tokens a user burned, optionally on one exam challenge
+type TokenRefund struct {
+	UserEmail   string `json:"user_email"`
+	ChallengeID *int   `json:"challenge_id,omitempty"`
+	Amount      int    `json:"amount"`
+	Note        string `json:"note"`
+}
+
+// recordLedgerEntry appends an entry to the token ledger in the caller's transaction.
+// It must be written alongside the counter change it records.
+// Zero amounts change nothing and are not recorded.
+func recordLedgerEntry(ctx context.Context, tx *sql.Tx, userEmail string, entryType LedgerEntryType, amount int, challengeID *int, actor, note string) error {
+	if amount == 0 {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO token_ledger (user_email, entry_type, amount, challenge_id, actor, note, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+	`, userEmail, entryType, amount, challengeID, actor, note)
+	if err != nil {
+		return fmt.Errorf("failed to record %s ledger entry: %w", entryType, err)
+	}
+	return nil
+}
+
+// LedgerClient reads the token ledger and checks balances against it
+type LedgerClient struct {
+	db *sql.DB
+}
+
+// NewLedgerClient creates a new ledger client
+func NewLedgerClient(db *sql.DB) *LedgerClient {
+	return &LedgerClient{db: db}
+}
+
+// List returns a page of a user's ledger entries, or everyone's if userEmail is empty, newest first
+func (lc *LedgerClient) List(ctx context.Context, userEmail string, limit, offset int) (LedgerPage, error) {
+	page := LedgerPage{
+		Entries: make([]LedgerEntry, 0),
+		Limit:   limit,
+		Offset:  offset,
+	}
+
+	const where = `
+		WHERE ($1 = '' OR user_email = $1)
+	`
+
+	err := lc.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM token_ledger`+where, userEmail).Scan(&page.Total)
+	if err != nil {
+		return page, fmt.Errorf("failed to count ledger entries: %w", err)
+	}
+
+	rows, err := lc.db.QueryContext(ctx, `
+		SELECT id, user_email, entry_type, amount, challenge_id, actor, note, created_at
+		FROM token_ledger`+where+`
+		ORDER BY id DESC
+		LIMIT $2 OFFSET $3
+	`, userEmail, limit, offset)
+	if err != nil {
+		return page, fmt.Errorf("failed to query ledger entries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry LedgerEntry
+		var challengeID sql.NullInt64
+		if err := rows.Scan(&entry.ID, &entry.UserEmail, &entry.Type, &entry.Amount, &challengeID,
+			&entry.Actor, &entry.Note, &entry.CreatedAt); err != nil {
+			return page, fmt.Errorf("failed to scan ledger entry: %w", err)
+		}
+		if challengeID.Valid {
+			entry.ChallengeID = intPtr(int(challengeID.Int64))
+		}
+		page.Entries = append(page.Entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return page, fmt.Errorf("rows error: %w", err)
+	}
+
+	return page, nil
+}
+
+// Reconcile rebuilds every user's balances from the ledger and returns the users whose
+// tokens_available or tokens_burned disagree with it. Counters and entries are always
+// written together, so a single snapshot compares them consistently.
+func (lc *LedgerClient) Reconcile(ctx context.Context) ([]TokenDiscrepancy, error) {
+	rows, err := lc.db.QueryContext(ctx, `
+		WITH ledger AS (
+			SELECT user_email,
+			       SUM(amount) AS available,
+			       COALESCE(-SUM(amount) FILTER (WHERE entry_type IN ('burn', 'refund')), 0) AS burned
+			FROM token_ledger
+			GROUP BY user_email
+		)
+		SELECT COALESCE(u.user_email, l.user_email),
+		       COALESCE(u.tokens_available, 0), COALESCE(l.available, 0),
+		       COALESCE(u.tokens_burned, 0), COALESCE(l.burned, 0)
+		FROM users u
+		FULL OUTER JOIN ledger l ON l.user_email = u.user_email
+		WHERE COALESCE(u.tokens_available, 0) != COALESCE(l.available, 0)
+		   OR COALESCE(u.tokens_burned, 0) != COALESCE(l.burned, 0)
+		ORDER BY 1
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reconcile token ledger: %w", err)
+	}
+	defer rows.Close()
+
+	discrepancies := make([]TokenDiscrepancy, 0)
+	for rows.Next() {
+		var discrepancy TokenDiscrepancy
+		if err := rows.Scan(&discrepancy.UserEmail, &discrepancy.TokensAvailable, &discrepancy.LedgerAvailable,
+			&discrepancy.TokensBurned, &discrepancy.LedgerBurned); err != nil {
+			return nil, fmt.Errorf("failed to scan token discrepancy: %w", err)
+		}
+		discrepancies = append(discrepancies, discrepancy)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return discrepancies, nil
+}
+
+// GrantTokens adds tokens to a user's balance, or removes them for a negative amount.
+// A grant cannot take the balance below zero.
+// Error messages from this function can be returned to the client
+func (uc *UserClient) GrantTokens(ctx context.Context, adminEmail string, grant TokenGrant) error {
+	grant.UserEmail = strings.TrimSpace(grant.UserEmail)
+	grant.Note = strings.TrimSpace(grant.Note)
+	if grant.UserEmail == "" {
+		return ClientError{Message: "User email cannot be empty"}
+	}
+	if grant.Amount == 0 {
+		return ClientError{Message: "Grant amount cannot be zero"}
+	}
+	if grant.Note == "" {
+		return ClientError{Message: "A note explaining the grant is required"}
+	}
+	if len(grant.Note) > maxLedgerNoteLength {
+		return ClientError{Message: fmt.Sprintf("Note cannot be longer than %d characters", maxLedgerNoteLength)}
+	}
+
+	// Start transaction
+	tx, err := uc.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO users (user_email, tokens_available, tokens_burned, points_achieved, exam_challenges_solved, last_exam_challenge_solved_timestamp, last_challenge_solved_timestamp)
+		VALUES ($1, 0, 0, 0, 0, NOW(), NOW())
+		ON CONFLICT (user_email) DO NOTHING
+	`, grant.UserEmail)
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	// Atomic check and adjust in a single query
+	result, err := tx.ExecContext(ctx, `
+		UPDATE users
+		SET tokens_available = tokens_available + $2
+		WHERE user_email = $1 AND tokens_available + $2 >= 0
+	`, grant.UserEmail, grant.Amount)
+	if err != nil {
+		return fmt.Errorf("failed to grant tokens: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ClientError{Message: "Grant would take the user's token balance below zero"}
+	}
+
+	if err := recordLedgerEntry(ctx, tx, grant.UserEmail, LedgerAdminGrant, grant.Amount, nil, adminEmail, grant.Note); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO user_history_log (user_email, log, date)
+		VALUES ($1, $2, NOW())
+	`, grant.UserEmail, fmt.Sprintf("Admin %s granted %+d tokens: %s", adminEmail, grant.Amount, grant.Note))
+	if err != nil {
+		return fmt.Errorf("failed to log token grant: %w", err)
+	}
+
+	if err := logAdminAction(ctx, tx, adminEmail, fmt.Sprintf("Granted %+d tokens to %s", grant.Amount, grant.UserEmail)); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// Invalidate cache
+	uc.mutex.Lock()
+	delete(uc.cache, grant.UserEmail)
+	uc.mutex.Unlock()
+
+	return nil
+}
+
+// RefundTokens gives a user back tokens burned on exam submissions, for example after a
+// broken challenge. The refund comes off tokens_burned, so it cannot exceed what they burned.
+// Error messages from this function can be returned to the client
+func (uc *UserClient) RefundTokens(ctx context.Context, adminEmail string, refund TokenRefund) error {
+	refund.UserEmail = strings.TrimSpace(refund.UserEmail)
+	refund.Note = strings.TrimSpace(refund.Note)
+	if refund.UserEmail == "" {
+		return ClientError{Message: "User email cannot be empty"}
+	}
+	if refund.Amount <= 0 {
+		return ClientError{Message: "Refund amount must be positive"}
+	}
+	if refund.Note == "" {
+		return ClientError{Message: "A note explaining the refund is required"}
+	}
+	if len(refund.Note) > maxLedgerNoteLength {
+		return ClientError{Message: fmt.Sprintf("Note cannot be longer than %d characters", maxLedgerNoteLength)}
+	}
+
+	// Start transaction
+	tx, err := uc.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if refund.ChallengeID != nil {
+		var category string
+		err := tx.QueryRowContext(ctx, `SELECT category FROM challenges WHERE id = $1`, *refund.ChallengeID).Scan(&category)
+		if err == sql.ErrNoRows {
+			return ClientError{Message: fmt.Sprintf("Challenge %d does not exist", *refund.ChallengeID)}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check refunded challenge: %w", err)
+		}
+		if category != "exam" {
+			return ClientError{Message: "Only exam submissions burn tokens"}
+		}
+	}
+
+	// Atomic check and refund in a single query
+	result, err := tx.ExecContext(ctx, `
+		UPDATE users
+		SET tokens_available = tokens_available + $2,
+		    tokens_burned = tokens_burned - $2
+		WHERE user_email = $1 AND tokens_burned >= $2
+	`, refund.UserEmail, refund.Amount)
+	if err != nil {
+		return fmt.Errorf("failed to refund tokens: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ClientError{Message: "Refund is more than the user has burned"}
+	}
+
+	if err := recordLedgerEntry(ctx, tx, refund.UserEmail, LedgerRefund, refund.Amount, refund.ChallengeID, adminEmail, refund.Note); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO user_history_log (user_email, log, date)
+		VALUES ($1, $2, NOW())
+	`, refund.UserEmail, fmt.Sprintf("Admin %s refunded %d burned tokens: %s", adminEmail, refund.Amount, refund.Note))
+	if err != nil {
+		return fmt.Errorf("failed to log token refund: %w", err)
+	}
+
+	if err := logAdminAction(ctx, tx, adminEmail, fmt.Sprintf("Refunded %d burned tokens to %s", refund.Amount, refund.UserEmail)); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// Invalidate cache
+	uc.mutex.Lock()
+	delete(uc.cache, refund.UserEmail)
+	uc.mutex.Unlock()
+
+	return nil
+}
@@ -8,6 +8,12 @@ import (
 // LoggerContextKey is the context key for storing logger instances
 const LoggerContextKey = "logger"
 
+// RequestIDContextKey is the context key for the ID assigned to each request
+const RequestIDContextKey = "request_id"
+
+// ClientIPContextKey is the context key for the client IP the request came from
+const ClientIPContextKey = "client_ip"
+
 // GetLogger returns the logger from context or creates a new one
 func GetLogger(ctx context.Context) *log.Entry {
 	logger, ok := ctx.Value(LoggerContextKey).(*log.Entry)
@@ -16,3 +22,15 @@ func GetLogger(ctx context.Context) *log.Entry {
 	}
 	return logger
 }
+
+// GetRequestID returns the request ID from context, or an empty string if unset
+func GetRequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(RequestIDContextKey).(string)
+	return requestID
+}
+
+// GetClientIP returns the client IP from context, or an empty string if unset
+func GetClientIP(ctx context.Context) string {
+	clientIP, _ := ctx.Value(ClientIPContextKey).(string)
+	return clientIP
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PrerequisiteGroup requires solving Required of the listed challenges
+type PrerequisiteGroup struct {
+	Required     int   `json:"required"`
+	ChallengeIDs []int `json:"challenge_ids"`
+}
+
+// satisfied reports whether the solved challenges meet the group
+func (pg PrerequisiteGroup) satisfied(solved map[int]bool) bool {
+	count := 0
+	for _, id := range pg.ChallengeIDs {
+		if solved[id] {
+			count++
+		}
+	}
+	return count >= pg.Required
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// validatePrerequisites checks a definition's prerequisite groups, sorting their challenge IDs.
+// References to other challenges are checked when the definition is written.
+// Error messages from this function can be returned to the client
+func validatePrerequisites(def *ChallengeDefinition) error {
+	if len(def.Prerequisites) > 0 && def.Category == "exam" {
+		return ClientError{Message: "Exam challenges are unlocked by exam progress and cannot have prerequisites"}
+	}
+
+	for i := range def.Prerequisites {
+		group := &def.Prerequisites[i]
+		if len(group.ChallengeIDs) == 0 {
+			return ClientError{Message: "Prerequisite groups must list at least one challenge"}
+		}
+		if group.Required < 1 || group.Required > len(group.ChallengeIDs) {
+			return ClientError{Message: fmt.Sprintf("Prerequisite group requires %d of %d challenges", group.Required, len(group.ChallengeIDs))}
+		}
+
+		seen := make(map[int]bool)
+		for _, id := range group.ChallengeIDs {
+			if id == def.ID {
+				return ClientError{Message: "A challenge cannot be its own prerequisite"}
+			}
+			if seen[id] {
+				return ClientError{Message: fmt.Sprintf("Prerequisite group lists challenge %d twice", id)}
+			}
+			seen[id] = true
+		}
+		sort.Ints(group.ChallengeIDs)
+	}
+
+	return nil
+}
+
+// loadPrerequisites returns the prerequisite groups of every challenge, keyed by challenge ID
+func loadPrerequisites(ctx context.Context, db querier) (map[int][]PrerequisiteGroup, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT g.challenge_id, g.id, g.required, p.prerequisite_id
+		FROM challenge_prerequisite_groups g
+		JOIN challenge_prerequisites p ON p.group_id = g.id
+		ORDER BY g.challenge_id, g.id, p.prerequisite_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query prerequisites: %w", err)
+	}
+	defer rows.Close()
+
+	prerequisites := make(map[int][]PrerequisiteGroup)
+	lastGroupID := 0
+	for rows.Next() {
+		var challengeID, groupID, required, prerequisiteID int
+		if err := rows.Scan(&challengeID, &groupID, &required, &prerequisiteID); err != nil {
+			return nil, fmt.Errorf("failed to scan prerequisite: %w", err)
+		}
+
+		groups := prerequisites[challengeID]
+		if groupID != lastGroupID {
+			groups = append(groups, PrerequisiteGroup{Required: required})
+			lastGroupID = groupID
+		}
+		groups[len(groups)-1].ChallengeIDs = append(groups[len(groups)-1].ChallengeIDs, prerequisiteID)
+		prerequisites[challengeID] = groups
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return prerequisites, nil
+}
+
+// PrerequisiteGraph flattens prerequisite groups into edges from each challenge to the challenges it requires
+func PrerequisiteGraph(prerequisites map[int][]PrerequisiteGroup) map[int][]int {
+	graph := make(map[int][]int)
+	for challengeID, groups := range prerequisites {
+		for _, group := range groups {
+			graph[challengeID] = append(graph[challengeID], group.ChallengeIDs...)
+		}
+	}
+	return graph
+}
+
+// FindPrerequisiteCycle returns a cycle in the prerequisite graph, starting and
+// ending at the same challenge, or nil if there is none
+func FindPrerequisiteCycle(graph map[int][]int) []int {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[int]int)
+	var path []int
+
+	var visit func(id int) []int
+	visit = func(id int) []int {
+		state[id] = visiting
+		path = append(path, id)
+		for _, next := range graph[id] {
+			switch state[next] {
+			case visiting:
+				for i, onPath := range path {
+					if onPath == next {
+						return append(append([]int{}, path[i:]...), next)
+					}
+				}
+			case unvisited:
+				if cycle := visit(next); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[id] = visited
+		return nil
+	}
+
+	// Visit in ID order so the reported cycle is stable
+	ids := make([]int, 0, len(graph))
+	for id := range graph {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		if state[id] == unvisited {
+			if cycle := visit(id); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// FormatPrerequisiteCycle renders a cycle as "1 -> 2 -> 1"
+func FormatPrerequisiteCycle(cycle []int) string {
+	parts := make([]string, len(cycle))
+	for i, id := range cycle {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, " -> ")
+}
+
+// writePrerequisites replaces a challenge's prerequisite groups, rejecting
+// references to missing or exam challenges and any change that creates a cycle
+// Error messages from this function can be returned to the client
+func writePrerequisites(ctx context.Context, tx *sql.Tx, def *ChallengeDefinition) error {
+	// Serialize graph changes so two concurrent saves can't create a cycle together
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE challenge_prerequisite_groups IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return fmt.Errorf("failed to lock prerequisites: %w", err)
+	}
+
+	for _, group := range def.Prerequisites {
+		for _, id := range group.ChallengeIDs {
+			var category string
+			err := tx.QueryRowContext(ctx, `SELECT category FROM challenges WHERE id = $1`, id).Scan(&category)
+			if err == sql.ErrNoRows {
+				return ClientError{Message: fmt.Sprintf("Prerequisite challenge %d does not exist", id)}
+			}
+			if err != nil {
+				return fmt.Errorf("failed to check prerequisite challenge: %w", err)
+			}
+			if category == "exam" {
+				return ClientError{Message: fmt.Sprintf("Prerequisite challenge %d is an exam challenge", id)}
+			}
+		}
+	}
+
+	prerequisites, err := loadPrerequisites(ctx, tx)
+	if err != nil {
+		return err
+	}
+	prerequisites[def.ID] = def.Prerequisites
+	if cycle := FindPrerequisiteCycle(PrerequisiteGraph(prerequisites)); cycle != nil {
+		return ClientError{Message: fmt.Sprintf("Prerequisites would create a cycle: %s", FormatPrerequisiteCycle(cycle))}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM challenge_prerequisite_groups WHERE challenge_id = $1`, def.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete prerequisites: %w", err)
+	}
+
+	for _, group := range def.Prerequisites {
+		var groupID int
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO challenge_prerequisite_groups (challenge_id, required)
+			VALUES ($1, $2)
+			RETURNING id
+		`, def.ID, group.Required).Scan(&groupID)
+		if err != nil {
+			return fmt.Errorf("failed to insert prerequisite group: %w", err)
+		}
+
+		for _, id := range group.ChallengeIDs {
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO challenge_prerequisites (group_id, prerequisite_id) VALUES ($1, $2)
+			`, groupID, id)
+			if err != nil {
+				return fmt.Errorf("failed to insert prerequisite: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// solvedChallenges returns the IDs of the challenges a user completed, including
+// those solved by their team in team mode
+func (cc *ChallengeClient) solvedChallenges(ctx context.Context, userEmail string) (map[int]bool, error) {
+	query := `SELECT challenge_id FROM user_challenges_completed WHERE user_email = $1`
+	if cc.config.Teams.Enabled {
+		query += ` UNION ` + teamSolvesQuery
+	}
+
+	rows, err := cc.database.QueryContext(ctx, query, userEmail)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query completed challenges: %w", err)
+	}
+	defer rows.Close()
+
+	solved := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan completed challenge: %w", err)
+		}
+		solved[id] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return solved, nil
+}
+
+// LockedChallenges returns the challenges a user has not unlocked yet, with their prerequisites
+func (cc *ChallengeClient) LockedChallenges(ctx context.Context, userEmail string) (map[int][]PrerequisiteGroup, error) {
+	prerequisites, err := loadPrerequisites(ctx, cc.database)
+	if err != nil {
+		return nil, err
+	}
+
+	locked := make(map[int][]PrerequisiteGroup)
+	if len(prerequisites) == 0 {
+		return locked, nil
+	}
+
+	solved, err := cc.solvedChallenges(ctx, userEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	for challengeID, groups := range prerequisites {
+		// A challenge the user (or their team) already solved stays unlocked even if its prerequisites change
+		if solved[challengeID] {
+			continue
+		}
+		for _, group := range groups {
+			if !group.satisfied(solved) {
+				locked[challengeID] = groups
+				break
+			}
+		}
+	}
+
+	return locked, nil
+}
+
+// ChallengeLocked reports whether a user has yet to unlock a challenge, with its prerequisites
+func (cc *ChallengeClient) ChallengeLocked(ctx context.Context, challengeID int, userEmail string) (bool, []PrerequisiteGroup, error) {
+	locked, err := cc.LockedChallenges(ctx, userEmail)
+	if err != nil {
+		return false, nil, err
+	}
+	groups, isLocked := locked[challengeID]
+	return isLocked, groups, nil
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFindPrerequisiteCycle(t *testing.T) {
+	acyclic := map[string]map[int][]int{
+		"empty":                  {},
+		"single challenge":       {1: nil},
+		"chain":                  {3: {2}, 2: {1}},
+		"diamond":                {4: {2, 3}, 2: {1}, 3: {1}},
+		"shared prerequisite":    {2: {1}, 3: {1}, 4: {1}},
+		"missing prerequisite":   {2: {99}},
+		"two separate subgraphs": {2: {1}, 12: {11}},
+	}
+	for name, graph := range acyclic {
+		t.Run(name, func(t *testing.T) {
+			if cycle := FindPrerequisiteCycle(graph); cycle != nil {
+				t.Errorf("FindPrerequisiteCycle(%v) = %v, want no cycle", graph, cycle)
+			}
+		})
+	}
+
+	cyclic := map[string]struct {
+		graph map[int][]int
+		want  []int
+	}{
+		"self":               {map[int][]int{5: {5}}, []int{5, 5}},
+		"pair":               {map[int][]int{1: {2}, 2: {1}}, []int{1, 2, 1}},
+		"behind a chain":     {map[int][]int{1: {2}, 2: {3}, 3: {4}, 4: {2}}, []int{2, 3, 4, 2}},
+		"lowest id reported": {map[int][]int{9: {8}, 8: {9}, 3: {4}, 4: {3}}, []int{3, 4, 3}},
+		"through a diamond":  {map[int][]int{1: {2, 3}, 2: {4}, 3: {4}, 4: {1}}, []int{1, 2, 4, 1}},
+	}
+	for name, tt := range cyclic {
+		t.Run(name, func(t *testing.T) {
+			if cycle := FindPrerequisiteCycle(tt.graph); !slices.Equal(cycle, tt.want) {
+				t.Errorf("FindPrerequisiteCycle(%v) = %v, want %v", tt.graph, cycle, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrerequisiteGraphCycleOnEdit(t *testing.T) {
+	// 3 needs one of 1 or 2, and 2 needs 1
+	prerequisites := map[int][]PrerequisiteGroup{
+		3: {{Required: 1, ChallengeIDs: []int{1, 2}}},
+		2: {{Required: 1, ChallengeIDs: []int{1}}},
+	}
+	if cycle := FindPrerequisiteCycle(PrerequisiteGraph(prerequisites)); cycle != nil {
+		t.Fatalf("initial graph has cycle %v", cycle)
+	}
+
+	// Saving 1 with a prerequisite on 3 closes the loop, even though 3 only needs one of its group
+	prerequisites[1] = []PrerequisiteGroup{{Required: 1, ChallengeIDs: []int{3}}}
+	cycle := FindPrerequisiteCycle(PrerequisiteGraph(prerequisites))
+	if got, want := FormatPrerequisiteCycle(cycle), "1 -> 3 -> 1"; got != want {
+		t.Errorf("cycle after edit = %q, want %q", got, want)
+	}
+}
+
+func TestPrerequisiteGroupSatisfied(t *testing.T) {
+	group := PrerequisiteGroup{Required: 2, ChallengeIDs: []int{1, 2, 3}}
+
+	if group.satisfied(map[int]bool{1: true}) {
+		t.Error("one of three solved satisfied a group requiring two")
+	}
+	if !group.satisfied(map[int]bool{1: true, 3: true}) {
+		t.Error("two of three solved did not satisfy a group requiring two")
+	}
+	if group.satisfied(map[int]bool{1: true, 4: true, 5: true}) {
+		t.Error("solves outside the group counted towards it")
+	}
+}
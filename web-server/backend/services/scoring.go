@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+
+	"github.com/lib/pq"
+)
+
+// ScoringMode is how a challenge's point value is determined
+type ScoringMode string
+
+const (
+	// ScoringStatic awards point_reward_amount to every solver
+	ScoringStatic ScoringMode = "static"
+	// ScoringLinear loses decay points with every solve
+	ScoringLinear ScoringMode = "linear"
+	// ScoringLogarithmic decays along a curve that reaches the minimum after decay solves
+	ScoringLogarithmic ScoringMode = "logarithmic"
+)
+
+// DynamicValue returns what a dynamic challenge is worth once it has the given
+// number of solves, using the CTFd curves. The first solve is worth the initial value.
+func DynamicValue(mode ScoringMode, initial, minimum, decay, solves int) int {
+	solveCount := float64(max(solves-1, 0))
+
+	var value float64
+	switch mode {
+	case ScoringLinear:
+		value = float64(initial) - float64(decay)*solveCount
+	case ScoringLogarithmic:
+		value = float64(minimum-initial)/float64(decay*decay)*solveCount*solveCount + float64(initial)
+	default:
+		return initial
+	}
+
+	return max(int(math.Ceil(value)), minimum)
+}
+
+// validateScoring checks a definition's scoring mode and its parameters.
+// Dynamic challenges start at their initial value; it is recomputed from the solves when written.
+// Error messages from this function can be returned to the client
+func validateScoring(def *ChallengeDefinition) error {
+	if def.Scoring == "" {
+		def.Scoring = ScoringStatic
+	}
+
+	switch def.Scoring {
+	case ScoringStatic:
+		def.InitialPoints, def.MinimumPoints, def.Decay = 0, 0, 0
+		return nil
+	case ScoringLinear, ScoringLogarithmic:
+	default:
+		return ClientError{Message: fmt.Sprintf("Unknown scoring mode: %s", def.Scoring)}
+	}
+
+	if def.Category == "exam" {
+		return ClientError{Message: "Exam challenges award tokens and cannot use dynamic scoring"}
+	}
+	if def.InitialPoints < 1 {
+		return ClientError{Message: "Dynamic scoring needs a positive initial_points"}
+	}
+	if def.MinimumPoints < 0 || def.MinimumPoints > def.InitialPoints {
+		return ClientError{Message: "minimum_points must be between 0 and initial_points"}
+	}
+	if def.Decay < 1 {
+		return ClientError{Message: "Dynamic scoring needs a positive decay"}
+	}
+
+	def.PointRewardAmount = def.InitialPoints
+	return nil
+}
+
+// rescoreChallenge recomputes a dynamic challenge's value from its solve count and
+// reprices its completions and team completions. The caller must hold the challenge
+// row lock. Returns the users whose totals need refreshing.
+func rescoreChallenge(ctx context.Context, tx *sql.Tx, challengeID int) ([]string, error) {
+	var mode ScoringMode
+	var initial, minimum, decay int
+	err := tx.QueryRowContext(ctx, `
+		SELECT scoring, initial_points, minimum_points, decay FROM challenges WHERE id = $1
+	`, challengeID).Scan(&mode, &initial, &minimum, &decay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query challenge scoring: %w", err)
+	}
+	if mode == ScoringStatic {
+		return nil, nil
+	}
+
+	var solves int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM user_challenges_completed WHERE challenge_id = $1
+	`, challengeID).Scan(&solves)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count solves: %w", err)
+	}
+
+	value := DynamicValue(mode, initial, minimum, decay, solves)
+	_, err = tx.ExecContext(ctx, `UPDATE challenges SET point_reward_amount = $2 WHERE id = $1`, challengeID, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update challenge value: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		UPDATE user_challenges_completed
+		SET points = ROUND(($2 * score)::NUMERIC)
+		WHERE challenge_id = $1 AND points != ROUND(($2 * score)::NUMERIC)
+		RETURNING user_email
+	`, challengeID, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reprice completions: %w", err)
+	}
+	defer rows.Close()
+
+	changed := make([]string, 0)
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, fmt.Errorf("failed to scan repriced completion: %w", err)
+		}
+		changed = append(changed, email)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	// A team is worth what its first solve is worth to the solver
+	_, err = tx.ExecContext(ctx, `
+		UPDATE team_challenges_completed tcc
+		SET points = ucc.points + ucc.bonus_points
+		FROM user_challenges_completed ucc
+		WHERE tcc.challenge_id = $1 AND ucc.challenge_id = tcc.challenge_id AND ucc.user_email = tcc.user_email
+	`, challengeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reprice team completions: %w", err)
+	}
+
+	return changed, nil
+}
+
+// refreshUserPoints rederives points_achieved for the given users from their
+// completions and solve bonuses, the points they spent on hints and their adjustment
+func refreshUserPoints(ctx context.Context, tx *sql.Tx, userEmails []string) error {
+	if len(userEmails) == 0 {
+		return nil
+	}
+
+	// Lock in a fixed order so concurrent refreshes can't deadlock. The totals are summed
+	// by a later statement, which sees anything committed while waiting for the locks.
+	_, err := tx.ExecContext(ctx, `
+		SELECT 1 FROM users WHERE user_email = ANY($1) ORDER BY user_email FOR NO KEY UPDATE
+	`, pq.Array(userEmails))
+	if err != nil {
+		return fmt.Errorf("failed to lock users: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE users u
+		SET points_achieved = u.points_adjustment
+		    + COALESCE((SELECT SUM(ucc.points + ucc.bonus_points) FROM user_challenges_completed ucc WHERE ucc.user_email = u.user_email), 0)
+		    - COALESCE((SELECT SUM(hu.cost) FROM hint_unlocks hu WHERE hu.user_email = u.user_email AND hu.cost_type = 'points'), 0)
+		WHERE u.user_email = ANY($1)
+	`, pq.Array(userEmails))
+	if err != nil {
+		return fmt.Errorf("failed to refresh user points: %w", err)
+	}
+	return nil
+}
+
+// keepChallengePoints moves the points users earned and spent on a challenge into
+// their adjustment, so deleting its completions and hint unlocks leaves totals unchanged
+func keepChallengePoints(ctx context.Context, tx *sql.Tx, challengeID int) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE users u
+		SET points_adjustment = u.points_adjustment
+		    + COALESCE((SELECT SUM(ucc.points + ucc.bonus_points) FROM user_challenges_completed ucc WHERE ucc.user_email = u.user_email AND ucc.challenge_id = $1), 0)
+		    - COALESCE((SELECT SUM(hu.cost) FROM hint_unlocks hu WHERE hu.user_email = u.user_email AND hu.challenge_id = $1 AND hu.cost_type = 'points'), 0)
+		WHERE u.user_email IN (
+			SELECT user_email FROM user_challenges_completed WHERE challenge_id = $1
+			UNION
+			SELECT user_email FROM hint_unlocks WHERE challenge_id = $1
+		)
+	`, challengeID)
+	if err != nil {
+		return fmt.Errorf("failed to keep challenge points: %w", err)
+	}
+	return nil
+}
+
+// keepHintCosts moves the points users spent on a hint into their adjustment,
+// so deleting its unlocks leaves totals unchanged
+func keepHintCosts(ctx context.Context, tx *sql.Tx, hintID int) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE users u
+		SET points_adjustment = u.points_adjustment - hu.cost
+		FROM hint_unlocks hu
+		WHERE hu.hint_id = $1 AND hu.user_email = u.user_email AND hu.cost_type = 'points'
+	`, hintID)
+	if err != nil {
+		return fmt.Errorf("failed to keep hint costs: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package services
+
+import "testing"
+
+func TestDynamicValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    ScoringMode
+		initial int
+		minimum int
+		decay   int
+		solves  int
+		want    int
+	}{
+		{"static ignores solves", ScoringStatic, 50, 0, 0, 10, 50},
+		{"unknown mode is static", ScoringMode("bogus"), 50, 10, 5, 10, 50},
+		{"linear before any solve", ScoringLinear, 100, 10, 15, 0, 100},
+		{"linear first solve is worth the initial value", ScoringLinear, 100, 10, 15, 1, 100},
+		{"linear second solve", ScoringLinear, 100, 10, 15, 2, 85},
+		{"linear reaches the minimum", ScoringLinear, 100, 10, 15, 7, 10},
+		{"linear never drops below the minimum", ScoringLinear, 100, 10, 15, 100, 10},
+		{"logarithmic first solve is worth the initial value", ScoringLogarithmic, 500, 100, 10, 1, 500},
+		{"logarithmic second solve", ScoringLogarithmic, 500, 100, 10, 2, 496},
+		{"logarithmic halfway", ScoringLogarithmic, 500, 100, 10, 6, 400},
+		{"logarithmic reaches the minimum after decay solves", ScoringLogarithmic, 500, 100, 10, 11, 100},
+		{"logarithmic never drops below the minimum", ScoringLogarithmic, 500, 100, 10, 50, 100},
+		{"logarithmic rounds up", ScoringLogarithmic, 100, 0, 7, 2, 98},
+		{"minimum equal to initial never decays", ScoringLinear, 20, 20, 5, 30, 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DynamicValue(tt.mode, tt.initial, tt.minimum, tt.decay, tt.solves)
+			if got != tt.want {
+				t.Errorf("DynamicValue(%s, %d, %d, %d, %d) = %d, want %d",
+					tt.mode, tt.initial, tt.minimum, tt.decay, tt.solves, got, tt.want)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"reflect"
+	"sort"
 	"sync"
 	"time"
 
@@ -16,6 +17,9 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// slackLeaderboardSize is the number of top scorers included in leaderboard updates
+const slackLeaderboardSize = 16
+
 // SlackMessage represents a message to be sent to Slack
 type SlackMessage struct {
 	Text      string `json:"text"`
@@ -30,13 +34,14 @@ type SlackService struct {
 	client            *http.Client
 	db                *sql.DB
 	config            *config.Config
+	leaderboard       *LeaderboardService
 	cachedStats       *LeaderboardStats
 	cacheTimestamp    time.Time
 	cacheMutex        sync.RWMutex
 }
 
 // NewSlackService creates a new Slack service
-func NewSlackService(db *sql.DB, cfg *config.Config) *SlackService {
+func NewSlackService(db *sql.DB, cfg *config.Config, leaderboard *LeaderboardService) *SlackService {
 	privateWebhookURL := os.Getenv("SLACK_PRIVATE_WEBHOOK")
 	publicWebhookURL := os.Getenv("SLACK_PUBLIC_WEBHOOK")
 
@@ -52,8 +57,9 @@ func NewSlackService(db *sql.DB, cfg *config.Config) *SlackService {
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
-		db:     db,
-		config: cfg,
+		db:          db,
+		config:      cfg,
+		leaderboard: leaderboard,
 	}
 
 	// Start the message dispatcher
@@ -120,13 +126,22 @@ func (s *SlackService) SendMessageAsync(text string, isPrivate bool) {
 	}
 }
 
-// SendChallengeCompletion sends a notification when a user completes a challenge
-func (s *SlackService) SendChallengeCompletion(user *User, challengeName string) {
+// SendChallengeCompletion sends a notification when a user completes a challenge.
+// The first three solves are announced as first, second and third blood.
+func (s *SlackService) SendChallengeCompletion(user *User, challengeName string, solvePosition int) {
 	ctx := context.Background()
 	alias := s.getUserAlias(ctx, user.Email)
 
 	var privateText, publicText string
-	if alias != "" {
+	if blood := bloodName(solvePosition); blood != "" {
+		if alias != "" {
+			privateText = fmt.Sprintf("🩸 %s on *%s* by *%s* (%s)", blood, challengeName, user.Email, alias)
+			publicText = fmt.Sprintf("🩸 %s on *%s* by *%s*", blood, challengeName, alias)
+		} else {
+			privateText = fmt.Sprintf("🩸 %s on *%s* by *%s*", blood, challengeName, user.Email)
+			publicText = fmt.Sprintf("🩸 %s on *%s* by *%s*", blood, challengeName, user.Email)
+		}
+	} else if alias != "" {
 		privateText = fmt.Sprintf("🎉 *%s* (%s) solved challenge *%s*", user.Email, alias, challengeName)
 		publicText = fmt.Sprintf("🎉 *%s* solved challenge *%s*", alias, challengeName)
 	} else {
@@ -184,21 +199,39 @@ func (s *SlackService) SendExamChallengeCompletion(user *User, challengeName str
 	s.SendMessageAsync(publicText, false)
 }
 
-// LeaderboardStats represents statistics for the leaderboard
-type LeaderboardStats struct {
-	TopScorers            []TopScorer
-	TotalUsers            int
-	TotalSubmissions      int
-	SuccessfulSubmissions int
-	WrongSubmissions      int
+// SendCheatingIncident alerts the private channel when a user submits another user's answer
+func (s *SlackService) SendCheatingIncident(incident CheatingIncident) {
+	if s == nil {
+		return
+	}
+
+	text := fmt.Sprintf("🚨 *%s* submitted the %s answer belonging to *%s* for challenge *%s*",
+		incident.UserEmail, incident.Kind, incident.OwnerEmail, incident.Challenge)
+
+	s.SendMessageAsync(text, true)
 }
 
-// TopScorer represents a user's score
-type TopScorer struct {
-	UserEmail            string `json:"user_email"`
-	Alias                string `json:"alias"`
-	Points               int    `json:"points"`
-	ExamChallengesSolved int    `json:"exam_challenges_solved"`
+// SendHintUnlock tells the private channel when a user pays for a hint
+func (s *SlackService) SendHintUnlock(user *User, hint *Hint) {
+	if s == nil {
+		return
+	}
+
+	text := fmt.Sprintf("💡 *%s* unlocked hint %d for challenge *%s* for %d %s",
+		user.Email, hint.ID, hint.Challenge, hint.Cost, hint.CostType)
+
+	s.SendMessageAsync(text, true)
+}
+
+// SendChallengeRelease announces a challenge that has just been released
+func (s *SlackService) SendChallengeRelease(release ChallengeRelease) {
+	if s == nil {
+		return
+	}
+
+	text := fmt.Sprintf("🚀 New challenge released: *%s* (%s, %d points)", release.Name, release.Category, release.PointRewardAmount)
+	s.SendMessageAsync(text, true)
+	s.SendMessageAsync(text, false)
 }
 
 // getUserAlias gets a user's alias from the database
@@ -220,7 +253,7 @@ func (s *SlackService) sendLeaderboardUpdate(ctx context.Context) {
 		return
 	}
 
-	stats, err := s.getLeaderboardStats(ctx)
+	stats, err := s.leaderboard.GetStats(ctx, slackLeaderboardSize)
 	if err != nil {
 		logrus.WithError(err).Error("failed to get leaderboard stats")
 		return
@@ -253,6 +286,9 @@ func (s *SlackService) SendPrivateLeaderboardUpdate(stats LeaderboardStats) {
 	// Build the leaderboard text for private channel
 	var text string
 	text += "🏆 *Leaderboard Update*\n\n"
+	if stats.Frozen {
+		text += "_The scoreboard is frozen; standings are from the freeze._\n\n"
+	}
 
 	// Add top scorers with email and alias
 	text += "*Top 16 Scorers:*\n"
@@ -282,6 +318,9 @@ func (s *SlackService) SendPublicLeaderboardUpdate(stats LeaderboardStats) {
 	// Build the leaderboard text for public channel (only users with aliases)
 	var text string
 	text += "🏆 *Leaderboard Update*\n\n"
+	if stats.Frozen {
+		text += "_The scoreboard is frozen; standings are from the freeze._\n\n"
+	}
 
 	// Add top scorers with aliases, fallback to email
 	text += "*Top 16 Scorers:*\n"
@@ -308,7 +347,8 @@ func (s *SlackService) statsEqual(a, b LeaderboardStats) bool {
 	if a.TotalUsers != b.TotalUsers ||
 		a.TotalSubmissions != b.TotalSubmissions ||
 		a.SuccessfulSubmissions != b.SuccessfulSubmissions ||
-		a.WrongSubmissions != b.WrongSubmissions {
+		a.WrongSubmissions != b.WrongSubmissions ||
+		a.Frozen != b.Frozen {
 		return false
 	}
 
@@ -316,71 +356,85 @@ func (s *SlackService) statsEqual(a, b LeaderboardStats) bool {
 	return reflect.DeepEqual(a.TopScorers, b.TopScorers)
 }
 
-// getLeaderboardStats queries the database for leaderboard statistics
-func (s *SlackService) getLeaderboardStats(ctx context.Context) (LeaderboardStats, error) {
-	stats := LeaderboardStats{}
-
-	// Get top 16 scorers
-	rows, err := s.db.QueryContext(ctx, `
-		SELECT u.user_email, u.points_achieved, u.exam_challenges_solved, COALESCE(ua.alias, '') as alias
-		FROM users u
-		LEFT JOIN user_aliases ua ON u.user_email = ua.user_email AND ua.deleted_at IS NULL
-		WHERE u.points_achieved > 0 
-		ORDER BY 
-			u.exam_challenges_solved DESC,
-			CASE 
-				WHEN u.exam_challenges_solved > 0 THEN u.last_exam_challenge_solved_timestamp 
-				ELSE NULL 
-			END ASC NULLS LAST,
-			u.points_achieved DESC,
-			u.last_challenge_solved_timestamp ASC 
-		LIMIT 16
+// StartLeaderboardUpdates starts a goroutine that periodically sends leaderboard updates
+// while the competition runs. After the competition ends it sends a final update and stops.
+func (s *SlackService) StartLeaderboardUpdates(ctx context.Context) {
+	if s == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.config.Slack.LeaderboardInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				now := time.Now()
+				if !s.config.Competition.HasStarted(now) {
+					continue
+				}
+				s.sendLeaderboardUpdate(ctx)
+				if s.config.Competition.HasEnded(now) {
+					logrus.Info("competition ended, stopping slack leaderboard updates")
+					return
+				}
+			}
+		}
+	}()
+}
+
+// ChallengeRelease is a challenge whose release time has passed
+type ChallengeRelease struct {
+	ID                int
+	Name              string
+	Category          string
+	PointRewardAmount int
+}
+
+// claimChallengeReleases marks every visible regular challenge whose release time has
+// passed as announced and returns them. Concurrent claims wait on the row locks and
+// then skip rows already marked, so each release is claimed by exactly one replica.
+func claimChallengeReleases(ctx context.Context, db *sql.DB) ([]ChallengeRelease, error) {
+	rows, err := db.QueryContext(ctx, `
+		UPDATE challenges
+		SET release_announced_at = NOW()
+		WHERE release_announced_at IS NULL AND release_at <= NOW()
+		  AND category != 'exam' AND NOT hidden
+		RETURNING id, name, category, point_reward_amount
 	`)
 	if err != nil {
-		return stats, fmt.Errorf("failed to query top scorers: %w", err)
+		return nil, fmt.Errorf("failed to claim challenge releases: %w", err)
 	}
 	defer rows.Close()
 
+	releases := make([]ChallengeRelease, 0)
 	for rows.Next() {
-		var scorer TopScorer
-		if err := rows.Scan(&scorer.UserEmail, &scorer.Points, &scorer.ExamChallengesSolved, &scorer.Alias); err != nil {
-			return stats, fmt.Errorf("failed to scan scorer: %w", err)
+		var release ChallengeRelease
+		if err := rows.Scan(&release.ID, &release.Name, &release.Category, &release.PointRewardAmount); err != nil {
+			return nil, fmt.Errorf("failed to scan challenge release: %w", err)
 		}
-		stats.TopScorers = append(stats.TopScorers, scorer)
-	}
-
-	// Get total unique users
-	err = s.db.QueryRowContext(ctx, `
-		SELECT COUNT(DISTINCT user_email) 
-		FROM users 
-	`).Scan(&stats.TotalUsers)
-	if err != nil {
-		return stats, fmt.Errorf("failed to count total users: %w", err)
+		releases = append(releases, release)
 	}
 
-	// Get submission statistics
-	err = s.db.QueryRowContext(ctx, `
-		SELECT 
-			COUNT(CASE WHEN log LIKE 'Completed challenge%' THEN 1 END) as successful_submissions,
-			COUNT(CASE WHEN log LIKE 'Wrong flag attempt%' THEN 1 END) as wrong_submissions
-		FROM user_history_log
-	`).Scan(&stats.SuccessfulSubmissions, &stats.WrongSubmissions)
-	if err != nil {
-		return stats, fmt.Errorf("failed to get submission stats: %w", err)
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
 	}
-	stats.TotalSubmissions = stats.SuccessfulSubmissions + stats.WrongSubmissions
 
-	return stats, nil
+	sort.Slice(releases, func(i, j int) bool { return releases[i].ID < releases[j].ID })
+	return releases, nil
 }
 
-// StartLeaderboardUpdates starts a goroutine that periodically sends leaderboard updates
-func (s *SlackService) StartLeaderboardUpdates(ctx context.Context) {
+// StartReleaseAnnouncements starts a goroutine that announces challenges as their release times pass
+func (s *SlackService) StartReleaseAnnouncements(ctx context.Context) {
 	if s == nil {
 		return
 	}
 
 	go func() {
-		ticker := time.NewTicker(s.config.Slack.LeaderboardInterval)
+		ticker := time.NewTicker(s.config.Slack.ReleaseCheckInterval)
 		defer ticker.Stop()
 
 		for {
@@ -388,7 +442,15 @@ func (s *SlackService) StartLeaderboardUpdates(ctx context.Context) {
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				s.sendLeaderboardUpdate(ctx)
+				releases, err := claimChallengeReleases(ctx, s.db)
+				if err != nil {
+					logrus.WithError(err).Error("failed to check challenge releases")
+					continue
+				}
+				for _, release := range releases {
+					logrus.WithField("challenge_id", release.ID).Info("announcing challenge release")
+					s.SendChallengeRelease(release)
+				}
 			}
 		}
 	}()
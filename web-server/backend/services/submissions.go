@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// SubmissionResult is the outcome of a flag submission
+type SubmissionResult string
+
+const (
+	SubmissionCorrect   SubmissionResult = "correct"
+	SubmissionIncorrect SubmissionResult = "incorrect"
+)
+
+// Submission is a single flag submission. The submitted value is only stored as a
+// SHA-256 hash so that correct flags never end up in the database in plain text.
+type Submission struct {
+	ID            int64            `json:"id"`
+	UserEmail     string           `json:"user_email"`
+	ChallengeID   int              `json:"challenge_id"`
+	SubmittedHash string           `json:"submitted_hash"`
+	Result        SubmissionResult `json:"result"`
+	TokensBurned  int              `json:"tokens_burned"`
+	IPAddress     string           `json:"ip_address"`
+	RequestID     string           `json:"request_id"`
+	CreatedAt     time.Time        `json:"created_at"`
+}
+
+// SubmissionPage represents a paginated slice of submissions
+type SubmissionPage struct {
+	Submissions []Submission `json:"submissions"`
+	Total       int          `json:"total"`
+	Limit       int          `json:"limit"`
+	Offset      int          `json:"offset"`
+}
+
+// SubmissionFilter narrows a submission listing. Zero values match everything.
+type SubmissionFilter struct {
+	UserEmail   string
+	ChallengeID int
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// hashSubmission returns the hex SHA-256 of a submitted value
+func hashSubmission(submitted string) string {
+	sum := sha256.Sum256([]byte(submitted))
+	return hex.EncodeToString(sum[:])
+}
+
+// recordSubmission writes a submission row, taking the request ID and client IP from context
+func recordSubmission(ctx context.Context, db execer, userEmail string, challengeID int, submitted string, result SubmissionResult, tokensBurned int) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO submissions (user_email, challenge_id, submitted_hash, result, tokens_burned, ip_address, request_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+	`, userEmail, challengeID, hashSubmission(submitted), result, tokensBurned, GetClientIP(ctx), GetRequestID(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to record submission: %w", err)
+	}
+	return nil
+}
+
+// SubmissionClient records and queries flag submissions
+type SubmissionClient struct {
+	db *sql.DB
+}
+
+// NewSubmissionClient creates a new submission client
+func NewSubmissionClient(db *sql.DB) *SubmissionClient {
+	return &SubmissionClient{db: db}
+}
+
+// RecordIncorrect records a wrong flag submission. Correct submissions are recorded
+// by the UserClient as part of completing the challenge.
+func (sc *SubmissionClient) RecordIncorrect(ctx context.Context, userEmail string, challengeID int, submitted string, tokensBurned int) error {
+	return recordSubmission(ctx, sc.db, userEmail, challengeID, submitted, SubmissionIncorrect, tokensBurned)
+}
+
+// List returns a page of submissions matching the filter, newest first
+func (sc *SubmissionClient) List(ctx context.Context, filter SubmissionFilter, limit, offset int) (SubmissionPage, error) {
+	page := SubmissionPage{
+		Submissions: make([]Submission, 0),
+		Limit:       limit,
+		Offset:      offset,
+	}
+
+	const where = `
+		WHERE ($1 = '' OR user_email = $1)
+		AND ($2 = 0 OR challenge_id = $2)
+	`
+
+	err := sc.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM submissions`+where, filter.UserEmail, filter.ChallengeID).Scan(&page.Total)
+	if err != nil {
+		return page, fmt.Errorf("failed to count submissions: %w", err)
+	}
+
+	rows, err := sc.db.QueryContext(ctx, `
+		SELECT id, user_email, challenge_id, submitted_hash, result, tokens_burned,
+		       COALESCE(ip_address, ''), COALESCE(request_id, ''), created_at
+		FROM submissions`+where+`
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	`, filter.UserEmail, filter.ChallengeID, limit, offset)
+	if err != nil {
+		return page, fmt.Errorf("failed to query submissions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var submission Submission
+		if err := rows.Scan(
+			&submission.ID,
+			&submission.UserEmail,
+			&submission.ChallengeID,
+			&submission.SubmittedHash,
+			&submission.Result,
+			&submission.TokensBurned,
+			&submission.IPAddress,
+			&submission.RequestID,
+			&submission.CreatedAt,
+		); err != nil {
+			return page, fmt.Errorf("failed to scan submission: %w", err)
+		}
+		page.Submissions = append(page.Submissions, submission)
+	}
+
+	if err := rows.Err(); err != nil {
+		return page, fmt.Errorf("rows error: %w", err)
+	}
+
+	return page, nil
+}
@@ -0,0 +1,508 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/TwiN/go-away"
+
+	"github.com/obelisk/example-ctf/config"
+)
+
+// validTeamNameRegex allows letters, numbers, spaces and [_-.]
+var validTeamNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.\- ]+$`)
+
+// teamSolvesQuery selects the challenges solved by the team of user $1
+const teamSolvesQuery = `
+	SELECT tcc.challenge_id
+	FROM team_challenges_completed tcc
+	JOIN team_members tm ON tm.team_id = tcc.team_id
+	WHERE tm.user_email = $1
+`
+
+// TeamMember represents a member of a team as seen by their teammates
+type TeamMember struct {
+	UserEmail string    `json:"user_email"`
+	Alias     string    `json:"alias"`
+	JoinedAt  time.Time `json:"joined_at"`
+}
+
+// Team represents a team with its members and shared progress
+type Team struct {
+	ID               int          `json:"id"`
+	Name             string       `json:"name"`
+	InviteCode       string       `json:"invite_code"`
+	MaxMembers       int          `json:"max_members"`
+	Members          []TeamMember `json:"members"`
+	Points           int          `json:"points"`
+	ChallengesSolved int          `json:"challenges_solved"`
+}
+
+// TeamLeaderboardEntry represents a single ranked team
+type TeamLeaderboardEntry struct {
+	Rank             int        `json:"rank"`
+	Name             string     `json:"name"`
+	Members          int        `json:"members"`
+	Points           int        `json:"points"`
+	ChallengesSolved int        `json:"challenges_solved"`
+	LastSolvedAt     *time.Time `json:"last_solved_at,omitempty"`
+	IsCurrentTeam    bool       `json:"is_current_team"`
+}
+
+// TeamLeaderboardPage represents a paginated slice of the team leaderboard
+type TeamLeaderboardPage struct {
+	Entries []TeamLeaderboardEntry `json:"entries"`
+	Total   int                    `json:"total"`
+	Limit   int                    `json:"limit"`
+	Offset  int                    `json:"offset"`
+	// Frozen is set while the leaderboard only counts solves made before the scoreboard freeze
+	Frozen bool `json:"frozen"`
+}
+
+// TeamClient handles team membership and the team leaderboard
+type TeamClient struct {
+	db          *sql.DB
+	config      *config.Config
+	competition *CompetitionService
+}
+
+// NewTeamClient creates a new team client
+func NewTeamClient(db *sql.DB, cfg *config.Config, competition *CompetitionService) *TeamClient {
+	return &TeamClient{
+		db:          db,
+		config:      cfg,
+		competition: competition,
+	}
+}
+
+// checkEnabled returns a ClientError when teams are turned off
+func (tc *TeamClient) checkEnabled() error {
+	if !tc.config.Teams.Enabled {
+		return ClientError{Message: "Teams are not enabled"}
+	}
+	return nil
+}
+
+// newInviteCode returns a random code for joining a team
+func newInviteCode() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate invite code: %w", err)
+	}
+	return strings.ToUpper(hex.EncodeToString(b)), nil
+}
+
+// GetForUser returns the team a user belongs to
+// Returns sql.ErrNoRows if the user is not in a team
+func (tc *TeamClient) GetForUser(ctx context.Context, userEmail string) (*Team, error) {
+	if err := tc.checkEnabled(); err != nil {
+		return nil, err
+	}
+
+	team := Team{MaxMembers: tc.config.Teams.MaxMembers}
+	err := tc.db.QueryRowContext(ctx, `
+		SELECT t.id, t.name, t.invite_code,
+		       COALESCE((SELECT SUM(tcc.points) FROM team_challenges_completed tcc WHERE tcc.team_id = t.id), 0),
+		       (SELECT COUNT(*) FROM team_challenges_completed tcc WHERE tcc.team_id = t.id)
+		FROM teams t
+		JOIN team_members tm ON tm.team_id = t.id
+		WHERE tm.user_email = $1
+	`, userEmail).Scan(&team.ID, &team.Name, &team.InviteCode, &team.Points, &team.ChallengesSolved)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tc.db.QueryContext(ctx, `
+		SELECT tm.user_email, COALESCE(ua.alias, ''), tm.joined_at
+		FROM team_members tm
+		LEFT JOIN user_aliases ua ON tm.user_email = ua.user_email AND ua.deleted_at IS NULL
+		WHERE tm.team_id = $1
+		ORDER BY tm.joined_at, tm.user_email
+	`, team.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query team members: %w", err)
+	}
+	defer rows.Close()
+
+	team.Members = make([]TeamMember, 0)
+	for rows.Next() {
+		var member TeamMember
+		if err := rows.Scan(&member.UserEmail, &member.Alias, &member.JoinedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan team member: %w", err)
+		}
+		team.Members = append(team.Members, member)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return &team, nil
+}
+
+// Create creates a team with the user as its first member
+// Error messages from this function can be returned to the client
+func (tc *TeamClient) Create(ctx context.Context, userEmail, name string) (*Team, error) {
+	if err := tc.checkEnabled(); err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if len(name) < 3 || len(name) > 32 {
+		return nil, ClientError{Message: "Team name must be between 3 and 32 characters"}
+	}
+	if !validTeamNameRegex.MatchString(name) {
+		return nil, ClientError{Message: "Team name can only contain letters, numbers, spaces, underscores, hyphens, and periods"}
+	}
+	if goaway.IsProfane(name) {
+		return nil, ClientError{Message: fmt.Sprintf("Team name not allowed: %s", name)}
+	}
+
+	inviteCode, err := newInviteCode()
+	if err != nil {
+		return nil, err
+	}
+
+	// Start transaction for atomic operation
+	tx, err := tc.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var teamID int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO teams (name, invite_code, created_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT DO NOTHING
+		RETURNING id
+	`, name, inviteCode).Scan(&teamID)
+	if err == sql.ErrNoRows {
+		return nil, ClientError{Message: "Team name already taken"}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create team: %w", err)
+	}
+
+	if err := addTeamMember(ctx, tx, teamID, userEmail); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO user_history_log (user_email, log, date)
+		VALUES ($1, $2, NOW())
+	`, userEmail, fmt.Sprintf("Created team '%s'", name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to log team creation: %w", err)
+	}
+
+	// Commit transaction
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return tc.GetForUser(ctx, userEmail)
+}
+
+// Join adds the user to the team with the given invite code
+// Error messages from this function can be returned to the client
+func (tc *TeamClient) Join(ctx context.Context, userEmail, inviteCode string) (*Team, error) {
+	if err := tc.checkEnabled(); err != nil {
+		return nil, err
+	}
+
+	// Start transaction for atomic operation
+	tx, err := tc.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the team so concurrent joins can't exceed the member cap
+	var teamID int
+	var name string
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, name FROM teams WHERE invite_code = $1 FOR UPDATE
+	`, strings.ToUpper(strings.TrimSpace(inviteCode))).Scan(&teamID, &name)
+	if err == sql.ErrNoRows {
+		return nil, ClientError{Message: "Invalid invite code"}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find team: %w", err)
+	}
+
+	var members int
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM team_members WHERE team_id = $1`, teamID).Scan(&members)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count team members: %w", err)
+	}
+	if members >= tc.config.Teams.MaxMembers {
+		return nil, ClientError{Message: fmt.Sprintf("Team is full (%d members)", tc.config.Teams.MaxMembers)}
+	}
+
+	if err := addTeamMember(ctx, tx, teamID, userEmail); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO user_history_log (user_email, log, date)
+		VALUES ($1, $2, NOW())
+	`, userEmail, fmt.Sprintf("Joined team '%s'", name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to log team join: %w", err)
+	}
+
+	// Commit transaction
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return tc.GetForUser(ctx, userEmail)
+}
+
+// addTeamMember adds a user who is not in a team yet
+// Error messages from this function can be returned to the client
+func addTeamMember(ctx context.Context, tx *sql.Tx, teamID int, userEmail string) error {
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO team_members (user_email, team_id, joined_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (user_email) DO NOTHING
+	`, userEmail, teamID)
+	if err != nil {
+		return fmt.Errorf("failed to add team member: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ClientError{Message: "You are already in a team"}
+	}
+	return nil
+}
+
+// Leave removes the user from their team. The team keeps the solves it already
+// recorded, and is deleted when its last member leaves.
+// Error messages from this function can be returned to the client
+func (tc *TeamClient) Leave(ctx context.Context, userEmail string) error {
+	if err := tc.checkEnabled(); err != nil {
+		return err
+	}
+
+	// Start transaction for atomic operation
+	tx, err := tc.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var teamID int
+	err = tx.QueryRowContext(ctx, `
+		DELETE FROM team_members WHERE user_email = $1 RETURNING team_id
+	`, userEmail).Scan(&teamID)
+	if err == sql.ErrNoRows {
+		return ClientError{Message: "You are not in a team"}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to leave team: %w", err)
+	}
+
+	// Lock the team so a concurrent join can't land in a team being deleted
+	var name string
+	err = tx.QueryRowContext(ctx, `SELECT name FROM teams WHERE id = $1 FOR UPDATE`, teamID).Scan(&name)
+	if err != nil {
+		return fmt.Errorf("failed to find team: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM teams t
+		WHERE t.id = $1 AND NOT EXISTS (SELECT 1 FROM team_members tm WHERE tm.team_id = t.id)
+	`, teamID)
+	if err != nil {
+		return fmt.Errorf("failed to delete empty team: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO user_history_log (user_email, log, date)
+		VALUES ($1, $2, NOW())
+	`, userEmail, fmt.Sprintf("Left team '%s'", name))
+	if err != nil {
+		return fmt.Errorf("failed to log team leave: %w", err)
+	}
+
+	// Commit transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// CheckTeamCompletedChallenge checks if the user's team has completed a challenge
+// Returns (completed, completed_at, err); always false when teams are disabled
+func (tc *TeamClient) CheckTeamCompletedChallenge(ctx context.Context, userEmail string, challengeID int) (bool, time.Time, error) {
+	if !tc.config.Teams.Enabled {
+		return false, time.Unix(0, 0), nil
+	}
+
+	var completedAt time.Time
+	err := tc.db.QueryRowContext(ctx, `
+		SELECT tcc.completed_at
+		FROM team_challenges_completed tcc
+		JOIN team_members tm ON tm.team_id = tcc.team_id
+		WHERE tm.user_email = $1 AND tcc.challenge_id = $2
+	`, userEmail, challengeID).Scan(&completedAt)
+	if err == sql.ErrNoRows {
+		return false, time.Unix(0, 0), nil
+	}
+	if err != nil {
+		return false, time.Unix(0, 0), fmt.Errorf("failed to check team completion: %w", err)
+	}
+
+	return true, completedAt, nil
+}
+
+// TeamCompletedChallenges returns the IDs of the challenges the user's team completed
+func (tc *TeamClient) TeamCompletedChallenges(ctx context.Context, userEmail string) (map[int]bool, error) {
+	completed := make(map[int]bool)
+	if !tc.config.Teams.Enabled {
+		return completed, nil
+	}
+
+	rows, err := tc.db.QueryContext(ctx, teamSolvesQuery, userEmail)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query team completions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan team completion: %w", err)
+		}
+		completed[id] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return completed, nil
+}
+
+// GetLeaderboard returns a page of the team leaderboard: most points, then earliest last solve.
+// While the scoreboard is frozen teams are ranked by their standings at the freeze.
+func (tc *TeamClient) GetLeaderboard(ctx context.Context, currentUserEmail string, limit, offset int) (TeamLeaderboardPage, error) {
+	page := TeamLeaderboardPage{
+		Entries: make([]TeamLeaderboardEntry, 0),
+		Limit:   limit,
+		Offset:  offset,
+	}
+	if err := tc.checkEnabled(); err != nil {
+		return page, err
+	}
+
+	source, frozen, err := tc.competition.ScoreboardSource(ctx)
+	if err != nil {
+		return page, err
+	}
+	page.Frozen = frozen
+
+	// Team standings are snapshotted together with the user standings
+	standings := "team_standings"
+	if source == "scoreboard_snapshot" {
+		standings = "team_scoreboard_snapshot"
+	}
+
+	rankedTeamsQuery := fmt.Sprintf(`
+		WITH ranked AS (
+			SELECT t.id, t.name, s.points, s.solves, s.last_solved_at,
+			       (SELECT COUNT(*) FROM team_members tm WHERE tm.team_id = t.id) AS members,
+			       ROW_NUMBER() OVER (ORDER BY s.points DESC, s.last_solved_at ASC, t.name ASC) AS rank
+			FROM %s s
+			JOIN teams t ON t.id = s.team_id
+		)
+	`, standings)
+
+	err = tc.db.QueryRowContext(ctx, rankedTeamsQuery+`SELECT COUNT(*) FROM ranked`).Scan(&page.Total)
+	if err != nil {
+		return page, fmt.Errorf("failed to count ranked teams: %w", err)
+	}
+
+	var currentTeamID int
+	err = tc.db.QueryRowContext(ctx, `SELECT team_id FROM team_members WHERE user_email = $1`, currentUserEmail).Scan(&currentTeamID)
+	if err != nil && err != sql.ErrNoRows {
+		return page, fmt.Errorf("failed to find current team: %w", err)
+	}
+
+	rows, err := tc.db.QueryContext(ctx, rankedTeamsQuery+`
+		SELECT id, rank, name, members, points, solves, last_solved_at
+		FROM ranked
+		ORDER BY rank
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return page, fmt.Errorf("failed to query team leaderboard: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry TeamLeaderboardEntry
+		var teamID int
+		var lastSolvedAt sql.NullTime
+		if err := rows.Scan(&teamID, &entry.Rank, &entry.Name, &entry.Members, &entry.Points, &entry.ChallengesSolved, &lastSolvedAt); err != nil {
+			return page, fmt.Errorf("failed to scan team leaderboard entry: %w", err)
+		}
+		if lastSolvedAt.Valid {
+			entry.LastSolvedAt = &lastSolvedAt.Time
+		}
+		entry.IsCurrentTeam = teamID == currentTeamID
+		page.Entries = append(page.Entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return page, fmt.Errorf("rows error: %w", err)
+	}
+
+	return page, nil
+}
+
+// recordTeamCompletion credits a solve to the user's team unless a teammate solved the challenge first
+func recordTeamCompletion(ctx context.Context, tx *sql.Tx, teams config.TeamsConfig, userEmail string, challengeID, points int) error {
+	if !teams.Enabled {
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO team_challenges_completed (team_id, challenge_id, user_email, points, completed_at)
+		SELECT team_id, $2, $1, $3, NOW()
+		FROM team_members
+		WHERE user_email = $1
+		ON CONFLICT (team_id, challenge_id) DO NOTHING
+	`, userEmail, challengeID, points)
+	if err != nil {
+		return fmt.Errorf("failed to record team completion: %w", err)
+	}
+	return nil
+}
+
+// tokenPoolQuery selects the users whose tokens pay for user $1's exam submissions.
+// In team mode tokens are pooled across the team; otherwise it is the user alone.
+func tokenPoolQuery(teams config.TeamsConfig) string {
+	if !teams.Enabled {
+		return `SELECT $1::text`
+	}
+	return `
+		SELECT $1::text
+		UNION
+		SELECT tm.user_email FROM team_members tm
+		WHERE tm.team_id = (SELECT team_id FROM team_members WHERE user_email = $1)
+	`
+}
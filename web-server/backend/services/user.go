@@ -13,6 +13,7 @@ import (
 	"github.com/obelisk/example-ctf/config"
 
 	"github.com/TwiN/go-away"
+	"github.com/lib/pq"
 )
 
 // ClientError represents an error that should be shown to the client
@@ -39,6 +40,16 @@ type UserProfile struct {
 	ExamChallengesSolved             int       `json:"exam_challenges_solved"`
 	LastExamChallengeSolvedTimestamp time.Time `json:"last_exam_challenge_solved_timestamp"`
 	LastChallengeSolvedTimestamp     time.Time `json:"last_challenge_solved_timestamp"`
+	// Bloods are the challenges the user solved first, second or third
+	Bloods []Blood `json:"bloods"`
+}
+
+// Completion is what a regular challenge solve earned
+type Completion struct {
+	// Points is what the solve is worth now, including BonusPoints
+	Points        int
+	BonusPoints   int
+	SolvePosition int
 }
 
 // UserClient handles user-related operations
@@ -84,6 +95,11 @@ func (uc *UserClient) GetUserProfile(ctx context.Context, slackService *SlackSer
 		return nil, fmt.Errorf("failed to get user profile data: %w", err)
 	}
 
+	bloods, err := uc.getUserBloods(ctx, userEmail)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user bloods: %w", err)
+	}
+
 	profile := &UserProfile{
 		UserEmail:                        userEmail,
 		Alias:                            alias,
@@ -92,6 +108,7 @@ func (uc *UserClient) GetUserProfile(ctx context.Context, slackService *SlackSer
 		ExamChallengesSolved:             examChallengesSolved,
 		LastExamChallengeSolvedTimestamp: lastExamTimestamp,
 		LastChallengeSolvedTimestamp:     lastChallengeTimestamp,
+		Bloods:                           bloods,
 	}
 
 	uc.cache[userEmail] = profile
@@ -300,29 +317,54 @@ func (uc *UserClient) RemoveAlias(ctx context.Context, userEmail string) error {
 	return nil
 }
 
-// CompleteExamChallenge completes an exam challenge for a user
-func (uc *UserClient) CompleteExamChallenge(ctx context.Context, userEmail string, challengeID int) error {
-	// Start transaction
-	tx, err := uc.db.BeginTx(ctx, nil)
+// completeExamChallenge completes an exam challenge for a user in the caller's transaction,
+// awarding its completion reward and advancing the user through its track, and records the
+// correct submission, which burned tokensBurned tokens.
+// Returns the number of tokens awarded.
+func (uc *UserClient) completeExamChallenge(ctx context.Context, tx *sql.Tx, userEmail string, challengeID int, submittedFlag string, tokensBurned int) (int, error) {
+	if err := freezeScoreboard(ctx, tx, uc.config.Competition); err != nil {
+		return 0, err
+	}
+
+	var reward int
+	var track string
+	err := tx.QueryRowContext(ctx, `
+		SELECT completion_reward, COALESCE(exam_track, $2) FROM challenges WHERE id = $1
+	`, challengeID, DefaultExamTrack).Scan(&reward, &track)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return 0, fmt.Errorf("failed to query completion reward: %w", err)
 	}
-	defer tx.Rollback()
 
-	// Add token and increment exam challenges solved
+	// Add reward tokens and increment exam challenges solved across all tracks
 	query := `
 		INSERT INTO users (user_email, tokens_available, tokens_burned, points_achieved, exam_challenges_solved, last_exam_challenge_solved_timestamp, last_challenge_solved_timestamp) 
-		VALUES ($1, 1, 0, 0, 1, NOW(), NOW())
+		VALUES ($1, $2, 0, 0, 1, NOW(), NOW())
 		ON CONFLICT (user_email) 
 		DO UPDATE SET 
-			tokens_available = users.tokens_available + 1,
+			tokens_available = users.tokens_available + $2,
 			exam_challenges_solved = users.exam_challenges_solved + 1,
 			last_exam_challenge_solved_timestamp = NOW()
 	`
 
-	_, err = tx.ExecContext(ctx, query, userEmail)
+	_, err = tx.ExecContext(ctx, query, userEmail, reward)
+	if err != nil {
+		return 0, fmt.Errorf("failed to complete exam challenge: %w", err)
+	}
+	if err := recordLedgerEntry(ctx, tx, userEmail, LedgerEarn, reward, &challengeID, userEmail, ""); err != nil {
+		return 0, err
+	}
+
+	// Advance the user to the next stage of the track
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO user_exam_progress (user_email, track, stages_solved, last_solved_at)
+		VALUES ($1, $2, 1, NOW())
+		ON CONFLICT (user_email, track)
+		DO UPDATE SET
+			stages_solved = user_exam_progress.stages_solved + 1,
+			last_solved_at = NOW()
+	`, userEmail, track)
 	if err != nil {
-		return fmt.Errorf("failed to complete exam challenge: %w", err)
+		return 0, fmt.Errorf("failed to record exam track progress: %w", err)
 	}
 
 	// Record challenge completion
@@ -331,188 +373,233 @@ func (uc *UserClient) CompleteExamChallenge(ctx context.Context, userEmail strin
 		VALUES ($1, $2, NOW())
 	`, userEmail, challengeID)
 	if err != nil {
-		return fmt.Errorf("failed to record challenge completion: %w", err)
+		return 0, fmt.Errorf("failed to record challenge completion: %w", err)
 	}
 
 	// Log to user history
 	_, err = tx.ExecContext(ctx, `
 		INSERT INTO user_history_log (user_email, log, date) 
 		VALUES ($1, $2, NOW())
-	`, userEmail, fmt.Sprintf("Completed exam challenge %d: added 1 token", challengeID))
+	`, userEmail, fmt.Sprintf("Completed exam challenge %d in track %s: added %s", challengeID, track, tokenCount(reward)))
 	if err != nil {
-		return fmt.Errorf("failed to log exam challenge completion: %w", err)
+		return 0, fmt.Errorf("failed to log exam challenge completion: %w", err)
 	}
 
-	// Commit transaction
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	if err := recordSubmission(ctx, tx, userEmail, challengeID, submittedFlag, SubmissionCorrect, tokensBurned); err != nil {
+		return 0, err
 	}
 
-	// Invalidate cache
-	uc.mutex.Lock()
-	delete(uc.cache, userEmail)
-	uc.mutex.Unlock()
-
-	return nil
+	return reward, nil
 }
 
-// CompleteChallenge adds 1 token and points to a user's account in a single transaction
-func (uc *UserClient) CompleteChallenge(ctx context.Context, userEmail string, pointAmount int, challengeID int) error {
+// CompleteChallenge adds 1 token and points to a user's account and records the
+// correct submission in a single transaction. Returns what the completion earned.
+func (uc *UserClient) CompleteChallenge(ctx context.Context, userEmail string, challengeID int, result ValidationResult, submittedFlag string) (Completion, error) {
+	var completion Completion
+
 	// Start transaction
 	tx, err := uc.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return completion, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	// Add token and points in a single query
-	query := `
-		INSERT INTO users (user_email, tokens_available, tokens_burned, points_achieved, exam_challenges_solved, last_exam_challenge_solved_timestamp, last_challenge_solved_timestamp) 
-		VALUES ($1, 1, 0, $2, 0, NOW(), NOW())
-		ON CONFLICT (user_email) 
-		DO UPDATE SET 
-			tokens_available = users.tokens_available + 1,
-			points_achieved = users.points_achieved + $2,
-			last_challenge_solved_timestamp = NOW()
-	`
+	if err := freezeScoreboard(ctx, tx, uc.config.Competition); err != nil {
+		return completion, err
+	}
 
-	_, err = tx.ExecContext(ctx, query, userEmail, pointAmount)
+	// Lock the challenge so concurrent solves are ordered and counted in turn
+	var scoring ScoringMode
+	var pointReward int
+	var bonuses pq.Int64Array
+	err = tx.QueryRowContext(ctx, `
+		SELECT scoring, point_reward_amount, blood_bonuses FROM challenges WHERE id = $1 FOR UPDATE
+	`, challengeID).Scan(&scoring, &pointReward, &bonuses)
 	if err != nil {
-		return fmt.Errorf("failed to add token and points to user: %w", err)
+		return completion, fmt.Errorf("failed to lock challenge: %w", err)
 	}
+	pointsEarned := result.AwardedPoints(pointReward)
 
-	// Record challenge completion
-	_, err = tx.ExecContext(ctx, `
-		INSERT INTO user_challenges_completed (user_email, challenge_id, completed_at) 
-		VALUES ($1, $2, NOW())
-	`, userEmail, challengeID)
+	// Holding the lock, the next position can't be taken by a racing solve
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(MAX(solve_position), 0) + 1 FROM user_challenges_completed WHERE challenge_id = $1
+	`, challengeID).Scan(&completion.SolvePosition)
 	if err != nil {
-		return fmt.Errorf("failed to record challenge completion: %w", err)
+		return completion, fmt.Errorf("failed to query solve position: %w", err)
 	}
+	completion.BonusPoints = bloodBonus(bonuses, completion.SolvePosition)
 
-	// Log to user history
+	// Make sure the user exists; the row is locked with the other repriced users below
 	_, err = tx.ExecContext(ctx, `
-		INSERT INTO user_history_log (user_email, log, date) 
-		VALUES ($1, $2, NOW())
-	`, userEmail, fmt.Sprintf("Completed challenge %d: added 1 token and %d points", challengeID, pointAmount))
+		INSERT INTO users (user_email, tokens_available, tokens_burned, points_achieved, exam_challenges_solved, last_exam_challenge_solved_timestamp, last_challenge_solved_timestamp) 
+		VALUES ($1, 0, 0, 0, 0, NOW(), NOW())
+		ON CONFLICT (user_email) DO NOTHING
+	`, userEmail)
 	if err != nil {
-		return fmt.Errorf("failed to log challenge completion: %w", err)
+		return completion, fmt.Errorf("failed to create user: %w", err)
 	}
 
-	// Commit transaction
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	// Record challenge completion
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO user_challenges_completed (user_email, challenge_id, completed_at, score, points, solve_position, bonus_points) 
+		VALUES ($1, $2, NOW(), $3, $4, $5, $6)
+	`, userEmail, challengeID, result.Score, pointsEarned, completion.SolvePosition, completion.BonusPoints)
+	if err != nil {
+		return completion, fmt.Errorf("failed to record challenge completion: %w", err)
 	}
 
-	// Invalidate cache
-	uc.mutex.Lock()
-	delete(uc.cache, userEmail)
-	uc.mutex.Unlock()
-
-	return nil
-}
+	// Every solve of a dynamic challenge reprices it for all solvers
+	changed := []string{userEmail}
+	if scoring != ScoringStatic {
+		repriced, err := rescoreChallenge(ctx, tx, challengeID)
+		if err != nil {
+			return completion, err
+		}
+		changed = append(changed, repriced...)
 
-// BurnToken burns 1 token from a user's available balance
-// Returns the number of tokens successfully burned (0 if not enough tokens available)
-func (uc *UserClient) BurnToken(ctx context.Context, userEmail string, challengeID int) (int, error) {
-	// Start transaction
-	tx, err := uc.db.BeginTx(ctx, nil)
-	if err != nil {
-		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+		err = tx.QueryRowContext(ctx, `
+			SELECT points FROM user_challenges_completed WHERE user_email = $1 AND challenge_id = $2
+		`, userEmail, challengeID).Scan(&pointsEarned)
+		if err != nil {
+			return completion, fmt.Errorf("failed to query awarded points: %w", err)
+		}
 	}
-	defer tx.Rollback()
 
-	// Atomic check and burn in a single query
-	query := `
-		UPDATE users 
-		SET tokens_available = tokens_available - 1,
-		    tokens_burned = tokens_burned + 1
-		WHERE user_email = $1 AND tokens_available >= 1
-		RETURNING tokens_available
-	`
+	if err := refreshUserPoints(ctx, tx, changed); err != nil {
+		return completion, err
+	}
 
-	var newAvailableTokens int
-	err = tx.QueryRowContext(ctx, query, userEmail).Scan(&newAvailableTokens)
+	// Add token, points were derived from the completions above
+	_, err = tx.ExecContext(ctx, `
+		UPDATE users
+		SET tokens_available = tokens_available + 1,
+		    last_challenge_solved_timestamp = NOW()
+		WHERE user_email = $1
+	`, userEmail)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			// No rows affected means either user doesn't exist or not enough tokens
-			return 0, nil
-		}
-		return 0, fmt.Errorf("failed to burn token from user: %w", err)
+		return completion, fmt.Errorf("failed to add token to user: %w", err)
 	}
+	if err := recordLedgerEntry(ctx, tx, userEmail, LedgerEarn, 1, &challengeID, userEmail, ""); err != nil {
+		return completion, err
+	}
+
+	completion.Points = pointsEarned + completion.BonusPoints
 
 	// Log to user history
+	logEntry := fmt.Sprintf("Completed challenge %d: added 1 token and %d points", challengeID, completion.Points)
+	if completion.BonusPoints > 0 {
+		logEntry += fmt.Sprintf(" including a %d point %s bonus", completion.BonusPoints, strings.ToLower(bloodName(completion.SolvePosition)))
+	}
 	_, err = tx.ExecContext(ctx, `
 		INSERT INTO user_history_log (user_email, log, date) 
 		VALUES ($1, $2, NOW())
-	`, userEmail, fmt.Sprintf("Burned 1 token for exam challenge %d", challengeID))
+	`, userEmail, logEntry)
 	if err != nil {
-		return 0, fmt.Errorf("failed to log token burn: %w", err)
+		return completion, fmt.Errorf("failed to log challenge completion: %w", err)
+	}
+
+	if err := recordTeamCompletion(ctx, tx, uc.config.Teams, userEmail, challengeID, completion.Points); err != nil {
+		return completion, err
+	}
+
+	if err := recordSubmission(ctx, tx, userEmail, challengeID, submittedFlag, SubmissionCorrect, 0); err != nil {
+		return completion, err
 	}
 
 	// Commit transaction
 	if err = tx.Commit(); err != nil {
-		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+		return completion, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	// Invalidate cache
 	uc.mutex.Lock()
-	delete(uc.cache, userEmail)
+	for _, email := range changed {
+		delete(uc.cache, email)
+	}
 	uc.mutex.Unlock()
 
-	// If the update succeeded, we burned 1 token
-	return 1, nil
+	return completion, nil
 }
 
-// RefundToken refunds 1 token by decreasing tokens_burned and increasing tokens_available
-func (uc *UserClient) RefundToken(ctx context.Context, userEmail string, challengeID int) error {
-	// Start transaction
-	tx, err := uc.db.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer tx.Rollback()
-
-	// Refund token
-	query := `
-		UPDATE users 
-		SET tokens_available = tokens_available + 1,
-		    tokens_burned = tokens_burned - 1
-		WHERE user_email = $1 AND tokens_burned >= 1
-	`
+// InvalidateProfiles drops every cached profile, for changes that reprice many users at once
+func (uc *UserClient) InvalidateProfiles() {
+	uc.mutex.Lock()
+	uc.cache = make(map[string]*UserProfile)
+	uc.mutex.Unlock()
+}
 
-	result, err := tx.ExecContext(ctx, query, userEmail)
+// burnTokens charges an exam challenge's submission cost and uses up one of the user's
+// attempts in the caller's transaction. In team mode the tokens come from the user if they
+// have enough, otherwise from a single teammate. Returns the number of tokens burned and
+// who paid them.
+// Error messages for running out of tokens or attempts can be returned to the client
+func (uc *UserClient) burnTokens(ctx context.Context, tx *sql.Tx, userEmail string, challengeID int) (int, string, error) {
+	var cost int
+	var maxAttempts sql.NullInt64
+	err := tx.QueryRowContext(ctx, `
+		SELECT submission_cost, max_attempts FROM challenges WHERE id = $1
+	`, challengeID).Scan(&cost, &maxAttempts)
 	if err != nil {
-		return fmt.Errorf("failed to refund token for user: %w", err)
+		return 0, "", fmt.Errorf("failed to query submission cost: %w", err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected for token refund: %w", err)
+	// Claim an attempt; racing submissions queue on the attempts row so the limit holds
+	var attempts int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO exam_attempts (user_email, challenge_id, attempts)
+		VALUES ($1, $2, 1)
+		ON CONFLICT (user_email, challenge_id) DO UPDATE SET attempts = exam_attempts.attempts + 1
+		WHERE $3::integer IS NULL OR exam_attempts.attempts < $3
+		RETURNING attempts
+	`, userEmail, challengeID, maxAttempts).Scan(&attempts)
+	if err == sql.ErrNoRows {
+		return 0, "", ClientError{Message: fmt.Sprintf("No attempts left. Each user gets %d submissions for this exam challenge.", maxAttempts.Int64)}
 	}
-
-	if rowsAffected == 0 {
-		return fmt.Errorf("no tokens to refund for user %s", userEmail)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to claim exam attempt: %w", err)
+	}
+
+	payerEmail := userEmail
+	if cost > 0 {
+		// Atomic check and burn in a single query
+		query := `
+			UPDATE users 
+			SET tokens_available = tokens_available - $2,
+			    tokens_burned = tokens_burned + $2
+			WHERE user_email = (
+				SELECT user_email FROM users
+				WHERE user_email IN (` + tokenPoolQuery(uc.config.Teams) + `) AND tokens_available >= $2
+				ORDER BY user_email = $1 DESC, tokens_available DESC, user_email
+				LIMIT 1
+			) AND tokens_available >= $2
+			RETURNING user_email
+		`
+
+		err = tx.QueryRowContext(ctx, query, userEmail, cost).Scan(&payerEmail)
+		if err == sql.ErrNoRows {
+			// No rows affected means either user doesn't exist or not enough tokens
+			return 0, "", ClientError{Message: fmt.Sprintf("Insufficient tokens. Each submission to this exam challenge costs %s.", tokenCount(cost))}
+		}
+		if err != nil {
+			return 0, "", fmt.Errorf("failed to burn token from user: %w", err)
+		}
+		if err := recordLedgerEntry(ctx, tx, payerEmail, LedgerBurn, -cost, &challengeID, userEmail, ""); err != nil {
+			return 0, "", err
+		}
 	}
 
 	// Log to user history
+	logEntry := fmt.Sprintf("Burned %s for exam challenge %d (attempt %d)", tokenCount(cost), challengeID, attempts)
+	if payerEmail != userEmail {
+		logEntry = fmt.Sprintf("Burned %s from the team pool for %s's exam challenge %d (attempt %d)", tokenCount(cost), userEmail, challengeID, attempts)
+	}
 	_, err = tx.ExecContext(ctx, `
 		INSERT INTO user_history_log (user_email, log, date) 
 		VALUES ($1, $2, NOW())
-	`, userEmail, fmt.Sprintf("Refunded 1 token for exam challenge %d", challengeID))
+	`, payerEmail, logEntry)
 	if err != nil {
-		return fmt.Errorf("failed to log token refund: %w", err)
+		return 0, "", fmt.Errorf("failed to log token burn: %w", err)
 	}
 
-	// Commit transaction
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
-	// Invalidate cache
-	uc.mutex.Lock()
-	delete(uc.cache, userEmail)
-	uc.mutex.Unlock()
-
-	return nil
+	return cost, payerEmail, nil
 }
@@ -0,0 +1,318 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/obelisk/example-ctf/config"
+)
+
+func TestValidatorRegistry(t *testing.T) {
+	registry := NewValidatorRegistry()
+	if names := registry.Names(); len(names) != 0 {
+		t.Fatalf("new registry has validators %v", names)
+	}
+
+	if err := registry.Register("Exact", exactValidator{}); err != nil {
+		t.Fatalf("Register(Exact) = %v", err)
+	}
+	if err := registry.Register("Exact", caseInsensitiveValidator{}); err == nil {
+		t.Error("registering Exact twice succeeded")
+	}
+	if err := registry.Register("", exactValidator{}); err == nil {
+		t.Error("registering an empty name succeeded")
+	}
+
+	validator, ok := registry.Get("Exact")
+	if !ok {
+		t.Fatal("Get(Exact) found nothing")
+	}
+	if _, isExact := validator.(exactValidator); !isExact {
+		t.Errorf("Get(Exact) = %T, want the first registration to stick", validator)
+	}
+	if _, ok := registry.Get("exact"); ok {
+		t.Error("handler names should be case-sensitive")
+	}
+	if _, ok := registry.Get("Missing"); ok {
+		t.Error("Get(Missing) found a validator")
+	}
+}
+
+func TestBuiltinValidators(t *testing.T) {
+	registry := NewValidatorRegistry()
+	if err := registerBuiltinValidators(registry, &config.Config{}); err != nil {
+		t.Fatalf("registerBuiltinValidators() = %v", err)
+	}
+
+	// Every handler name stored in existing challenges must keep resolving
+	want := []string{
+		"CaseInsensitive", "ClientSideWasm", "Exact", "Keccak256HashOfUsername", "Md5HashOfUsername",
+		"OneOf", "PerUserHMAC", "Regex", "Sha1HashOfUsername", "Sha256HashOfUsername", "StringEqual",
+	}
+	if got := registry.Names(); !slices.Equal(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+
+	// Builtins are registered once, so a second pass must fail rather than replace them
+	if err := registerBuiltinValidators(registry, &config.Config{}); err == nil {
+		t.Error("registering the builtins twice succeeded")
+	}
+}
+
+// newWasmKey returns a signing key and the PEM public key stored as the flag value
+func newWasmKey(t *testing.T) (ed25519.PrivateKey, string) {
+	t.Helper()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal public key: %v", err)
+	}
+	return privateKey, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// signWasmToken mints the token the challenge binary would hand out for userData
+func signWasmToken(t *testing.T, key ed25519.PrivateKey, userData string) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{
+		"is_admin":  true,
+		"user_data": userData,
+	}).SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return token
+}
+
+func TestClientSideWasmFindOwner(t *testing.T) {
+	key, publicKeyPEM := newWasmKey(t)
+	registered := []string{"a@example.com", "ba@example.com", "carol@example.com"}
+	validator := clientSideWasmValidator{}
+
+	findOwner := func(submitter, userData string) (string, bool) {
+		return validator.FindOwner(ValidationInput{
+			UserEmail: submitter,
+			Submitted: signWasmToken(t, key, userData),
+			Expected:  publicKeyPEM,
+		}, registered)
+	}
+
+	// ba@example.com contains a@example.com; their own token must not be pinned on a@
+	if owner, found := findOwner("ba@example.com", "ba@example.com"); found {
+		t.Errorf("own token with an overlapping registered email was attributed to %s", owner)
+	}
+	if owner, found := findOwner("BA@example.com", "ba@example.com"); found {
+		t.Errorf("own token in a different case was attributed to %s", owner)
+	}
+	if owner, found := findOwner("carol@example.com", "ba@example.com"); !found || owner != "ba@example.com" {
+		t.Errorf("token minted for ba@example.com: owner = %q, found = %v", owner, found)
+	}
+	if owner, found := findOwner("ba@example.com", "a@example.com"); !found || owner != "a@example.com" {
+		t.Errorf("token minted for a@example.com: owner = %q, found = %v", owner, found)
+	}
+	if owner, found := findOwner("me@home", "me@home"); found {
+		t.Errorf("token for an unregistered submitter was attributed to %s", owner)
+	}
+	if owner, found := findOwner("carol@example.com", "just a note"); found {
+		t.Errorf("token without an email was attributed to %s", owner)
+	}
+
+	otherKey, _ := newWasmKey(t)
+	forged := ValidationInput{
+		UserEmail: "carol@example.com",
+		Submitted: signWasmToken(t, otherKey, "a@example.com"),
+		Expected:  publicKeyPEM,
+	}
+	if owner, found := validator.FindOwner(forged, registered); found {
+		t.Errorf("token signed with the wrong key was attributed to %s", owner)
+	}
+}
+
+func TestRegexValidator(t *testing.T) {
+	validator := newRegexValidator()
+
+	tests := []struct {
+		pattern   string
+		params    string
+		submitted string
+		want      bool
+	}{
+		{`flag\{[0-9]+\}`, ``, `flag{123}`, true},
+		{`flag\{[0-9]+\}`, ``, `flag{12a}`, false},
+		{`flag\{[0-9]+\}`, ``, `xflag{123}`, false},
+		{`flag\{[0-9]+\}`, ``, `flag{123}x`, false},
+		{`flag\{[0-9]+\}`, ``, `FLAG{123}`, false},
+		{`flag\{[0-9]+\}`, `{"case_insensitive": true}`, `FLAG{123}`, true},
+		{`cat|dog`, ``, `cat`, true},
+		{`cat|dog`, ``, `catdog`, false},
+		{`cat|dog`, `{"case_insensitive": false}`, `DOG`, false},
+	}
+
+	for _, tt := range tests {
+		input := ValidationInput{ChallengeID: 1, Submitted: tt.submitted, Expected: tt.pattern, Params: json.RawMessage(tt.params)}
+		if got := validator.Validate(input).Valid; got != tt.want {
+			t.Errorf("pattern %q params %q: Validate(%q) = %v, want %v", tt.pattern, tt.params, tt.submitted, got, tt.want)
+		}
+	}
+
+	// Editing a challenge's pattern must not keep matching the old one from the cache
+	first := ValidationInput{ChallengeID: 7, Submitted: "old", Expected: "old"}
+	if !validator.Validate(first).Valid {
+		t.Fatal("old pattern did not match")
+	}
+	edited := ValidationInput{ChallengeID: 7, Submitted: "old", Expected: "new"}
+	if validator.Validate(edited).Valid {
+		t.Error("cached pattern matched after the flag value changed")
+	}
+
+	for _, bad := range []struct{ pattern, params string }{
+		{``, ``},
+		{`flag{(`, ``},
+		{`flag`, `{"case_sensitive": true}`},
+		{`flag`, `[]`},
+	} {
+		if err := validator.CheckParams(bad.pattern, json.RawMessage(bad.params)); err == nil {
+			t.Errorf("CheckParams(%q, %q) = nil, want an error", bad.pattern, bad.params)
+		}
+	}
+}
+
+func TestOneOfValidator(t *testing.T) {
+	validator := oneOfValidator{}
+	alternatives := json.RawMessage(`{"alternatives": ["Tux", "Wilber"]}`)
+	caseSensitive := json.RawMessage(`{"alternatives": ["Tux"], "case_sensitive": true}`)
+
+	tests := []struct {
+		params    json.RawMessage
+		submitted string
+		want      bool
+	}{
+		{nil, "penguin", true},
+		{nil, "PENGUIN", true},
+		{nil, "tux", false},
+		{alternatives, "penguin", true},
+		{alternatives, "tux", true},
+		{alternatives, "WILBER", true},
+		{alternatives, "gnu", false},
+		{alternatives, "", false},
+		{caseSensitive, "Tux", true},
+		{caseSensitive, "tux", false},
+		{caseSensitive, "Penguin", false},
+		{caseSensitive, "penguin", true},
+	}
+
+	for _, tt := range tests {
+		input := ValidationInput{Submitted: tt.submitted, Expected: "penguin", Params: tt.params}
+		if got := validator.Validate(input).Valid; got != tt.want {
+			t.Errorf("params %s: Validate(%q) = %v, want %v", tt.params, tt.submitted, got, tt.want)
+		}
+	}
+
+	if err := validator.CheckParams("penguin", alternatives); err != nil {
+		t.Errorf("CheckParams with alternatives = %v", err)
+	}
+	if err := validator.CheckParams("penguin", json.RawMessage(`{"alternatives": ["tux", ""]}`)); err == nil {
+		t.Error("an empty alternative was accepted")
+	}
+	if err := validator.CheckParams("", nil); err == nil {
+		t.Error("an empty flag value was accepted")
+	}
+}
+
+func TestPerUserHMACValidator(t *testing.T) {
+	validator := perUserHMACValidator{secret: []byte("test-secret")}
+	input := func(userEmail, submitted, params string) ValidationInput {
+		return ValidationInput{
+			ChallengeID: 42,
+			UserEmail:   userEmail,
+			Submitted:   submitted,
+			Expected:    "ctf",
+			Params:      json.RawMessage(params),
+		}
+	}
+
+	// The flag format is part of the contract with challenge authors, so check it independently
+	mac := hmac.New(sha256.New, []byte("test-secret"))
+	mac.Write([]byte("alice@example.com:42"))
+	aliceFlag := "ctf{" + hex.EncodeToString(mac.Sum(nil))[:defaultPerUserHMACLength] + "}"
+
+	derived, err := validator.DeriveFlag(input("Alice@Example.com", "", ""))
+	if err != nil {
+		t.Fatalf("DeriveFlag() = %v", err)
+	}
+	if derived != aliceFlag {
+		t.Fatalf("DeriveFlag() = %q, want %q", derived, aliceFlag)
+	}
+
+	short, err := validator.DeriveFlag(input("alice@example.com", "", `{"length": 8}`))
+	if err != nil {
+		t.Fatalf("DeriveFlag() with length 8 = %v", err)
+	}
+	if short != aliceFlag[:len("ctf{")+8]+"}" {
+		t.Errorf("DeriveFlag() with length 8 = %q", short)
+	}
+
+	if !validator.Validate(input("alice@example.com", aliceFlag, "")).Valid {
+		t.Error("alice's own flag was rejected")
+	}
+	if validator.Validate(input("bob@example.com", aliceFlag, "")).Valid {
+		t.Error("bob was accepted with alice's flag")
+	}
+	if validator.Validate(input("alice@example.com", strings.ToUpper(aliceFlag), "")).Valid {
+		t.Error("flags should be compared case-sensitively")
+	}
+
+	candidates := []string{"alice@example.com", "bob@example.com"}
+	if owner, found := validator.FindOwner(input("bob@example.com", aliceFlag, ""), candidates); !found || owner != "alice@example.com" {
+		t.Errorf("FindOwner(alice's flag) = %q, %v", owner, found)
+	}
+	if owner, found := validator.FindOwner(input("alice@example.com", aliceFlag, ""), candidates); found {
+		t.Errorf("alice's own flag was attributed to %s", owner)
+	}
+
+	for _, submitted := range []string{
+		"ctf{guess}",
+		"ctf{" + strings.Repeat("0", defaultPerUserHMACLength-1) + "}",
+		"ctf{" + strings.Repeat("A", defaultPerUserHMACLength) + "}",
+		"other{" + aliceFlag[len("ctf{"):],
+		aliceFlag + " ",
+	} {
+		if _, ok := validator.OwnerHint(input("bob@example.com", submitted, "")); ok {
+			t.Errorf("OwnerHint(%q) accepted a submission that cannot be a derived flag", submitted)
+		}
+	}
+	if hint, ok := validator.OwnerHint(input("bob@example.com", aliceFlag, "")); !ok || hint != "" {
+		t.Errorf("OwnerHint(alice's flag) = %q, %v, want every user as a candidate", hint, ok)
+	}
+
+	for _, params := range []string{``, `{"length": 1}`, `{"length": 64}`} {
+		if err := validator.CheckParams("ctf", json.RawMessage(params)); err != nil {
+			t.Errorf("CheckParams(%q) = %v", params, err)
+		}
+	}
+	for _, params := range []string{`{"length": -1}`, `{"length": 65}`, `{"len": 8}`} {
+		if err := validator.CheckParams("ctf", json.RawMessage(params)); err == nil {
+			t.Errorf("CheckParams(%q) = nil, want an error", params)
+		}
+	}
+
+	unconfigured := perUserHMACValidator{}
+	if err := unconfigured.CheckParams("ctf", nil); err == nil {
+		t.Error("CheckParams without a secret = nil, want an error")
+	}
+	if unconfigured.Validate(input("alice@example.com", aliceFlag, "")).Valid {
+		t.Error("a validator without a secret accepted a flag")
+	}
+}
@@ -5,6 +5,7 @@ class CTFApp {
         this.currentChallenge = null;
         this.userInfo = null;
         this.challengeAccessed = {}; // Track accessed/viewed challenges
+        this.pendingExamSubmission = null; // Exam submission still waiting for an answer, with its idempotency key
         
         // Load accessed challenges from localStorage
         this.loadAccessedChallenges();
@@ -532,17 +533,30 @@ class CTFApp {
             }
         }
         
+        const apiEndpoint = this.currentChallenge.is_exam 
+            ? `/api/adoble/${this.currentChallenge.id}/submission`
+            : `/api/challenges/${this.currentChallenge.id}/submission`;
+
+        // Exam submissions burn tokens, so a double click or a retry after a lost
+        // answer sends the same idempotency key and is only charged once
+        const headers = {};
+        if (this.currentChallenge.is_exam) {
+            headers['Idempotency-Key'] = this.examSubmissionKey(apiEndpoint, flag);
+        }
+
         try {
-            const apiEndpoint = this.currentChallenge.is_exam 
-                ? `/api/adoble/${this.currentChallenge.id}/submission`
-                : `/api/challenges/${this.currentChallenge.id}/submission`;
-                
             const response = await this.apiCall(apiEndpoint, {
                 method: 'POST',
+                headers: headers,
                 body: JSON.stringify({
                     flag: flag
                 })
             });
+
+            // The server answered; only a failed server keeps the key for a retry
+            if (this.currentChallenge.is_exam && response.status < 500) {
+                this.pendingExamSubmission = null;
+            }
             
             const result = await response.json();
             
@@ -603,6 +617,17 @@ class CTFApp {
         }
     }
 
+    // Idempotency key for an exam submission, reused while the same flag
+    // for the same challenge hasn't been answered yet
+    examSubmissionKey(endpoint, flag) {
+        const pending = this.pendingExamSubmission;
+        if (pending && pending.endpoint === endpoint && pending.flag === flag) {
+            return pending.key;
+        }
+        this.pendingExamSubmission = { endpoint, flag, key: crypto.randomUUID() };
+        return this.pendingExamSubmission.key;
+    }
+
     // Show flag submission result
     showFlagResult(message, type) {
         const resultDiv = document.getElementById('flagResult');
@@ -2,6 +2,7 @@
 -- This file contains sample data to simulate the specified user state
 
 -- Clear existing data (optional - uncomment if you want to start fresh)
+-- DELETE FROM submissions;
 -- DELETE FROM user_history_log;
 -- DELETE FROM user_challenges_completed;
 -- DELETE FROM user_aliases;
@@ -90,6 +91,31 @@ INSERT INTO user_challenges_completed (user_email, challenge_id, completed_at) V
 INSERT INTO user_challenges_completed (user_email, challenge_id, completed_at) VALUES
 ('monzer@smartcontract.com', 1, NOW() - INTERVAL '1 day 9 hours 10 minutes');
 
+-- Migrations run before this file, so give the sample completions what their
+-- backfills would have: each solve's points and place in its challenge's solve order
+UPDATE user_challenges_completed ucc
+SET points = c.point_reward_amount
+FROM challenges c
+WHERE c.id = ucc.challenge_id AND c.category != 'exam';
+
+UPDATE user_challenges_completed ucc
+SET solve_position = ordered.position
+FROM (
+    SELECT ucc.user_email, ucc.challenge_id,
+           ROW_NUMBER() OVER (PARTITION BY ucc.challenge_id ORDER BY ucc.completed_at, ucc.user_email) AS position
+    FROM user_challenges_completed ucc
+    JOIN challenges c ON c.id = ucc.challenge_id
+    WHERE c.category != 'exam'
+) ordered
+WHERE ucc.user_email = ordered.user_email
+  AND ucc.challenge_id = ordered.challenge_id
+  AND ucc.solve_position IS NULL;
+
+-- Keep the sample totals above; whatever the completions don't explain becomes the adjustment
+UPDATE users u
+SET points_adjustment = u.points_achieved
+    - COALESCE((SELECT SUM(ucc.points + ucc.bonus_points) FROM user_challenges_completed ucc WHERE ucc.user_email = u.user_email), 0);
+
 -- Insert user history log entries for challenge completions
 INSERT INTO user_history_log (user_email, log, date) VALUES
 -- thanh.nguyen@smartcontract.com completions
@@ -224,4 +250,23 @@ INSERT INTO user_history_log (user_email, log, date) VALUES
 ('harry.anderson@smartcontract.com', 'Wrong flag attempt for challenge 2: another_wrong', NOW() - INTERVAL '1 day 10 hours'),
 ('william.spencer@smartcontract.com', 'Wrong flag attempt for challenge 2: incorrect_flag', NOW() - INTERVAL '2 days 12 hours'),
 ('martin.minkov@smartcontract.com', 'Wrong flag attempt for challenge 2: not_right', NOW() - INTERVAL '6 hours'),
-('sean.hartog@smartcontract.com', 'Wrong flag attempt for challenge 2: try_again', NOW() - INTERVAL '13 hours'); 
\ No newline at end of file
+('sean.hartog@smartcontract.com', 'Wrong flag attempt for challenge 2: try_again', NOW() - INTERVAL '13 hours'); 
+-- Mirror the sample completions and wrong attempts into the submissions table
+INSERT INTO submissions (user_email, challenge_id, submitted_hash, result, tokens_burned, created_at)
+SELECT ucc.user_email, ucc.challenge_id, '', 'correct',
+       CASE WHEN c.category = 'exam' THEN 1 ELSE 0 END, ucc.completed_at
+FROM user_challenges_completed ucc
+JOIN challenges c ON c.id = ucc.challenge_id
+UNION ALL
+SELECT h.user_email, c.id, encode(sha256(convert_to(substring(h.log FROM '^Wrong flag attempt for challenge \d+: (.*)$'), 'UTF8')), 'hex'),
+       'incorrect', 0, h.date
+FROM user_history_log h
+JOIN challenges c ON c.id = substring(h.log FROM '^Wrong flag attempt for challenge (\d+): ')::INTEGER
+WHERE h.log ~ '^Wrong flag attempt for challenge \d+: '
+UNION ALL
+SELECT h.user_email, c.id, encode(sha256(convert_to(substring(h.log FROM '^Wrong flag attempt for exam challenge \d+: (.*)$'), 'UTF8')), 'hex'),
+       'incorrect', 1, h.date
+FROM user_history_log h
+JOIN challenges c ON c.category = 'exam'
+                 AND c.nested_id = substring(h.log FROM '^Wrong flag attempt for exam challenge (\d+): ')::INTEGER
+WHERE h.log ~ '^Wrong flag attempt for exam challenge \d+: ';